@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"gocloud.dev/blob"
+)
+
+const (
+	// storageResource is the Azure AD resource tokens for Azure Storage are
+	// requested for.
+	storageResource = "https://storage.azure.com/"
+
+	// defaultAuthorityHost is the Azure AD endpoint of the public cloud,
+	// overridden by AZURE_AUTHORITY_HOST for other clouds.
+	defaultAuthorityHost = "https://login.microsoftonline.com/"
+
+	// maxDelegationKeyLifetime is the longest a user delegation key, and so
+	// a SAS signed with it, can be valid for.
+	maxDelegationKeyLifetime = 7 * 24 * time.Hour
+)
+
+var (
+	// Flags
+	authMode string
+
+	// The user delegation key SAS are signed with in Azure AD mode, reused
+	// for as long as it is valid.
+	delegationMu         sync.Mutex
+	delegationCredential *azblob.UserDelegationCredential
+	delegationExpiry     time.Time
+)
+
+// useAAD reports whether requests are authorized with Azure AD tokens.
+func useAAD() bool {
+	return authMode == "aad"
+}
+
+// checkAuthMode returns an error if --auth-mode is invalid or can't be used
+// with the other flags.
+func checkAuthMode() error {
+	switch authMode {
+	case "key":
+		return nil
+	case "aad":
+	default:
+		return fmt.Errorf(`flag "--auth-mode" should be one of "key" or "aad"`)
+	}
+	if sasToken != "" {
+		return fmt.Errorf(`flag "--auth-mode" aad cannot be combined with a SAS token`)
+	}
+	if emulator {
+		return fmt.Errorf(`flag "--auth-mode" aad cannot be combined with "--emulator", which only supports the account key`)
+	}
+	return nil
+}
+
+// newTokenCredential returns a credential authorizing requests with Azure AD
+// tokens, which are refreshed before they expire. Like
+// DefaultAzureCredential, it uses the service principal of AZURE_TENANT_ID,
+// AZURE_CLIENT_ID and AZURE_CLIENT_SECRET if set, and the managed identity
+// of the VM or AKS pod otherwise, the user-assigned one of AZURE_CLIENT_ID if
+// set.
+func newTokenCredential() (azblob.TokenCredential, error) {
+	spt, err := newServicePrincipalToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := spt.RefreshWithContext(ctx); err != nil {
+		return nil, fmt.Errorf("getting an Azure AD token: %v", err)
+	}
+
+	return azblob.NewTokenCredential(spt.OAuthToken(), func(tc azblob.TokenCredential) time.Duration {
+		if err := spt.RefreshWithContext(ctx); err != nil {
+			// Keep the current token and try again shortly
+			logger.Warn(fmt.Sprintf("Refreshing the Azure AD token: %v", err))
+			return time.Minute
+		}
+		tc.SetToken(spt.OAuthToken())
+		// Refresh a few minutes before the token expires
+		if d := time.Until(spt.Token().Expires()) - 5*time.Minute; d > time.Minute {
+			return d
+		}
+		return time.Minute
+	}), nil
+}
+
+// aadCredentialSource describes the credential newTokenCredential uses.
+func aadCredentialSource() string {
+	if os.Getenv("AZURE_TENANT_ID") != "" && os.Getenv("AZURE_CLIENT_ID") != "" && os.Getenv("AZURE_CLIENT_SECRET") != "" {
+		return fmt.Sprintf("service principal %s (secret redacted)", os.Getenv("AZURE_CLIENT_ID"))
+	}
+	if clientID := os.Getenv("AZURE_CLIENT_ID"); clientID != "" {
+		return fmt.Sprintf("managed identity %s", clientID)
+	}
+	return "managed identity"
+}
+
+// newServicePrincipalToken returns the token source of newTokenCredential.
+func newServicePrincipalToken() (*adal.ServicePrincipalToken, error) {
+	tenantID := os.Getenv("AZURE_TENANT_ID")
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+	clientSecret := os.Getenv("AZURE_CLIENT_SECRET")
+
+	if tenantID != "" && clientID != "" && clientSecret != "" {
+		authority := os.Getenv("AZURE_AUTHORITY_HOST")
+		if authority == "" {
+			authority = defaultAuthorityHost
+		}
+		config, err := adal.NewOAuthConfig(authority, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("AZURE_AUTHORITY_HOST or AZURE_TENANT_ID: %v", err)
+		}
+		return adal.NewServicePrincipalToken(*config, clientID, clientSecret, storageResource)
+	}
+
+	spt, err := adal.NewServicePrincipalTokenFromManagedIdentity(storageResource, &adal.ManagedIdentityOptions{ClientID: clientID})
+	if err != nil {
+		return nil, fmt.Errorf("no Azure AD credential available, set AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET or run with a managed identity: %v", err)
+	}
+	return spt, nil
+}
+
+// userDelegationCredential returns a user delegation key valid until at
+// least expiry, requesting a new one if the current one expires earlier.
+func userDelegationCredential(ctx context.Context, expiry time.Time) (azblob.UserDelegationCredential, error) {
+	delegationMu.Lock()
+	defer delegationMu.Unlock()
+
+	if delegationCredential != nil && !delegationExpiry.Before(expiry) {
+		return *delegationCredential, nil
+	}
+
+	// Allow for clock skew between this host and the service
+	start := time.Now().UTC().Add(-5 * time.Minute)
+	svcURL := azblob.NewServiceURL(serviceURL(), pline)
+	cred, err := svcURL.GetUserDelegationCredential(ctx, azblob.NewKeyInfo(start, expiry.UTC()), nil, nil)
+	if err != nil {
+		return azblob.UserDelegationCredential{}, fmt.Errorf("getting a user delegation key: %v", err)
+	}
+	delegationCredential, delegationExpiry = &cred, expiry
+	return cred, nil
+}
+
+// delegationSignedURL returns a URL to key in the named container signed
+// with a user delegation key for perms, valid for expiry.
+func delegationSignedURL(ctx context.Context, container, key string, perms azblob.BlobSASPermissions, expiry time.Duration) (url.URL, error) {
+	if expiry > maxDelegationKeyLifetime {
+		return url.URL{}, fmt.Errorf("a SAS signed with a user delegation key can be valid for at most %s, not %s", maxDelegationKeyLifetime, expiry)
+	}
+
+	end := time.Now().UTC().Add(expiry)
+	cred, err := userDelegationCredential(ctx, end)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	parts := azblob.NewBlobURLParts(newBlobURL(container, key).URL())
+	sas, err := azblob.BlobSASSignatureValues{
+		Protocol:      sasProtocol(),
+		ExpiryTime:    end,
+		ContainerName: container,
+		BlobName:      key,
+		Permissions:   perms.String(),
+	}.NewSASQueryParameters(cred)
+	if err != nil {
+		return url.URL{}, err
+	}
+	parts.SAS = sas
+	return parts.URL(), nil
+}
+
+// signURL returns a signed URL to key in b, the bucket of the container
+// named by --container-name. In Azure AD mode there is no account key to
+// sign with, so the URL is signed with a user delegation key instead.
+func signURL(ctx context.Context, b *blob.Bucket, key string, opts *blob.SignedURLOptions) (string, error) {
+	if !useAAD() {
+		return b.SignedURL(ctx, key, opts)
+	}
+
+	var perms azblob.BlobSASPermissions
+	switch strings.ToUpper(opts.Method) {
+	case "", http.MethodGet:
+		perms.Read = true
+	case http.MethodPut:
+		perms.Create, perms.Write = true, true
+	case http.MethodDelete:
+		perms.Delete = true
+	default:
+		return "", fmt.Errorf("unsupported method %q to sign a URL for", opts.Method)
+	}
+	u, err := delegationSignedURL(ctx, containerName, key, perms, opts.Expiry)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	accountInfoCmd = &cobra.Command{
+		Use:         "account-info",
+		Short:       "Show the storage account's SKU and kind",
+		Annotations: accountLevel,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Create a ServiceURL object that wraps the service URL and a request
+			// pipeline to make requests.
+			info, err := azblob.NewServiceURL(serviceURL(), pline).GetAccountInfo(ctx)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(out, "Account:", accountName)
+			fmt.Fprintln(out, "SKU:", info.SkuName())
+			fmt.Fprintln(out, "Kind:", info.AccountKind())
+			if hns := info.IsHierarchicalNamespaceEnabled(); hns != "" {
+				fmt.Fprintln(out, "Hierarchical namespace:", hns)
+			}
+
+			// Access tiers are only available on general-purpose v2 and blob
+			// storage accounts with a standard SKU.
+			if !supportsAccessTiers(info.SkuName(), info.AccountKind()) {
+				fmt.Fprintln(out)
+				fmt.Fprintln(out, "Note: this account does not support hot/cool/archive access tiers")
+			}
+
+			logger.Info(fmt.Sprintf("Successfully read account info for %q", accountName))
+			return nil
+		},
+	}
+)
+
+// supportsAccessTiers reports whether an account with the given SKU and kind
+// supports blob-level hot/cool/archive access tiers.
+func supportsAccessTiers(sku azblob.SkuNameType, kind azblob.AccountKindType) bool {
+	if strings.HasPrefix(string(sku), "Premium") {
+		return false
+	}
+	return kind == azblob.AccountKindStorageV2 || kind == azblob.AccountKindBlobStorage
+}
+
+func init() {
+	rootCmd.AddCommand(accountInfoCmd)
+}
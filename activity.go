@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+// dayFormat is the layout used for day buckets and the --since/--until flags.
+const dayFormat = "2006-01-02"
+
+var (
+	// Flags
+	since string
+	until string
+
+	// Commands
+	activityCmd = &cobra.Command{
+		Use:   "activity",
+		Short: "Show a per-day histogram of blobs modified under a prefix",
+		Long: `Show a per-day histogram of blobs modified under a prefix.
+
+Blobs under --blob-prefix are bucketed by the UTC day of their last
+modification, and the number of blobs and bytes per day is printed. Use
+--since and --until (YYYY-MM-DD, inclusive) to restrict the time window.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if outputFormat != "text" && outputFormat != "json" {
+				return fmt.Errorf(`flag "--output" should be one of "text" or "json"`)
+			}
+
+			var from, to time.Time
+			if since != "" {
+				t, err := time.Parse(dayFormat, since)
+				if err != nil {
+					return fmt.Errorf(`flag "--since" should be a date like 2006-01-02: %v`, err)
+				}
+				from = t
+			}
+			if until != "" {
+				t, err := time.Parse(dayFormat, until)
+				if err != nil {
+					return fmt.Errorf(`flag "--until" should be a date like 2006-01-02: %v`, err)
+				}
+				// Include the whole day.
+				to = t.AddDate(0, 0, 1)
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			days := make(map[string]*dayActivity)
+			iter := bucket.List(&blob.ListOptions{Prefix: blobPrefix})
+			for {
+				obj, err := iter.Next(ctx)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				mod := obj.ModTime.UTC()
+				if (!from.IsZero() && mod.Before(from)) || (!to.IsZero() && !mod.Before(to)) {
+					continue
+				}
+
+				day := mod.Format(dayFormat)
+				if days[day] == nil {
+					days[day] = &dayActivity{Day: day}
+				}
+				days[day].Count++
+				days[day].Bytes += obj.Size
+			}
+
+			result := make([]*dayActivity, 0, len(days))
+			for _, d := range days {
+				result = append(result, d)
+			}
+			sort.Slice(result, func(i, j int) bool { return result[i].Day < result[j].Day })
+
+			if outputFormat == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(result); err != nil {
+					return err
+				}
+				return nil
+			}
+
+			printActivity(out, result)
+			logger.Info(fmt.Sprintf("Successfully summarized activity under %q", blobPrefix))
+			return nil
+		},
+	}
+)
+
+// dayActivity is the number of blobs and bytes last modified on a day.
+type dayActivity struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+// histogramWidth is the width of the longest bar printed by printActivity.
+const histogramWidth = 40
+
+// printActivity prints days to out as a histogram of blob counts.
+func printActivity(out io.Writer, days []*dayActivity) {
+	max := 0
+	for _, d := range days {
+		if d.Count > max {
+			max = d.Count
+		}
+	}
+	for _, d := range days {
+		bar := strings.Repeat("#", (d.Count*histogramWidth+max-1)/max)
+		fmt.Fprintf(out, "%s %8d %10s %s\n", d.Day, d.Count, formatBytes(d.Bytes), bar)
+	}
+}
+
+// formatBytes formats n bytes using binary units, e.g. "1.5 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	activityCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to summarize")
+	activityCmd.PersistentFlags().StringVar(&since, "since", "", "indicate the first day (YYYY-MM-DD) to include")
+	activityCmd.PersistentFlags().StringVar(&until, "until", "", "indicate the last day (YYYY-MM-DD) to include")
+	activityCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "indicate an output format (text or json)")
+
+	rootCmd.AddCommand(activityCmd)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	jsonlRecord bool
+
+	// Commands
+	appendCmd = &cobra.Command{
+		Use:         "append",
+		Short:       "Append to an append blob",
+		Annotations: mutating,
+		Long: `Append to an append blob.
+
+The content is --blob-value followed by a newline, or standard input when it
+is piped, as with write. It is appended to the append blob --blob-key, which
+is created first if it doesn't exist, so log lines can be accumulated across
+invocations without reading the blob back.
+
+Content over 4 MiB is appended in several blocks, each only at the position
+the previous one ended, so that concurrent writers can't interleave with it.
+If another writer appends in between, the command fails after reporting how
+much was appended.
+
+With --jsonl-record, the content must be a single JSON value, which is
+appended on one line, so that the blob builds up a JSON Lines log. Invalid
+JSON is rejected before anything is appended, and the record is appended in
+a single block, so it is never interleaved with other records.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			// Take the content from --blob-value or else from piped input
+			var src io.Reader
+			if blobValue != "" {
+				src = strings.NewReader(blobValue + "\n")
+			} else {
+				in := cmd.InOrStdin()
+				if f, ok := in.(*os.File); ok && isTerminal(f) {
+					return fmt.Errorf(`flag "--blob-value" should be set`)
+				}
+				src = in
+			}
+
+			if jsonlRecord {
+				record, err := readJSONLRecord(src)
+				if err != nil {
+					return err
+				}
+				src = bytes.NewReader(record)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			appendBlobURL := newBlobURL(containerName, blobKey).ToAppendBlobURL()
+
+			// Create the blob unless it exists
+			_, err := appendBlobURL.Create(ctx, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{
+				ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny},
+			}, nil, azblob.ClientProvidedKeyOptions{})
+			if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists {
+				err = nil
+			}
+			if err != nil {
+				return err
+			}
+
+			n, err := appendBlocks(appendBlobURL, src)
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully appended %s to %q", formatBytes(n), blobKey))
+			return nil
+		},
+	}
+)
+
+// readJSONLRecord reads a single JSON value from src and returns it on one
+// line, followed by a newline, checking that it fits in one append block.
+func readJSONLRecord(src io.Reader) ([]byte, error) {
+	content, err := ioutil.ReadAll(io.LimitReader(src, azblob.AppendBlobMaxAppendBlockBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(content) {
+		return nil, fmt.Errorf("the record to append should be a single valid JSON value")
+	}
+
+	var record bytes.Buffer
+	if err := json.Compact(&record, content); err != nil {
+		return nil, err
+	}
+	record.WriteByte('\n')
+	if record.Len() > azblob.AppendBlobMaxAppendBlockBytes {
+		return nil, fmt.Errorf("the record to append is over the limit of %s of an append block", formatBytes(azblob.AppendBlobMaxAppendBlockBytes))
+	}
+	return record.Bytes(), nil
+}
+
+// appendBlocks appends the content of src to the append blob in blocks of
+// at most azblob.AppendBlobMaxAppendBlockBytes and returns the number of
+// bytes appended. Every block after the first is only appended where the
+// previous one ended.
+func appendBlocks(appendBlobURL azblob.AppendBlobURL, src io.Reader) (int64, error) {
+	var (
+		n   int64
+		pos int64 // 0 leaves the position of the first block unchecked
+	)
+	buf := make([]byte, azblob.AppendBlobMaxAppendBlockBytes)
+	for {
+		m, err := io.ReadFull(src, buf)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return n, err
+		}
+
+		resp, aerr := appendBlobURL.AppendBlock(ctx, bytes.NewReader(buf[:m]), azblob.AppendBlobAccessConditions{
+			AppendPositionAccessConditions: azblob.AppendPositionAccessConditions{IfAppendPositionEqual: pos},
+		}, nil, azblob.ClientProvidedKeyOptions{})
+		if serr, ok := aerr.(azblob.StorageError); ok {
+			switch serr.ServiceCode() {
+			case azblob.ServiceCodeAppendPositionConditionNotMet:
+				return n, fmt.Errorf("another writer appended to %q after %s of the content was appended, the rest was not appended", blobKey, formatBytes(n))
+			case azblob.ServiceCodeInvalidBlobType:
+				return n, fmt.Errorf("blob %q is not an append blob", blobKey)
+			}
+		}
+		if aerr != nil {
+			return n, aerr
+		}
+		n += int64(m)
+
+		offset, perr := strconv.ParseInt(resp.BlobAppendOffset(), 10, 64)
+		if perr != nil {
+			return n, fmt.Errorf("invalid append offset %q: %v", resp.BlobAppendOffset(), perr)
+		}
+		pos = offset + int64(m)
+
+		if err == io.ErrUnexpectedEOF {
+			return n, nil
+		}
+	}
+}
+
+func init() {
+	appendCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to append to")
+	appendCmd.PersistentFlags().StringVar(&blobValue, "blob-value", "", "indicate a value to append, followed by a newline")
+	appendCmd.PersistentFlags().BoolVar(&jsonlRecord, "jsonl-record", false, "append the content as a single-line JSON record, rejecting invalid JSON")
+
+	rootCmd.AddCommand(appendCmd)
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestAppendJSONLRecord(t *testing.T) {
+	s := newFakeService(t, "test")
+	for _, record := range []string{`{"event": "start"}`, "{\n  \"event\": \"stop\"\n}"} {
+		if _, _, err := executeFake(t, s, "append", "--blob-key", "log.jsonl", "--blob-value", record, "--jsonl-record"); err != nil {
+			t.Fatalf("append --jsonl-record %q: %v", record, err)
+		}
+	}
+
+	_, _, err := executeFake(t, s, "append", "--blob-key", "log.jsonl", "--blob-value", `{"event": `, "--jsonl-record")
+	if err == nil {
+		t.Error("append --jsonl-record of invalid JSON succeeded")
+	}
+
+	b := s.blob("test", "log.jsonl")
+	if b == nil {
+		t.Fatal("append --jsonl-record created no blob")
+	}
+	if got, want := string(b.content), "{\"event\":\"start\"}\n{\"event\":\"stop\"}\n"; got != want {
+		t.Errorf("blob has %q, want %q", got, want)
+	}
+}
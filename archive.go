@@ -0,0 +1,360 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	localDir string
+	gzipped  bool
+
+	// Commands
+	archiveDirCmd = &cobra.Command{
+		Use:         "archive-dir",
+		Short:       "Upload a local directory as a single tar blob",
+		Annotations: mutating,
+		Long: `Upload a local directory as a single tar blob.
+
+The directory --local-dir is archived with tar, and compressed with gzip if
+--gzip is set, while it is uploaded to --blob-key, so no temporary file is
+written. Regular files, directories and symbolic links are archived, with
+paths relative to --local-dir; other special files are skipped. The blob
+gets the content type application/x-tar, or application/gzip with --gzip.
+If archiving fails, nothing is written.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if localDir == "" {
+				return fmt.Errorf(`flag "--local-dir" should be set`)
+			}
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			n, err := archiveDir(ctx, bucket, blobKey, localDir, gzipped, errOut)
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully archived %d entries of %q to %q", n, localDir, blobKey))
+			return nil
+		},
+	}
+
+	extractBlobCmd = &cobra.Command{
+		Use:   "extract-blob",
+		Short: "Extract a tar blob into a local directory",
+		Long: `Extract a tar blob into a local directory.
+
+The tar archive at --blob-key, gzipped or not, is downloaded and extracted
+into --local-dir, which is created if needed, the way archive-dir stores
+directories. Directories, regular files and symbolic links are restored
+with their modes; other entries are skipped. Entries, or symbolic link
+targets, that would end up outside of --local-dir are rejected and stop the
+extraction. Existing files are overwritten.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if localDir == "" {
+				return fmt.Errorf(`flag "--local-dir" should be set`)
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			r, err := bucket.NewReader(ctx, blobKey, nil)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			n, err := extractTar(r, localDir, errOut)
+			if err != nil {
+				return fmt.Errorf("extracting %q: %v", blobKey, err)
+			}
+
+			logger.Info(fmt.Sprintf("Successfully extracted %d files of %q to %q", n, blobKey, localDir))
+			return nil
+		},
+	}
+)
+
+// archiveDir uploads dir to key in b as a tar archive, gzipped if gz is set,
+// and returns the number of archived entries. Skipped files are reported to
+// errOut.
+func archiveDir(ctx context.Context, b *blob.Bucket, key, dir string, gz bool, errOut io.Writer) (int, error) {
+	contentType := "application/x-tar"
+	if gz {
+		contentType = "application/gzip"
+	}
+
+	// Cancelling the writer's context before Close aborts the upload.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := b.NewWriter(wctx, key, &blob.WriterOptions{ContentType: contentType})
+	if err != nil {
+		return 0, err
+	}
+
+	var zw *gzip.Writer
+	dst := io.Writer(w)
+	if gz {
+		zw = gzip.NewWriter(w)
+		dst = zw
+	}
+	tw := tar.NewWriter(dst)
+
+	n, err := writeTar(tw, dir, errOut)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		cancel()
+		w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
+
+// writeTar writes the entries of dir to tw and returns their number.
+func writeTar(tw *tar.Writer, dir string, errOut io.Writer) (int, error) {
+	n := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		var link string
+		switch {
+		case info.Mode().IsRegular(), info.IsDir():
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintf(errOut, "Skipping %q: not a regular file, directory or symbolic link\n", path)
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		n++
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	return n, err
+}
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// extractTar extracts the tar archive read from r, gzipped or not, into dir
+// and returns the number of regular files extracted. Skipped entries are
+// reported to errOut.
+func extractTar(r io.Reader, dir string, errOut io.Writer) (int, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		path, ok := extractPath(dir, hdr.Name)
+		if !ok {
+			return n, fmt.Errorf("entry %q escapes %q", hdr.Name, dir)
+		}
+		mode := hdr.FileInfo().Mode().Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := checkNoSymlinks(dir, path); err != nil {
+				return n, err
+			}
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return n, err
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				return n, err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := checkNoSymlinks(dir, filepath.Dir(path)); err != nil {
+				return n, err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return n, err
+			}
+			if err := extractFile(path, tr, mode); err != nil {
+				return n, err
+			}
+			n++
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) {
+				return n, fmt.Errorf("symbolic link %q points to absolute path %q", hdr.Name, hdr.Linkname)
+			}
+			if _, ok := extractPath(dir, filepath.Join(filepath.Dir(hdr.Name), hdr.Linkname)); !ok {
+				return n, fmt.Errorf("symbolic link %q points outside of %q", hdr.Name, dir)
+			}
+			if err := checkNoSymlinks(dir, filepath.Dir(path)); err != nil {
+				return n, err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return n, err
+			}
+			os.Remove(path)
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return n, err
+			}
+		default:
+			fmt.Fprintf(errOut, "Skipping %q: unsupported entry type %q\n", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// extractPath returns the path name extracts to under dir, and false if it
+// would end up outside of dir.
+func extractPath(dir, name string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return "", false
+	}
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Join(dir, rel), true
+}
+
+// checkNoSymlinks returns an error if path, or any directory between dir and
+// path, exists as a symbolic link. Entries are never extracted through links,
+// since a chain of links, each pointing inside dir on its own, can resolve
+// outside of it.
+func checkNoSymlinks(dir, path string) error {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	cur := dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		fi, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%q is a symbolic link, not extracting through it", cur)
+		}
+	}
+	return nil
+}
+
+// extractFile writes the content read from r to the file at path.
+func extractFile(path string, r io.Reader, mode os.FileMode) error {
+	// Replace symbolic links instead of writing through them.
+	os.Remove(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	archiveDirCmd.PersistentFlags().StringVar(&localDir, "local-dir", "", "indicate a local directory to archive")
+	archiveDirCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to upload the archive to")
+	archiveDirCmd.PersistentFlags().BoolVar(&gzipped, "gzip", false, "compress the archive with gzip")
+
+	extractBlobCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a tar blob key to extract")
+	extractBlobCmd.PersistentFlags().StringVar(&localDir, "local-dir", "", "indicate a local directory to extract into")
+
+	rootCmd.AddCommand(archiveDirCmd)
+	rootCmd.AddCommand(extractBlobCmd)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractTarSymlinkChain(t *testing.T) {
+	// Each link points inside dir on its own, but a/b/l resolves to the
+	// parent of dir through a -> .
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []tar.Header{
+		{Name: "a", Typeflag: tar.TypeSymlink, Linkname: ".", Mode: 0777},
+		{Name: "b/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "a/b/l", Typeflag: tar.TypeSymlink, Linkname: "../..", Mode: 0777},
+		{Name: "a/b/l/evil.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+	}
+	for _, hdr := range entries {
+		hdr := hdr
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte("evil")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	base := t.TempDir()
+	dir := filepath.Join(base, "dir")
+	if _, err := extractTar(&buf, dir, ioutil.Discard); err == nil {
+		t.Error("extractTar succeeded, want an error for extracting through a symbolic link")
+	}
+	if _, err := os.Stat(filepath.Join(base, "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("evil.txt was written outside of %q", dir)
+	}
+}
+
+func TestExtractTarRegularEntries(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []tar.Header{
+		{Name: "d/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "d/f.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 2},
+		{Name: "d/link", Typeflag: tar.TypeSymlink, Linkname: "f.txt", Mode: 0777},
+	} {
+		hdr := hdr
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte("hi"))
+		}
+	}
+	tw.Close()
+
+	dir := t.TempDir()
+	n, err := extractTar(&buf, dir, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("extracted %d files, want 1", n)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "d", "link"))
+	if err != nil || string(data) != "hi" {
+		t.Errorf("reading through d/link = %q, %v, want %q", data, err, "hi")
+	}
+}
@@ -1,224 +1,1047 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/noprysk-ua/azure/blobstore"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"gocloud.dev/blob"
 	"gocloud.dev/blob/azureblob"
 )
 
+// The account used when AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY are not
+// set. The placeholders must be replaced to use the constants.
 const (
-	accountName azureblob.AccountName = "ENTER_YOUR_ACCOUNT_NAME"
-	accountKey  azureblob.AccountKey  = "ENTER_YOUR_KEY"
+	defaultAccountName azureblob.AccountName = "ENTER_YOUR_ACCOUNT_NAME"
+	defaultAccountKey  azureblob.AccountKey  = "ENTER_YOUR_KEY"
 )
 
 var (
 	// Global variables
-	ctx        context.Context
-	credential *azblob.SharedKeyCredential
-	pline      pipeline.Pipeline
+	accountName     azureblob.AccountName
+	accountKey      azureblob.AccountKey
+	storageProtocol azureblob.Protocol      = "https"
+	storageDomain   azureblob.StorageDomain = "blob." + defaultEndpointSuffix
+	ctx             context.Context
+	cancelTimeout   context.CancelFunc
+	credential      *azblob.SharedKeyCredential
+	pline           pipeline.Pipeline
 
 	// Flags
-	containerName string
-	blobKey       string
-	blobValue     string
-	blobPrefix    string
+	accountNameFlag  string
+	accountKeyFlag   string
+	connectionString string
+	sasToken         string
+	containerName    string
+	blobKey          string
+	blobValue        string
+	blobPrefix       string
+	prefixesFile     string
+	stateFile        string
+	shards           int
+	asciiKeys        bool
+	incremental      bool
+	validateOnly     bool
+	keyFromHash      bool
+	keyExtension     string
+	assumeYes        bool
+	readOnly         bool
+	asEnv            bool
+	readOffset       int64
+	readLength       int64
+	noColor          bool
+	urls             bool
+	noMD5            bool
+	strict           bool
+	exitZeroOnEmpty  bool
+	maxResults       int
+	noRecurse        bool
+	includeDeleted   bool
+	sign             bool
+	expiry           time.Duration
+	timeout          time.Duration
+	ifNotExists      bool
+	noOverwrite      bool
+	ifMatch          string
 
 	// Commands
 	rootCmd = &cobra.Command{
 		Use:   "azure",
 		Short: "Interact with azure using the azure CLI",
+		// Errors are printed by main, and the usage only for invalid flags
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Flags are valid at this point, so don't print the usage on errors
+			cmd.SilenceUsage = true
+			if err := initLogger(cmd.ErrOrStderr()); err != nil {
+				return err
+			}
+
+			// Completion requests configure the account only when completing
+			// values that need it, see completionContext
+			if cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+				return nil
+			}
+
+			// Bound every command by --timeout, if set
+			if timeout < 0 {
+				return fmt.Errorf(`flag "--timeout" should not be negative`)
+			}
+			if timeout > 0 {
+				ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+			}
+
+			if err := resolveAccount(cmd); err != nil {
+				return err
+			}
+			if err := checkAuthMode(); err != nil {
+				return err
+			}
+			if err := checkAccount(); err != nil {
+				return err
+			}
+			if err := checkContainer(cmd); err != nil {
+				return err
+			}
+			if err := checkRetryOptions(); err != nil {
+				return err
+			}
+			if err := initPipeline(); err != nil {
+				return err
+			}
+			// Dry runs of mutating commands don't write, even in read-only mode
+			if !isDryRunCommand(cmd) {
+				if err := checkCommandWritable(cmd); err != nil {
+					return err
+				}
+			}
+			if err := checkRetryStatusCodes(); err != nil {
+				return err
+			}
+			if err := checkConcurrency(); err != nil {
+				return err
+			}
+			return nil
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if cancelTimeout != nil {
+				cancelTimeout()
+			}
+		},
 	}
 
 	createContainerCmd = &cobra.Command{
-		Use:   "create-container",
-		Short: "Create an azure container",
-		Run: func(cmd *cobra.Command, args []string) {
-			// From the Azure portal, get your storage account blob service URL endpoint.
-			URL, _ := url.Parse(
-				fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, containerName))
-
-			// Create a ContainerURL object that wraps the container URL and a request
-			// pipeline to make requests.
-			containerURL := azblob.NewContainerURL(*URL, pline)
-			fmt.Printf("Creating a container named %q\n", containerName)
-			_, err := containerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+		Use:         "create-container",
+		Short:       "Create an azure container",
+		Annotations: mutating,
+		Long: `Create an azure container.
+
+The container is private unless --public-access is set to blob, to allow
+anonymous reads of blobs, or container, to also allow anonymous listing.
+
+The command fails if the container already exists, unless --if-not-exists
+is set, so that provisioning scripts can be run repeatedly.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			access, err := parsePublicAccess(publicAccess)
+			if err != nil {
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			client, release, err := getClient(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			logger.Info(fmt.Sprintf("Creating a container named %q", containerName))
+			err = client.CreateContainerWithAccess(ctx, access)
+			if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeContainerAlreadyExists && ifNotExists {
+				logger.Info(fmt.Sprintf("Container %q already exists", containerName))
+				return nil
+			}
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 
-			fmt.Printf("Successfully created container %q\n", containerName)
+			logger.Info(fmt.Sprintf("Successfully created container %q", containerName))
+			return nil
 		},
 	}
 
 	deleteContainerCmd = &cobra.Command{
-		Use:   "delete-container",
-		Short: "Delete an azure container",
-		Run: func(cmd *cobra.Command, args []string) {
-			// From the Azure portal, get your storage account blob service URL endpoint.
-			URL, _ := url.Parse(
-				fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, containerName))
-
-			// Create a ContainerURL object that wraps the container URL and a request
-			// pipeline to make requests.
-			containerURL := azblob.NewContainerURL(*URL, pline)
-			fmt.Printf("Deleting a container named %q\n", containerName)
-			_, err := containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
+		Use:         "delete-container",
+		Short:       "Delete an azure container",
+		Annotations: mutating,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			client, release, err := getClient(ctx)
 			if err != nil {
-				log.Fatal(err)
+				return err
+			}
+			defer release()
+
+			logger.Info(fmt.Sprintf("Deleting a container named %q", containerName))
+			if err := client.DeleteContainer(ctx); err != nil {
+				return err
 			}
 
-			fmt.Printf("Successfully deleted container %q\n", containerName)
+			logger.Info(fmt.Sprintf("Successfully deleted container %q", containerName))
+			return nil
 		},
 	}
 
 	writeCmd = &cobra.Command{
-		Use:   "write",
-		Short: "Write to a blob",
-		Run: func(cmd *cobra.Command, args []string) {
+		Use:         "write",
+		Short:       "Write to a blob",
+		Annotations: mutating,
+		Long: `Write to a blob.
+
+The content is --blob-value followed by a newline. If --blob-value is not
+set, the content is read from standard input instead when it is piped, e.g.
+"cat data | azure write --blob-key foo", and written as-is. Piped input is
+streamed, so no Content-MD5 is stored for it, unless --key-from-hash or
+--validate-only is set, which need the whole content up front.
+
+With --buffer-to-temp-file, piped input is first copied to a temporary file
+instead, so that its size and Content-MD5 are known before the upload and
+the blocks are sized to fit the whole content. This trades disk space for
+large inputs; the file is removed once the write is done.
+
+--content-type, --content-encoding and --cache-control set the matching
+headers and are left to the service defaults if empty. With --encryption-key,
+the blob is encrypted with that customer-provided key. With --gzip, the
+content is compressed with gzip as it is written and stored with
+Content-Encoding gzip, so that read decompresses it.
+
+With --no-overwrite, the write fails if the blob already exists. With
+--if-match, it fails unless the blob still has that ETag, as printed by
+stat, so that concurrent updates are not lost. A failed condition exits with
+status 3, other failures with status 1.
+
+--meta NAME=VALUE and --tag KEY=VALUE, which can be repeated, store metadata
+and index tags with the blob, as set-metadata and tag-blob do. They can
+also be loaded from JSON files with --meta-file and --tags-file, whose
+pairs the inline flags take precedence over.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
 			// Check if valid flags
-			if blobKey == "" {
-				log.Fatal(fmt.Errorf(`flag "--blob-key" should be set`))
+			if keyFromHash && blobKey != "" {
+				return fmt.Errorf(`flag "--blob-key" cannot be combined with "--key-from-hash"`)
 			}
 
-			if blobValue == "" {
-				log.Fatal(fmt.Errorf(`flag "--blob-value" should be set`))
+			if blobKey == "" && !keyFromHash {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
 			}
-
-			// Create a *blob.Bucket.
-			// The credential Option is required if you're going to use blob.SignedURL.
-			bucket, err := azureblob.OpenBucket(ctx, pline, accountName, containerName,
-				&azureblob.Options{Credential: credential})
+			if gzipped && uploadEncoding != "" && uploadEncoding != "gzip" {
+				return fmt.Errorf(`flag "--gzip" cannot be combined with "--content-encoding" %q`, uploadEncoding)
+			}
+			if noOverwrite && ifMatch != "" {
+				return fmt.Errorf(`flag "--no-overwrite" cannot be combined with "--if-match"`)
+			}
+			if bufferToTempFile && blobValue != "" {
+				return fmt.Errorf(`flag "--buffer-to-temp-file" cannot be combined with "--blob-value"`)
+			}
+			metadata, err := metadataOfFlags()
 			if err != nil {
-				log.Fatal(err)
+				return err
+			}
+			tags, err := tagsOfFlags()
+			if err != nil {
+				return err
 			}
-			defer bucket.Close()
 
-			// Write
-			w, err := bucket.NewWriter(ctx, blobKey, nil)
+			// Send the customer-provided key, if any, with the requests
+			ctx, err := encryptionContext(ctx)
 			if err != nil {
-				log.Fatal(err)
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			// Take the content from --blob-value or else from piped input
+			var (
+				content []byte
+				stdin   io.Reader
+			)
+			if blobValue != "" {
+				// The value is written with a trailing newline.
+				content = []byte(blobValue + "\n")
+			} else {
+				in := cmd.InOrStdin()
+				if f, ok := in.(*os.File); ok && isTerminal(f) {
+					return fmt.Errorf(`flag "--blob-value" should be set`)
+				}
+				stdin = in
+
+				if keyFromHash || validateOnly {
+					// The whole content is needed up front.
+					var err error
+					if content, err = ioutil.ReadAll(stdin); err != nil {
+						return err
+					}
+					stdin = nil
+				}
+			}
+
+			if keyFromHash {
+				blobKey = contentKey(blobPrefix, content, keyExtension)
+				fmt.Fprintln(out, blobKey)
 			}
 
-			_, err = fmt.Fprintln(w, blobValue)
+			if gzipped {
+				uploadEncoding = "gzip"
+				if stdin == nil {
+					var buf bytes.Buffer
+					zw := gzip.NewWriter(&buf)
+					zw.Write(content)
+					if err := zw.Close(); err != nil {
+						return err
+					}
+					content = buf.Bytes()
+				} else {
+					zr := gzipReader(stdin)
+					defer zr.Close()
+					stdin = zr
+				}
+			}
+
+			if validateOnly {
+				if err := validateWrite(ctx, out, blobKey, content); err != nil {
+					return err
+				}
+
+				logger.Info(fmt.Sprintf("Successfully validated writing to %q", blobKey))
+				return nil
+			}
+
+			client, release, err := getClient(ctx)
 			if err != nil {
-				log.Fatal(err)
+				return err
+			}
+			defer release()
+
+			// Write
+			// An empty content type lets the content type be detected.
+			opts := &blob.WriterOptions{
+				ContentType:     uploadContentType,
+				ContentEncoding: uploadEncoding,
+				CacheControl:    uploadCacheControl,
+			}
+			if stdin == nil && !noMD5 {
+				sum := md5.Sum(content)
+				opts.ContentMD5 = sum[:]
+			}
+			for name, value := range metadata {
+				if value != "" {
+					if opts.Metadata == nil {
+						opts.Metadata = make(map[string]string)
+					}
+					opts.Metadata[strings.ToLower(name)] = value
+				}
 			}
+			if noOverwrite || ifMatch != "" || len(tags) > 0 {
+				opts.BeforeWrite = func(as func(interface{}) bool) error {
+					var uploadOpts *azblob.UploadStreamToBlockBlobOptions
+					if as(&uploadOpts) {
+						uploadOpts.AccessConditions.ModifiedAccessConditions = writeConditions()
+						uploadOpts.BlobTagsMap = tags
+					}
+					return nil
+				}
+			}
+
+			src := stdin
+			if src == nil {
+				src = bytes.NewReader(content)
+			} else if bufferToTempFile {
+				f, size, sum, cleanup, err := spoolToTempFile(stdin)
+				if err != nil {
+					return err
+				}
+				defer cleanup()
 
-			err = w.Close()
+				if !noMD5 {
+					opts.ContentMD5 = sum
+				}
+				opts.BufferSize = int(blockSizeFor(size))
+				src = f
+			}
+			res, err := client.Write(ctx, blobKey, src, opts)
 			if err != nil {
-				log.Fatal(err)
+				if err := writeConditionError(blobKey, err); err != nil {
+					logger.Error(err.Error())
+					return &exitError{Code: 3}
+				}
+				return err
 			}
 
-			fmt.Printf("Successfully written %q to %q\n", blobValue, blobKey)
+			if blobValue == "" {
+				logger.Info(fmt.Sprintf("Successfully written %s from stdin to %q", formatBytes(res.Size), blobKey))
+				return nil
+			}
+			logger.Info(fmt.Sprintf("Successfully written %q to %q", blobValue, blobKey))
+			return nil
 		},
 	}
 
 	readCmd = &cobra.Command{
 		Use:   "read",
 		Short: "Read from a blob",
-		Run: func(cmd *cobra.Command, args []string) {
+		Long: `Read from a blob.
+
+The content of the blob is printed to stdout as-is, while its Content-Type
+and the success message are printed to stderr, unless --quiet is set.
+
+With --as-env, the blob is parsed as KEY=VALUE lines and printed as shell
+export statements, so that configuration stored in a blob can be loaded
+with:
+
+  eval "$(azure read --blob-key config.env --as-env)"
+
+Blank lines and lines starting with "#" are skipped. Nothing is printed if
+any line is invalid.
+
+With --grep, --head or --tail, only the selected lines of the blob are
+printed, without the Content-Type and success message, while the blob is
+streamed:
+
+  azure read --blob-key app.log --grep ERROR --head 100
+
+--grep keeps the lines matching a regular expression, --head keeps the
+first lines left and stops downloading the blob, and --tail keeps the last
+lines left. Combining --head and --tail keeps the last --tail lines of the
+first --head.
+
+With --offset and --length, only that byte range of the blob is read. A
+--length of -1, the default, reads to the end of the blob. --range takes
+the range relative to the size of the blob instead, as "start-end" (end
+inclusive), "start-" or "-n" for the last n bytes, with bounds in bytes,
+KB, MB or GB (of 1024 bytes) or percent:
+
+  azure read --blob-key app.log --range -1KB
+  azure read --blob-key app.log --range 50%-
+
+With --snapshot, the snapshot of the blob taken at that timestamp, as
+printed by snapshot-blob, is read instead. Blobs written with
+--encryption-key can only be read with the same --encryption-key.
+
+Blobs stored with Content-Encoding gzip, e.g. written with --gzip, deflate
+or br, as pre-compressed web assets often are, are decompressed unless
+--raw is set or only a byte range is read. Other encodings are an error.
+
+With --default, a blob that doesn't exist reads as the given value followed
+by a newline, printed without the Content-Type, and the command succeeds,
+so that scripts can read optional settings:
+
+  timeout=$(azure read --blob-key settings/timeout --default 30)
+
+Any other error, such as a missing container or a failed authorization,
+still fails the command.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
 			// Check if valid flags
 			if blobKey == "" {
-				log.Fatal(fmt.Errorf(`flag "--blob-key" should be set`))
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if headLines < 0 || tailLines < 0 {
+				return fmt.Errorf(`flags "--head" and "--tail" should not be negative`)
+			}
+			var re *regexp.Regexp
+			if grepPattern != "" {
+				var err error
+				if re, err = regexp.Compile(grepPattern); err != nil {
+					return fmt.Errorf(`flag "--grep" should be a regular expression: %v`, err)
+				}
+			}
+			if err := checkSnapshot(readSnapshot); err != nil {
+				return err
+			}
+			if readOffset < 0 {
+				return fmt.Errorf(`flag "--offset" should not be negative`)
+			}
+			if readLength != -1 && readLength <= 0 {
+				return fmt.Errorf(`flag "--length" should be positive, or -1 to read to the end`)
+			}
+			if readRange != "" && (cmd.Flags().Changed("offset") || cmd.Flags().Changed("length")) {
+				return fmt.Errorf(`flag "--range" cannot be combined with "--offset" or "--length"`)
+			}
+			if asEnv && hasLineFilters() {
+				return fmt.Errorf(`flag "--as-env" cannot be combined with "--grep", "--head" or "--tail"`)
+			}
+
+			// Send the customer-provided key, if any, with the requests
+			ctx, err := encryptionContext(ctx)
+			if err != nil {
+				return err
 			}
 
-			// Create a *blob.Bucket.
-			// The credential Option is required if you're going to use blob.SignedURL.
-			bucket, err := azureblob.OpenBucket(ctx, pline, accountName, containerName,
-				&azureblob.Options{Credential: credential})
+			client, release, err := getClient(ctx)
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
-			defer bucket.Close()
+			defer release()
 
-			// Open the key blobKey for reading with the default options.
-			r, err := bucket.NewReader(ctx, blobKey, nil)
+			// Open the key blobKey, or its snapshot --snapshot, for reading
+			// --length bytes from --offset, or --range (the whole blob by
+			// default).
+			offset, length, err := readRangeOf(ctx, client, blobKey, readSnapshot)
+			var r *blob.Reader
+			if err == nil {
+				r, err = client.NewSnapshotRangeReader(ctx, blobKey, readSnapshot, offset, length)
+			}
 			if err != nil {
-				log.Fatal(err)
+				// A missing blob reads as --default, if set, but other
+				// errors still fail
+				if cmd.Flags().Changed("default") && isBlobNotFound(err) {
+					return writeReadDefault(out, re)
+				}
+				return readError(blobKey, err)
 			}
 			defer r.Close()
 
-			// Readers also have a limited view of the blob's metadata.
-			fmt.Println("Content-Type:", r.ContentType())
-			fmt.Println()
+			// Whole blobs are verified against their stored MD5 and
+			// decompressed if gzip encoded.
+			var src io.Reader = r
+			if offset == 0 && length == -1 {
+				if src, err = decodedReader(r); err != nil {
+					return fmt.Errorf("reading %q: %v", blobKey, err)
+				}
+			}
+
+			if asEnv {
+				// Print only the exports, so the output can be evaluated.
+				if err := writeEnvExports(out, src); err != nil {
+					return fmt.Errorf("reading %q as environment: %v", blobKey, err)
+				}
+				return nil
+			}
+
+			if hasLineFilters() {
+				// Print only the lines, so the output can be piped.
+				if err := filterLines(out, src, re, headLines, tailLines); err != nil {
+					return fmt.Errorf("reading %q: %v", blobKey, err)
+				}
+				return nil
+			}
+
+			// Readers also have a limited view of the blob's metadata,
+			// which is logged so that stdout only carries the content.
+			logger.Info(fmt.Sprintf("Content-Type: %s", r.ContentType()))
 			// Copy from the reader to stdout.
-			if _, err := io.Copy(os.Stdout, r); err != nil {
-				log.Fatal(err)
+			if _, err := io.Copy(out, src); err != nil {
+				return err
 			}
 
-			fmt.Printf("Successfully read from %q\n", blobKey)
+			logger.Info(fmt.Sprintf("Successfully read from %q", blobKey))
+			return nil
 		},
 	}
 
 	listCmd = &cobra.Command{
-		Use:   "list",
+		Use:   "list [prefix]",
 		Short: "List from a blob with (or without) a prefix",
-		Run: func(cmd *cobra.Command, args []string) {
-			// Create a *blob.Bucket.
-			// The credential Option is required if you're going to use blob.SignedURL.
-			bucket, err := azureblob.OpenBucket(ctx, pline, accountName, containerName,
-				&azureblob.Options{Credential: credential})
+		Long: `List from a blob with (or without) a prefix.
+
+The prefix can be given either as an argument or with --blob-prefix. A
+prefix without a trailing "/" that names a pseudo-directory is treated as
+that directory, so "list logs/2024" lists the contents of "logs/2024/".
+Prefixes that do not name a directory are used as-is and match any key
+starting with them.
+
+With --shards, the container is listed flat and the keyspace is split by
+the first character after the prefix, listing the shards concurrently. This
+is much faster for huge containers, but keys are printed in no particular
+order. Keys whose first character after the prefix is not printable ASCII,
+such as a control or accented character, can't be split that way, so an
+extra shard lists every key under the prefix to find them, which takes as
+long as an unsharded listing. With --ascii-keys, that shard is skipped, for
+containers whose keys are known to be ASCII, and such keys are not listed.
+
+With --state-file, the container is listed flat and the position is saved
+after every page, so that an interrupted listing resumes where it stopped.
+Adding --incremental keeps the ETag of every key in the state file and only
+lists keys that are new or changed since the previous run.
+
+With --strict, the command exits with status 1 if nothing was listed (in
+incremental mode, if nothing changed). --exit-zero-on-empty always makes an
+empty listing succeed and takes precedence over --strict, so it can be
+added to a command line that has other strict settings.
+
+With --urls, the container is listed flat and the full https URL of every
+blob is printed instead of its key. Adding --sign prints signed URLs that
+grant read access until --expiry from now, e.g. to share download links.
+
+With --no-recurse, only the immediate level under the prefix is listed,
+without descending into directories. With --max-results, the listing stops
+after that many entries in total, across directories and prefixes, to peek
+into large containers.
+
+With --output json or csv, the listing is printed as a JSON array, or as
+CSV with a header row, of all entries with their full key, size,
+modification time and whether they are directories, without indentation.
+The JSON array ends with a {"summary": ...} object counting the files,
+directories and bytes listed.
+
+With --include-deleted, soft-deleted blobs are listed too, in red and
+marked with when they were deleted and how many days they can still be
+restored with undelete-blob. A blob that was deleted and written again is
+listed once live and once deleted.
+
+With --parallel-prefixes, the top level under the prefix is listed first
+and then every directory of it is listed concurrently, --concurrency at a
+time, which is much faster for containers spread over many directories.
+The output is the same as without it, in the same order, but each
+directory is held in memory until the ones before it are printed.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			if len(args) == 1 {
+				blobPrefix = args[0]
+			}
+
+			client, release, err := getClient(ctx)
 			if err != nil {
-				log.Fatal(err)
-			}
-			defer bucket.Close()
-
-			// Create a prefixed bucket
-			bucket = blob.PrefixedBucket(bucket, blobPrefix)
-			defer bucket.Close()
-
-			// list lists files in b starting with prefix. It uses the delimiter "/",
-			// and recurses into "directories", adding 2 spaces to indent each time.
-			// It will list the blobs created above because fileblob is strongly
-			// consistent, but is not guaranteed to work on all services.
-			var list func(context.Context, *blob.Bucket, string, string)
-			list = func(ctx context.Context, b *blob.Bucket, prefix, indent string) {
-				iter := b.List(&blob.ListOptions{
-					Delimiter: "/",
-					Prefix:    prefix,
-				})
-				for {
-					obj, err := iter.Next(ctx)
-					if err == io.EOF {
-						break
+				return err
+			}
+			defer release()
+			bucket := client.Bucket()
+
+			// Collect the prefixes to list
+			prefixes := []string{blobPrefix}
+			if prefixesFile != "" {
+				prefixes, err = readPrefixesFile(prefixesFile)
+				if err != nil {
+					return err
+				}
+			}
+
+			// list lists the entries under prefix, descending into directories
+			// unless --no-recurse is set. Entries are printed with their key
+			// relative to prefix, indented by 2 spaces per directory level after
+			// indent, or written to lw with their full key if set. Every entry
+			// listed is counted in stats. With --max-results, it returns
+			// errMaxResults once that many entries were listed in total. With
+			// --include-deleted, soft-deleted blobs are listed and marked.
+			var (
+				lw     *listWriter
+				listed int
+			)
+			listFn := client.List
+			if includeDeleted {
+				listFn = client.ListIncludingDeleted
+			}
+			list := func(prefix, indent string, stats *listStats) error {
+				listPrefix := func(fn func(obj *blob.ListObject, depth int) error) error {
+					return listFn(ctx, prefix, !noRecurse, fn)
+				}
+				if parallelPrefixes {
+					listPrefix = func(fn func(obj *blob.ListObject, depth int) error) error {
+						return listParallel(ctx, listFn, prefix, fn)
 					}
-					if err != nil {
-						log.Fatal(err)
+				}
+				return listPrefix(func(obj *blob.ListObject, depth int) error {
+					stats.add(obj)
+					if lw != nil {
+						if err := lw.write(obj); err != nil {
+							return err
+						}
+					} else {
+						key := strings.TrimPrefix(obj.Key, prefix)
+						if obj.IsDir {
+							key = colorize(out, colorBlue, key)
+						}
+						if deleted, props := blobstore.Deleted(obj); deleted {
+							key = colorize(out, colorRed, key+" (deleted"+deletedRetention(props)+")")
+						}
+						fmt.Fprintf(out, "%s%s%s\n", indent, strings.Repeat("  ", depth), key)
 					}
-					fmt.Printf("%s%s\n", indent, obj.Key)
-					if obj.IsDir {
-						list(ctx, b, obj.Key, indent+"  ")
+					if listed++; maxResults > 0 && listed >= maxResults {
+						return errMaxResults
 					}
+					return nil
+				})
+			}
+
+			if prefixesFile == "" && maxResults == 0 && !noRecurse {
+				warnFullScan(cmd.ErrOrStderr(), blobPrefix)
+			}
+
+			// Treat prefixes naming a pseudo-directory as that directory
+			for i, prefix := range prefixes {
+				prefixes[i], err = normalizePrefix(ctx, bucket, prefix)
+				if err != nil {
+					return err
+				}
+			}
+
+			if sign && !urls {
+				return fmt.Errorf(`flag "--sign" requires "--urls"`)
+			}
+			if sign && expiry <= 0 {
+				return fmt.Errorf(`flag "--expiry" should be positive`)
+			}
+			if sign {
+				if err := checkSharedKey(cmd.ErrOrStderr(), `signing the URLs of "--sign"`); err != nil {
+					return err
 				}
 			}
-			list(ctx, bucket, "", "")
 
-			fmt.Printf("Successfully listed from %q\n", blobPrefix)
+			if outputFormat != "text" && outputFormat != "json" && outputFormat != "csv" {
+				return fmt.Errorf(`flag "--output" should be one of "text", "json" or "csv"`)
+			}
+			if outputFormat != "text" && (urls || shards > 0 || stateFile != "") {
+				return fmt.Errorf(`flag "--output" cannot be combined with "--urls", "--shards" or "--state-file"`)
+			}
+			if maxResults < 0 {
+				return fmt.Errorf(`flag "--max-results" should not be negative`)
+			}
+			if includeDeleted && (outputFormat != "text" || urls || shards > 0 || stateFile != "") {
+				return fmt.Errorf(`flag "--include-deleted" cannot be combined with "--output", "--urls", "--shards" or "--state-file"`)
+			}
+			if parallelPrefixes && (noRecurse || urls || shards > 0 || stateFile != "") {
+				return fmt.Errorf(`flag "--parallel-prefixes" cannot be combined with "--no-recurse", "--urls", "--shards" or "--state-file"`)
+			}
+			if asciiKeys && shards == 0 {
+				return fmt.Errorf(`flag "--ascii-keys" can only be used with "--shards"`)
+			}
+			if (maxResults > 0 || noRecurse) && (urls || shards > 0 || stateFile != "") {
+				return fmt.Errorf(`flags "--max-results" and "--no-recurse" cannot be combined with "--urls", "--shards" or "--state-file"`)
+			}
+
+			if urls {
+				if shards > 0 || stateFile != "" {
+					return fmt.Errorf(`flag "--urls" cannot be combined with "--shards" or "--state-file"`)
+				}
+
+				var stats listStats
+				for _, prefix := range prefixes {
+					if err := listURLs(ctx, out, bucket, prefix, &stats); err != nil {
+						return err
+					}
+				}
+
+				printSummary(errOut, &stats)
+				if err := checkEmptyListing(errOut, &stats); err != nil {
+					return err
+				}
+				logger.Info(fmt.Sprintf("Successfully listed URLs from %d prefixes", len(prefixes)))
+				return nil
+			}
+
+			if shards > 0 {
+				if len(prefixes) != 1 {
+					return fmt.Errorf(`flag "--shards" cannot be combined with "--prefixes-file"`)
+				}
+
+				if stateFile != "" {
+					return fmt.Errorf(`flag "--shards" cannot be combined with "--state-file"`)
+				}
+
+				stats, err := listSharded(ctx, out, bucket, prefixes[0], shards, asciiKeys)
+				if err != nil {
+					return err
+				}
+
+				printSummary(errOut, stats)
+				if err := checkEmptyListing(errOut, stats); err != nil {
+					return err
+				}
+				logger.Info(fmt.Sprintf("Successfully listed from %q", prefixes[0]))
+				return nil
+			}
+
+			if incremental && stateFile == "" {
+				return fmt.Errorf(`flag "--incremental" requires "--state-file"`)
+			}
+
+			if stateFile != "" {
+				if len(prefixes) != 1 {
+					return fmt.Errorf(`flag "--state-file" cannot be combined with "--prefixes-file"`)
+				}
+
+				stats, err := listResumable(ctx, out, errOut, prefixes[0], stateFile, incremental)
+				if err != nil {
+					return err
+				}
+
+				printSummary(errOut, stats)
+				if err := checkEmptyListing(errOut, stats); err != nil {
+					return err
+				}
+				logger.Info(fmt.Sprintf("Successfully listed from %q", prefixes[0]))
+				return nil
+			}
+
+			if outputFormat != "text" {
+				// List the full keys from the bucket itself, as one flat result
+				lw, err = newListWriter(out, outputFormat)
+				if err != nil {
+					return err
+				}
+				var stats listStats
+				for _, prefix := range prefixes {
+					if err := list(prefix, "", &stats); err != nil {
+						if err == errMaxResults {
+							break
+						}
+						return err
+					}
+				}
+				if err := lw.close(&stats); err != nil {
+					return err
+				}
+
+				// Keep stdout parseable by leaving out the success message
+				printSummary(errOut, &stats)
+				return checkEmptyListing(errOut, &stats)
+			}
+
+			if len(prefixes) == 1 {
+				var stats listStats
+				if err := list(prefixes[0], "", &stats); err != nil && err != errMaxResults {
+					return err
+				}
+
+				printSummary(errOut, &stats)
+				if err := checkEmptyListing(errOut, &stats); err != nil {
+					return err
+				}
+				logger.Info(fmt.Sprintf("Successfully listed from %q", prefixes[0]))
+				return nil
+			}
+
+			var total listStats
+			for _, prefix := range prefixes {
+				fmt.Fprintf(out, "%s:\n", colorize(out, colorBlue, prefix))
+
+				var stats listStats
+				err := list(prefix, "  ", &stats)
+				if err != nil && err != errMaxResults {
+					return err
+				}
+
+				if !quiet {
+					fmt.Fprintf(errOut, "Listed %s from %q\n", &stats, prefix)
+				}
+				total.merge(&stats)
+				if err == errMaxResults {
+					break
+				}
+			}
+
+			printSummary(errOut, &total)
+			if err := checkEmptyListing(errOut, &total); err != nil {
+				return err
+			}
+			logger.Info(fmt.Sprintf("Successfully listed from %d prefixes", len(prefixes)))
+			return nil
 		},
 	}
 )
 
-// Execute executes the root command.
+// Execute executes the root command. It can be called several times in a
+// process, every call starting from the default flags.
+//
+// An interrupt or SIGTERM cancels ctx, so that requests in flight are
+// aborted and batch commands start no more items, and makes Execute return
+// an *exitError with status 130 once the command has returned.
 func Execute() error {
-	return rootCmd.Execute()
+	resetFlags(rootCmd)
+	totalRetries = 0
+	registerCompletionsOnce.Do(registerCompletions)
+	defer closeSharedBucket()
+
+	base := ctx
+	sigCtx, stop := signal.NotifyContext(base, os.Interrupt, syscall.SIGTERM)
+	ctx = sigCtx
+	defer func() {
+		stop()
+		ctx = base
+	}()
+
+	err := rootCmd.Execute()
+	// Commands such as wait-for report interrupts themselves
+	var exitErr *exitError
+	if sigCtx.Err() != nil && !(errors.As(err, &exitErr) && exitErr.Code == 130) {
+		logger.Warn("Interrupted, remaining operations were cancelled")
+		return &exitError{Code: 130}
+	}
+	return err
+}
+
+// writeConditions returns the conditions a write is made with, following
+// --no-overwrite and --if-match.
+func writeConditions() azblob.ModifiedAccessConditions {
+	if noOverwrite {
+		return azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny}
+	}
+	return azblob.ModifiedAccessConditions{IfMatch: azblob.ETag(ifMatch)}
+}
+
+// writeConditionError returns a clear error if err reports that the
+// conditions of writeConditions were not met, and nil otherwise.
+func writeConditionError(key string, err error) error {
+	var serr azblob.StorageError
+	if !errors.As(err, &serr) {
+		return nil
+	}
+	switch {
+	case noOverwrite && (serr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists || serr.ServiceCode() == azblob.ServiceCodeConditionNotMet):
+		return fmt.Errorf("blob %q already exists (unset \"--no-overwrite\" to overwrite it)", key)
+	case ifMatch != "" && serr.ServiceCode() == azblob.ServiceCodeConditionNotMet:
+		return fmt.Errorf("blob %q was modified, its ETag doesn't match %q anymore", key, ifMatch)
+	case ifMatch != "" && serr.ServiceCode() == azblob.ServiceCodeBlobNotFound:
+		return fmt.Errorf("blob %q doesn't exist, so its ETag doesn't match %q", key, ifMatch)
+	}
+	return nil
+}
+
+// resetFlags sets every flag of cmd and its subcommands back to its
+// default. Commands share the variables their flags are bound to, such as
+// blobKey, and flags only set their variable when given, so without this a
+// command executed after another in the same process would inherit the
+// values given to the other one.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		f.Changed = false
+		// Slice flags all default to empty, and can't parse their DefValue
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			v.Replace(nil)
+			return
+		}
+		f.Value.Set(f.DefValue)
+	}
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.Flags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// exitError makes the process exit with Code without printing anything.
+// Commands return it when their exit status reports a result, such as
+// differences being found, rather than a failure.
+type exitError struct {
+	Code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
 }
 
 func init() {
 	// Add flags
-	rootCmd.PersistentFlags().StringVar(&containerName, "container-name", "default-container-name", "indicate a name of the container")
+	rootCmd.PersistentFlags().StringVar(&accountNameFlag, "account-name", "", "indicate a storage account name (overrides AZURE_STORAGE_ACCOUNT)")
+	rootCmd.PersistentFlags().StringVar(&accountKeyFlag, "account-key", "", "indicate a storage account key (overrides AZURE_STORAGE_KEY)")
+	rootCmd.PersistentFlags().StringVar(&connectionString, "connection-string", "", "indicate a storage connection string, taking precedence over --account-name and --account-key (overrides AZURE_STORAGE_CONNECTION_STRING)")
+	rootCmd.PersistentFlags().StringVar(&endpointSuffix, "endpoint-suffix", defaultEndpointSuffix, "indicate the endpoint suffix of the Azure cloud, e.g. core.usgovcloudapi.net or core.chinacloudapi.cn")
+	rootCmd.PersistentFlags().StringVar(&serviceURLFlag, "service-url", "", "indicate the base URL of the blob service to use as is, e.g. http://127.0.0.1:10000/devstoreaccount1 for Azurite or a proxy")
+	rootCmd.PersistentFlags().BoolVar(&emulator, "emulator", false, "use the local Azurite emulator at http://127.0.0.1:10000/devstoreaccount1 with its well-known account")
+	rootCmd.PersistentFlags().StringVar(&authMode, "auth-mode", "key", "indicate how to authorize requests, with the account key (key) or an Azure AD service principal or managed identity (aad)")
+	rootCmd.PersistentFlags().StringVar(&sasToken, "sas-token", "", "indicate a SAS token to authorize requests with instead of the account key (overrides AZURE_STORAGE_SAS_TOKEN)")
+	rootCmd.PersistentFlags().StringVar(&containerName, "container-name", "", "indicate a name of the container (the container-name of the profile if empty)")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "refuse to run commands that modify the storage account (also set by AZURE_READ_ONLY=true)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print what commands that modify the storage account would do without doing it")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output even when writing to a terminal")
+	rootCmd.PersistentFlags().BoolVar(&noMD5, "no-md5", false, "do not compute and store the Content-MD5 of written blobs, for throughput at the cost of later integrity checks")
+	rootCmd.PersistentFlags().BoolVar(&noVerify, "no-verify", false, "do not check downloaded content against the stored Content-MD5, for blobs whose MD5 is wrong")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 4, "indicate a number of blobs batch commands process in parallel")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "indicate the lowest level of messages logged to stderr (debug, info, warn or error)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "indicate the format of messages logged to stderr (text or json)")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "do not log success and confirmation messages, only warnings and errors")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "log HTTP requests and responses to stderr, with signatures and keys redacted")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "indicate how long a command may run before it is aborted, e.g. how long wait commands wait (0 never aborts)")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 3, "indicate how many times a failed request is retried (0 fails fast)")
+	rootCmd.PersistentFlags().IntVar(&maxTotalRetries, "max-total-retries", 0, "indicate how many retries all requests of a command may make together before a batch command stops (0 is no limit)")
+	rootCmd.PersistentFlags().DurationVar(&retryDelay, "retry-delay", 4*time.Second, "indicate a delay before the first retry, doubling with every further retry")
+	rootCmd.PersistentFlags().DurationVar(&maxRetryDelay, "max-retry-delay", 2*time.Minute, "indicate a maximum delay between retries")
+	rootCmd.PersistentFlags().DurationVar(&throttleBackoff, "throttle-backoff", time.Second, "indicate a delay before a throttled request is retried, doubling with every further retry unless the service asks for longer")
+	rootCmd.PersistentFlags().IntSliceVar(&retryStatusCodes, "retry-status-codes", nil, "indicate comma-separated HTTP statuses to retry in addition to the default 429, 500, 502 and 503 (e.g. 408,504), or to stop retrying when negated (e.g. -503)")
 	writeCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key for writing")
 	writeCmd.PersistentFlags().StringVar(&blobValue, "blob-value", "", "indicate a value you want to write to a given blob-key")
-	readCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key for writing")
+	writeCmd.PersistentFlags().StringVar(&uploadContentType, "content-type", "", "indicate a content type (e.g. \"application/json\") to store with the blob")
+	writeCmd.PersistentFlags().StringVar(&uploadEncoding, "content-encoding", "", "indicate a content encoding (e.g. \"gzip\") to store with the blob")
+	writeCmd.PersistentFlags().StringVar(&encryptionKey, "encryption-key", "", "indicate a base64 encoded AES-256 key to encrypt the blob with")
+	writeCmd.PersistentFlags().StringVar(&encryptionKeySHA256, "encryption-key-sha256", "", "indicate the base64 encoded SHA-256 of --encryption-key (computed if empty)")
+	writeCmd.PersistentFlags().BoolVar(&gzipped, "gzip", false, "compress the content with gzip and store it with Content-Encoding gzip")
+	writeCmd.PersistentFlags().BoolVar(&noOverwrite, "no-overwrite", false, "fail if the blob already exists")
+	writeCmd.PersistentFlags().StringVar(&ifMatch, "if-match", "", "indicate an ETag the blob should have for the write to succeed")
+	writeCmd.PersistentFlags().StringVar(&uploadCacheControl, "cache-control", "", "indicate a cache control (e.g. \"max-age=3600\") to store with the blob")
+	writeCmd.PersistentFlags().BoolVar(&validateOnly, "validate-only", false, "check credentials, container, key and content and report what would be written without writing")
+	writeCmd.PersistentFlags().BoolVar(&keyFromHash, "key-from-hash", false, "use the SHA-256 of the content as the blob key instead of --blob-key")
+	writeCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to put in front of the key computed with --key-from-hash")
+	writeCmd.PersistentFlags().StringVar(&keyExtension, "key-extension", "", "indicate an extension (e.g. \".json\") to append to the key computed with --key-from-hash")
+	writeCmd.PersistentFlags().BoolVar(&bufferToTempFile, "buffer-to-temp-file", false, "copy piped input to a temporary file first, to know its size and Content-MD5 before the upload")
+	writeCmd.PersistentFlags().StringArrayVar(&metaPairs, "meta", nil, "indicate a NAME=VALUE metadata pair to store with the blob (repeatable)")
+	writeCmd.PersistentFlags().StringVar(&metaFile, "meta-file", "", "indicate a JSON file of metadata pairs to store with the blob, overridden by --meta")
+	writeCmd.PersistentFlags().StringArrayVar(&tagPairs, "tag", nil, "indicate a KEY=VALUE index tag to store with the blob (repeatable)")
+	writeCmd.PersistentFlags().StringVar(&tagsFile, "tags-file", "", "indicate a JSON file of index tags to store with the blob, overridden by --tag")
+	readCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key for reading")
+	readCmd.PersistentFlags().BoolVar(&asEnv, "as-env", false, "print KEY=VALUE lines of the blob as shell export statements")
+	readCmd.PersistentFlags().Int64Var(&readOffset, "offset", 0, "indicate a byte offset to start reading the blob at")
+	readCmd.PersistentFlags().Int64Var(&readLength, "length", -1, "indicate a number of bytes to read, or -1 to read to the end of the blob")
+	readCmd.PersistentFlags().StringVar(&readRange, "range", "", "indicate a byte range to read, relative to the size of the blob (e.g. \"-1KB\" for the last kilobyte or \"50%-\" for the second half)")
+	readCmd.PersistentFlags().StringVar(&grepPattern, "grep", "", "indicate a regular expression to print only the matching lines of the blob")
+	readCmd.PersistentFlags().IntVar(&headLines, "head", 0, "indicate a number of lines to print from the start of the blob, stopping the download after them")
+	readCmd.PersistentFlags().IntVar(&tailLines, "tail", 0, "indicate a number of lines to print from the end of the blob")
+	readCmd.PersistentFlags().StringVar(&encryptionKey, "encryption-key", "", "indicate a base64 encoded AES-256 key the blob was written with")
+	readCmd.PersistentFlags().StringVar(&encryptionKeySHA256, "encryption-key-sha256", "", "indicate the base64 encoded SHA-256 of --encryption-key (computed if empty)")
+	readCmd.PersistentFlags().StringVar(&readSnapshot, "snapshot", "", "indicate a snapshot timestamp to read the snapshot of the blob taken then")
+	readCmd.PersistentFlags().StringVar(&readDefault, "default", "", "indicate a value to print instead of failing if the blob doesn't exist")
+	readCmd.PersistentFlags().BoolVar(&rawRead, "raw", false, "print encoded blobs without decompressing them")
 	listCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to read from subdirectories")
+	listCmd.PersistentFlags().StringVar(&stateFile, "state-file", "", "indicate a file to persist the listing position to, so an interrupted flat listing can be resumed")
+	listCmd.PersistentFlags().BoolVar(&incremental, "incremental", false, "only list blobs that are new or changed (by ETag) since the last run recorded in --state-file")
+	listCmd.PersistentFlags().BoolVar(&assumeYes, "yes", false, "do not warn when listing the entire container")
+	listCmd.PersistentFlags().IntVar(&shards, "shards", 0, "indicate a number of workers to list the keyspace concurrently with (unordered output)")
+	listCmd.PersistentFlags().BoolVar(&asciiKeys, "ascii-keys", false, "with --shards, skip the keys whose first character after the prefix is not printable ASCII")
+	listCmd.PersistentFlags().BoolVar(&strict, "strict", false, "exit with status 1 if nothing was listed")
+	listCmd.PersistentFlags().BoolVar(&exitZeroOnEmpty, "exit-zero-on-empty", false, "exit with status 0 if nothing was listed, even with --strict")
+	listCmd.PersistentFlags().BoolVar(&urls, "urls", false, "print the full https URL of every blob instead of its key")
+	listCmd.PersistentFlags().BoolVar(&sign, "sign", false, "print signed URLs granting read access with --urls")
+	listCmd.PersistentFlags().DurationVar(&expiry, "expiry", time.Hour, "indicate how long the URLs printed with --sign stay valid")
+	listCmd.PersistentFlags().IntVar(&maxResults, "max-results", 0, "indicate a number of entries to stop listing after (0 lists everything)")
+	listCmd.PersistentFlags().BoolVar(&noRecurse, "no-recurse", false, "list only the immediate level without descending into directories")
+	listCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "indicate an output format (text, json or csv)")
+	listCmd.PersistentFlags().BoolVar(&includeDeleted, "include-deleted", false, "also list soft-deleted blobs, marked as deleted")
+	listCmd.PersistentFlags().BoolVar(&parallelPrefixes, "parallel-prefixes", false, "list the top-level directories under the prefix concurrently, with --concurrency workers")
+	listCmd.PersistentFlags().StringVar(&prefixesFile, "prefixes-file", "", "indicate a file with one blob prefix per line to list instead of --blob-prefix")
+
+	createContainerCmd.PersistentFlags().StringVar(&publicAccess, "public-access", "", "indicate a public access level (none, blob or container; none if empty)")
+	createContainerCmd.PersistentFlags().BoolVar(&ifNotExists, "if-not-exists", false, "succeed without changes if the container already exists")
 
 	// Add commands
 	rootCmd.AddCommand(createContainerCmd)
@@ -228,17 +1051,380 @@ func init() {
 	rootCmd.AddCommand(listCmd)
 
 	// Init azure
-	// Create a credentials object.
+	// Read the account from the environment, falling back to the constants.
+	accountName, accountKey = defaultAccountName, defaultAccountKey
+	if v := os.Getenv("AZURE_STORAGE_ACCOUNT"); v != "" {
+		accountName = azureblob.AccountName(v)
+	}
+	if v := os.Getenv("AZURE_STORAGE_KEY"); v != "" {
+		accountKey = azureblob.AccountKey(v)
+	}
+	// The flag default is left empty so that --help doesn't print the key
+	connectionString = os.Getenv("AZURE_STORAGE_CONNECTION_STRING")
+	sasToken = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+
+	// The credential and pipeline are created by rootCmd once the
+	// --account-name, --account-key and --sas-token flags are parsed.
 	ctx = context.Background()
-	credential, err := azureblob.NewCredential(accountName, accountKey)
+}
+
+// resolveAccount lets the flags override the account and endpoint read
+// from the environment, which override those of the config file.
+func resolveAccount(cmd *cobra.Command) error {
+	if err := loadConfigFile(cmd); err != nil {
+		return err
+	}
+	if serviceURLFlag != "" && (emulator || cmd.Flags().Changed("endpoint-suffix")) {
+		return fmt.Errorf(`flag "--service-url" cannot be combined with "--emulator" or "--endpoint-suffix"`)
+	}
+	if emulator {
+		if cmd.Flags().Changed("endpoint-suffix") || connectionString != "" {
+			return fmt.Errorf(`flag "--emulator" cannot be combined with "--endpoint-suffix" or a connection string`)
+		}
+		useEmulator()
+	}
+	if cmd.Flags().Changed("endpoint-suffix") {
+		if err := setEndpointSuffix(endpointSuffix); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("account-name") {
+		accountName = azureblob.AccountName(accountNameFlag)
+	}
+	if cmd.Flags().Changed("account-key") {
+		accountKey = azureblob.AccountKey(accountKeyFlag)
+	}
+	// A connection string takes precedence over the separate name and key
+	if connectionString != "" {
+		if err := parseConnectionString(connectionString); err != nil {
+			return err
+		}
+	}
+	// The service URL takes precedence over the endpoint of a connection string
+	if serviceURLFlag != "" {
+		if err := setServiceURL(serviceURLFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initPipeline creates the credential and pipeline of the configured account.
+// With --sas-token, requests are authorized by the SAS in their URL instead
+// and no credential is created.
+func initPipeline() error {
+	if sasToken != "" {
+		credential = nil
+		pline = wrapPipeline(azureblob.NewPipeline(azblob.NewAnonymousCredential(), pipelineOptions()))
+		return nil
+	}
+	if useAAD() {
+		// There is no account key, so buckets can't sign URLs, see signURL
+		credential = nil
+		tokenCredential, err := newTokenCredential()
+		if err != nil {
+			return err
+		}
+		pline = wrapPipeline(azureblob.NewPipeline(tokenCredential, pipelineOptions()))
+		return nil
+	}
+
+	// Create a credentials object. Assign the package-level credential, as
+	// OpenBucket needs it for blob.SignedURL.
+	var err error
+	credential, err = azureblob.NewCredential(accountName, accountKey)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// Create a Pipeline, using whatever PipelineOptions you need.
-	pline = azureblob.NewPipeline(credential, azblob.PipelineOptions{})
+	pline = wrapPipeline(azureblob.NewPipeline(credential, pipelineOptions()))
+	return nil
+}
+
+// wrapPipeline adds the behaviour the SDK doesn't provide to p: retries
+// following the retry flags, headers passed in the context and reading blobs
+// as stored.
+func wrapPipeline(p pipeline.Pipeline) pipeline.Pipeline {
+	return retryPipeline{requestHeadersPipeline{identityEncodingPipeline{p}}}
+}
+
+// pipelineOptions returns the options of the pipeline. Requests are only
+// logged with --verbose, so no logging function is called otherwise.
+func pipelineOptions() azblob.PipelineOptions {
+	opts := azblob.PipelineOptions{Retry: retryOptions()}
+	if verbose {
+		opts.Log = logOptions()
+	}
+	return opts
+}
+
+// checkAccount returns an error if the account name or key is empty or
+// still a placeholder. No key is needed with --sas-token.
+func checkAccount() error {
+	if accountName == "" || accountName == defaultAccountName {
+		return fmt.Errorf("no storage account name is configured, set \"--account-name\" or AZURE_STORAGE_ACCOUNT")
+	}
+	if sasToken != "" || useAAD() {
+		return nil
+	}
+	if accountKey == "" || accountKey == defaultAccountKey {
+		return fmt.Errorf("no storage account key is configured, set \"--account-key\" or AZURE_STORAGE_KEY")
+	}
+	return nil
+}
+
+// openClient opens the container named by --container-name as a
+// *blobstore.Client. Commands share the one opened by getClient.
+func openClient(ctx context.Context) (*blobstore.Client, error) {
+	return blobstore.Open(ctx, blobstore.Config{
+		AccountName: bucketAccountName(),
+		Pipeline:    pline,
+		ServiceURL:  serviceURL(),
+		Container:   containerName,
+		Options:     bucketOptions(),
+	})
+}
+
+// openContainer opens the named container as a *blob.Bucket.
+// The credential Option is required if you're going to use blob.SignedURL.
+func openContainer(ctx context.Context, name string) (*blob.Bucket, error) {
+	return azureblob.OpenBucket(ctx, pline, bucketAccountName(), name, bucketOptions())
+}
+
+// bucketOptions returns the options to open buckets of the configured
+// account with. In SAS mode there is no credential, so the buckets can't
+// sign URLs.
+func bucketOptions() *azureblob.Options {
+	opts := &azureblob.Options{
+		StorageDomain: storageDomain,
+		Protocol:      storageProtocol,
+		SASToken:      azureblob.SASToken(sasToken),
+		// The account name is not part of the host of a custom service URL
+		IsCDN: customServiceURL != nil,
+	}
+	// Leave the interface nil rather than holding a nil *SharedKeyCredential
+	if credential != nil {
+		opts.Credential = credential
+	}
+	return opts
+}
+
+// contentKey returns a content-addressable blob key for content: the hex
+// SHA-256 of the content between prefix and ext.
+func contentKey(prefix string, content []byte, ext string) string {
+	return fmt.Sprintf("%s%x%s", prefix, sha256.Sum256(content), ext)
+}
+
+// serviceURL returns the blob service endpoint of the storage account.
+// URLs are built structurally rather than with string formatting so that
+// container and blob names are escaped correctly. Every URL of the account
+// is built from it, so that the endpoint suffix, emulator and service URL
+// apply to all.
+func serviceURL() url.URL {
+	if customServiceURL != nil {
+		u := *customServiceURL
+		u.RawQuery = strings.TrimPrefix(sasToken, "?")
+		return u
+	}
+	u := url.URL{
+		Scheme: string(storageProtocol),
+		Host:   fmt.Sprintf("%s.%s", accountName, storageDomain),
+		// The portal shows SAS tokens with a leading '?'
+		RawQuery: strings.TrimPrefix(sasToken, "?"),
+	}
+	if isLocalDomain() {
+		u.Host = string(storageDomain)
+		u.Path = "/" + string(accountName)
+	}
+	return u
+}
+
+// newContainerURL returns a ContainerURL for the named container. The name
+// is appended to the path of the service URL, so characters such as spaces,
+// '#' and '?' are escaped when the URL is serialized.
+func newContainerURL(name string) azblob.ContainerURL {
+	return azblob.NewServiceURL(serviceURL(), pline).NewContainerURL(name)
+}
+
+// newBlobURL returns a BlobURL for key in the named container. Keys are
+// escaped the same way as container names, so keys containing spaces, '#',
+// '?' or unicode characters address the right blob.
+func newBlobURL(container, key string) azblob.BlobURL {
+	return newContainerURL(container).NewBlobURL(key)
+}
+
+// listBlobItems lists the blobs under prefix in the named container with
+// their properties, which blob.Bucket.List doesn't expose. details selects
+// what else to include in the listing.
+func listBlobItems(ctx context.Context, container, prefix string, details azblob.BlobListingDetails) ([]azblob.BlobItemInternal, error) {
+	var items []azblob.BlobItemInternal
+	containerURL := newContainerURL(container)
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Details:    details,
+			Prefix:     prefix,
+			MaxResults: listPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, resp.Segment.BlobItems...)
+		marker = resp.NextMarker
+	}
+	return items, nil
+}
+
+// listURLs lists all blobs under prefix in b as a flat namespace, printing
+// the URL of each to out, signed if --sign is set. Every blob listed is
+// counted in stats.
+func listURLs(ctx context.Context, out io.Writer, b *blob.Bucket, prefix string, stats *listStats) error {
+	iter := b.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var u string
+		if sign {
+			u, err = signURL(ctx, b, obj.Key, &blob.SignedURLOptions{Expiry: expiry})
+			if err != nil {
+				return err
+			}
+		} else {
+			u = redactedURL(newBlobURL(containerName, obj.Key).URL())
+		}
+		fmt.Fprintln(out, u)
+		stats.add(obj)
+	}
+}
+
+// errMaxResults stops a listing once --max-results entries were listed.
+var errMaxResults = errors.New("maximum number of results listed")
+
+// checkEmptyListing returns an *exitError if --strict is set and nothing was
+// counted in stats, unless --exit-zero-on-empty is set.
+func checkEmptyListing(errOut io.Writer, stats *listStats) error {
+	if !strict || exitZeroOnEmpty || stats.Files+stats.Dirs > 0 {
+		return nil
+	}
+	fmt.Fprintln(errOut, "Nothing was listed")
+	return &exitError{Code: 1}
+}
+
+// listStats summarizes the entries encountered by a listing.
+type listStats struct {
+	Files int   `json:"files"`
+	Dirs  int   `json:"dirs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// add counts obj in s.
+func (s *listStats) add(obj *blob.ListObject) {
+	if obj.IsDir {
+		s.Dirs++
+		return
+	}
+	s.addBlob(obj.Size)
+}
+
+// addBlob counts a blob of the given size in s.
+func (s *listStats) addBlob(size int64) {
+	s.Files++
+	s.Bytes += size
+}
+
+// merge adds the counts of o to s.
+func (s *listStats) merge(o *listStats) {
+	s.Files += o.Files
+	s.Dirs += o.Dirs
+	s.Bytes += o.Bytes
+}
+
+func (s *listStats) String() string {
+	return fmt.Sprintf("%d files, %d directories, %s", s.Files, s.Dirs, formatBytes(s.Bytes))
+}
+
+// printSummary prints the summary of a listing to errOut, unless --quiet is
+// set.
+func printSummary(errOut io.Writer, summary fmt.Stringer) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(errOut, "Summary: %s\n", summary)
+}
+
+// warnFullScan warns on errOut that an empty prefix covers the whole
+// container, unless --yes was given.
+func warnFullScan(errOut io.Writer, prefix string) {
+	if prefix != "" || assumeYes {
+		return
+	}
+	logger.Warn(fmt.Sprintf("No prefix given, this covers the entire container %q (use --yes to suppress this warning)", containerName))
+}
+
+// normalizePrefix returns prefix with a trailing "/" appended if it names a
+// pseudo-directory in b, i.e. at least one blob key starts with prefix + "/".
+// Empty prefixes and prefixes already ending in "/" are returned unchanged.
+func normalizePrefix(ctx context.Context, b *blob.Bucket, prefix string) (string, error) {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix, nil
+	}
+
+	objs, _, err := b.ListPage(ctx, blob.FirstPageToken, 1, &blob.ListOptions{
+		Delimiter: "/",
+		Prefix:    prefix + "/",
+	})
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if len(objs) > 0 {
+		return prefix + "/", nil
+	}
+	return prefix, nil
+}
+
+// readPrefixesFile reads blob prefixes from the file at path, one per line.
+// Blank lines and lines starting with "#" are ignored.
+func readPrefixesFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var prefixes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefixes = append(prefixes, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no prefixes found in %q", path)
+	}
+	return prefixes, nil
 }
 
 func main() {
-	Execute()
+	err := Execute()
+	if err == nil {
+		return
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	logger.Error(err.Error())
+	os.Exit(1)
 }
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// testAccount are the flags of a made up account, which commands that fail
+// or return before sending requests can run with.
+var testAccount = []string{"--account-name", "testaccount", "--account-key", "a2V5", "--container-name", "test"}
+
+// execute runs the command line args with the test account, as the binary
+// would, and returns what it wrote to stdout and stderr.
+func execute(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	return executeArgs(t, append(append([]string{}, args...), testAccount...))
+}
+
+// executeArgs runs the command line args as the binary would, and returns
+// what it wrote to stdout and stderr.
+func executeArgs(t *testing.T, args []string) (string, string, error) {
+	t.Helper()
+	// Keep the config file of the user out of the tests
+	t.Setenv("HOME", t.TempDir())
+
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	t.Cleanup(func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	})
+	err := Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestExecuteResetsFlags(t *testing.T) {
+	if _, _, err := execute(t, "write", "--blob-key", "k", "--blob-value", "v", "--dry-run"); err != nil {
+		t.Fatalf("write --dry-run: %v", err)
+	}
+
+	// read must not inherit the --blob-key of write
+	_, _, err := execute(t, "read")
+	if err == nil || !strings.Contains(err.Error(), `"--blob-key" should be set`) {
+		t.Errorf("read after write: got error %v, want --blob-key to be required", err)
+	}
+	if blobKey != "" {
+		t.Errorf("blobKey is %q after read, want it reset", blobKey)
+	}
+}
+
+func TestExecuteDryRunIsPerInvocation(t *testing.T) {
+	stdout, _, err := execute(t, "write", "--blob-key", "k", "--blob-value", "v", "--dry-run")
+	if err != nil {
+		t.Fatalf("write --dry-run: %v", err)
+	}
+	if !strings.HasPrefix(stdout, `Dry run: would run "write"`) {
+		t.Errorf("write --dry-run printed %q, want the dry run", stdout)
+	}
+
+	// Without --dry-run, write runs again and checks its flags
+	stdout, _, err = execute(t, "write", "--blob-value", "v")
+	if err == nil || !strings.Contains(err.Error(), `"--blob-key" should be set`) {
+		t.Errorf("write after write --dry-run: got error %v, want --blob-key to be required", err)
+	}
+	if stdout != "" {
+		t.Errorf("write after write --dry-run printed %q", stdout)
+	}
+}
+
+func TestExecuteDryRunChecksFlags(t *testing.T) {
+	stdout, _, err := execute(t, "write", "--blob-value", "v", "--dry-run")
+	if err == nil || !strings.Contains(err.Error(), `"--blob-key" should be set`) {
+		t.Errorf("write --dry-run without --blob-key: got error %v", err)
+	}
+	if stdout != "" {
+		t.Errorf("write --dry-run without --blob-key printed %q", stdout)
+	}
+}
+
+func TestInitPipelineSetsCredential(t *testing.T) {
+	savedName, savedKey, savedCredential, savedPipeline := accountName, accountKey, credential, pline
+	t.Cleanup(func() {
+		accountName, accountKey, credential, pline = savedName, savedKey, savedCredential, savedPipeline
+	})
+	accountName, accountKey, credential = "testaccount", "a2V5", nil
+
+	if err := initPipeline(); err != nil {
+		t.Fatal(err)
+	}
+	// The package-level credential signs URLs, so it must not be shadowed
+	if credential == nil {
+		t.Error("credential is nil after initPipeline")
+	}
+	if pline == nil {
+		t.Error("pipeline is nil after initPipeline")
+	}
+}
+
+// useTestAccount points the account at testaccount in the public cloud for
+// the duration of the test.
+func useTestAccount(t *testing.T) {
+	savedName, savedKey, savedProtocol, savedDomain := accountName, accountKey, storageProtocol, storageDomain
+	savedServiceURL, savedSAS := customServiceURL, sasToken
+	t.Cleanup(func() {
+		accountName, accountKey, storageProtocol, storageDomain = savedName, savedKey, savedProtocol, savedDomain
+		customServiceURL, sasToken = savedServiceURL, savedSAS
+	})
+	accountName, accountKey = "testaccount", "a2V5"
+	storageProtocol, storageDomain = "https", "blob."+defaultEndpointSuffix
+	customServiceURL, sasToken = nil, ""
+}
+
+func TestNewBlobURL(t *testing.T) {
+	useTestAccount(t)
+
+	const base = "https://testaccount.blob.core.windows.net/c/"
+	tests := []struct {
+		key     string
+		wantURL string
+	}{
+		{key: "a.txt", wantURL: base + "a.txt"},
+		{key: "dir/a b.txt", wantURL: base + "dir/a%20b.txt"},
+		{key: "100%.txt", wantURL: base + "100%25.txt"},
+		{key: "a#b", wantURL: base + "a%23b"},
+		{key: "a?b=c", wantURL: base + "a%3Fb=c"},
+		{key: "a%2Fb", wantURL: base + "a%252Fb"},
+		{key: "dir/ü日本.txt", wantURL: base + "dir/%C3%BC%E6%97%A5%E6%9C%AC.txt"},
+		// The blob is named "/lead", not "lead"
+		{key: "/lead", wantURL: base + "/lead"},
+	}
+	for _, tt := range tests {
+		u := newBlobURL("c", tt.key).URL()
+		if got := u.String(); got != tt.wantURL {
+			t.Errorf("newBlobURL(%q) = %s, want %s", tt.key, got, tt.wantURL)
+		}
+		// The service unescapes the path back to the container and key
+		parsed, err := url.Parse(u.String())
+		if err != nil {
+			t.Fatalf("newBlobURL(%q): %v", tt.key, err)
+		}
+		if got := strings.TrimPrefix(parsed.Path, "/c/"); got != tt.key {
+			t.Errorf("newBlobURL(%q) addresses key %q", tt.key, got)
+		}
+	}
+}
+
+func TestServiceURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func()
+		wantURL  string
+		redacted string
+	}{
+		{
+			name:     "account",
+			setup:    func() {},
+			wantURL:  "https://testaccount.blob.core.windows.net",
+			redacted: "https://testaccount.blob.core.windows.net",
+		},
+		{
+			name:     "SAS token",
+			setup:    func() { sasToken = "?sv=2020-08-04&sig=secret%2F" },
+			wantURL:  "https://testaccount.blob.core.windows.net?sv=2020-08-04&sig=secret%2F",
+			redacted: "https://testaccount.blob.core.windows.net",
+		},
+		{
+			name:     "emulator",
+			setup:    useEmulator,
+			wantURL:  "http://127.0.0.1:10000/devstoreaccount1",
+			redacted: "http://127.0.0.1:10000/devstoreaccount1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestAccount(t)
+			tt.setup()
+
+			u := serviceURL()
+			if got := u.String(); got != tt.wantURL {
+				t.Errorf("serviceURL() = %s, want %s", got, tt.wantURL)
+			}
+			if got := redactedURL(u); got != tt.redacted {
+				t.Errorf("redactedURL(serviceURL()) = %s, want %s", got, tt.redacted)
+			}
+		})
+	}
+}
+
+func TestRedactedURL(t *testing.T) {
+	useTestAccount(t)
+	sasToken = "sv=2020-08-04&sig=secret"
+
+	u := newBlobURL("c", "a b?#.txt").URL()
+	if !strings.Contains(u.String(), "sig=secret") {
+		t.Fatalf("blob URL %s has no SAS token", u.String())
+	}
+	want := "https://testaccount.blob.core.windows.net/c/a%20b%3F%23.txt"
+	if got := redactedURL(u); got != want {
+		t.Errorf("redactedURL() = %s, want %s", got, want)
+	}
+}
+
+func TestWriteContentType(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "page.html", "--blob-value", "<p>hi</p>", "--content-type", "text/html"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	attrs, err := openFakeBucket(t, s).Attributes(context.Background(), "page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.ContentType != "text/html" {
+		t.Errorf("got Content-Type %q, want text/html", attrs.ContentType)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "with", "--blob-value", "v", "--content-encoding", "br", "--cache-control", "max-age=3600"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "without", "--blob-value", "v"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	b := openFakeBucket(t, s)
+	attrs, err := b.Attributes(context.Background(), "with")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.ContentEncoding != "br" || attrs.CacheControl != "max-age=3600" {
+		t.Errorf("got Content-Encoding %q and Cache-Control %q, want br and max-age=3600", attrs.ContentEncoding, attrs.CacheControl)
+	}
+
+	// Empty flags leave the headers to the service
+	attrs, err = b.Attributes(context.Background(), "without")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.ContentEncoding != "" || attrs.CacheControl != "" {
+		t.Errorf("got Content-Encoding %q and Cache-Control %q without flags, want none", attrs.ContentEncoding, attrs.CacheControl)
+	}
+}
+
+func TestOutputStreams(t *testing.T) {
+	s := newFakeService(t, "test")
+
+	stdout, stderr, err := executeFake(t, s, "write", "--blob-key", "k", "--blob-value", "payload")
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if stdout != "" {
+		t.Errorf("write printed %q to stdout, want nothing", stdout)
+	}
+	if !strings.Contains(stderr, "Successfully written") {
+		t.Errorf("write printed %q to stderr, want the confirmation", stderr)
+	}
+
+	stdout, stderr, err = executeFake(t, s, "read", "--blob-key", "k")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if stdout != "payload\n" {
+		t.Errorf("read printed %q to stdout, want only the blob", stdout)
+	}
+	if !strings.Contains(stderr, "Content-Type: ") || !strings.Contains(stderr, "Successfully read") {
+		t.Errorf("read printed %q to stderr, want the Content-Type and the confirmation", stderr)
+	}
+
+	// --quiet leaves the data but drops the confirmations
+	stdout, stderr, err = executeFake(t, s, "read", "--blob-key", "k", "--quiet")
+	if err != nil {
+		t.Fatalf("read --quiet: %v", err)
+	}
+	if stdout != "payload\n" {
+		t.Errorf("read --quiet printed %q to stdout, want only the blob", stdout)
+	}
+	if stderr != "" {
+		t.Errorf("read --quiet printed %q to stderr, want nothing", stderr)
+	}
+
+	// Errors are still reported with --quiet
+	_, _, err = executeFake(t, s, "read", "--blob-key", "missing", "--quiet")
+	if err == nil {
+		t.Error("read --quiet of a missing blob succeeded")
+	}
+}
+
+func TestWriteContentTypeReadBack(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "data.json", "--blob-value", "{}", "--content-type", "application/json"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// The content type is persisted with the blob, not only sent with the
+	// upload requests
+	var persisted bool
+	for _, r := range s.requests {
+		if r.Method == http.MethodPut && r.Header.Get("x-ms-blob-content-type") == "application/json" {
+			persisted = true
+		}
+	}
+	if !persisted {
+		t.Error("write sent no x-ms-blob-content-type header")
+	}
+
+	stdout, stderr, err := executeFake(t, s, "read", "--blob-key", "data.json")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "{}\n"; stdout != want {
+		t.Errorf("read printed %q, want %q", stdout, want)
+	}
+	if !strings.Contains(stderr, "Content-Type: application/json") {
+		t.Errorf("read printed %q to stderr, want the Content-Type", stderr)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commands
+	blobExistsCmd = &cobra.Command{
+		Use:   "blob-exists",
+		Short: "Check whether a blob exists",
+		Long: `Check whether a blob exists.
+
+The command exits with status 0 if --blob-key exists and 1 if it doesn't,
+without reading its content, or with status 3 if --container-name doesn't
+exist either, which usually means it is mistyped. If the check itself
+fails, e.g. because of an authentication or network problem, it exits with
+status 2, so scripts can tell "doesn't exist" apart from "couldn't check".
+With --quiet, nothing is printed and only the exit status reports the
+outcome.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// fail reports err unless --quiet is set and exits with status 2.
+			fail := func(err error) error {
+				if !quiet {
+					logger.Error(err.Error())
+				}
+				return &exitError{Code: 2}
+			}
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fail(fmt.Errorf(`flag "--blob-key" should be set`))
+			}
+
+			// The service tells a missing container from a missing blob by
+			// the error code of the response
+			_, err := newBlobURL(containerName, blobKey).GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+			var serr azblob.StorageError
+			switch {
+			case errors.As(err, &serr) && serr.ServiceCode() == azblob.ServiceCodeContainerNotFound:
+				if !quiet {
+					logger.Info(fmt.Sprintf("Container %q does not exist", containerName))
+				}
+				return &exitError{Code: 3}
+			case isBlobNotFound(err):
+				if !quiet {
+					logger.Info(fmt.Sprintf("Blob %q does not exist", blobKey))
+				}
+				return &exitError{Code: 1}
+			case err != nil:
+				return fail(err)
+			}
+
+			if !quiet {
+				logger.Info(fmt.Sprintf("Blob %q exists", blobKey))
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	blobExistsCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to check")
+
+	rootCmd.AddCommand(blobExistsCmd)
+}
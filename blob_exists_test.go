@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBlobExistsExitStatus(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "k", "--blob-value", "v"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// A service without the test container
+	empty := newFakeService(t)
+
+	for _, tt := range []struct {
+		name    string
+		service *fakeService
+		key     string
+		code    int
+	}{
+		{"exists", s, "k", 0},
+		{"missing blob", s, "missing", 1},
+		{"missing container", empty, "k", 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, _, err := executeFake(t, tt.service, "blob-exists", "--blob-key", tt.key)
+			code := 0
+			if err != nil {
+				var exitErr *exitError
+				if !errors.As(err, &exitErr) {
+					t.Fatalf("blob-exists: %v", err)
+				}
+				code = exitErr.Code
+			}
+			if code != tt.code {
+				t.Errorf("blob-exists exited with status %d, want %d", code, tt.code)
+			}
+			// The outcome is logged, so stdout stays empty
+			if stdout != "" {
+				t.Errorf("blob-exists printed %q to stdout, want nothing", stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/noprysk-ua/azure/blobstore"
+	"github.com/spf13/cobra"
+)
+
+// blobProperties are the attributes of a blob printed by blob-properties.
+type blobProperties struct {
+	Key         string            `json:"key"`
+	ContentType string            `json:"contentType"`
+	Size        int64             `json:"size"`
+	ModTime     time.Time         `json:"modTime"`
+	MD5         string            `json:"md5,omitempty"`
+	ETag        string            `json:"etag"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+var (
+	// Commands
+	blobPropertiesCmd = &cobra.Command{
+		Use:   "blob-properties",
+		Short: "Show the size, content type, modification time and metadata of a blob",
+		Long: `Show the size, content type, modification time and metadata of a blob.
+
+The attributes of --blob-key are read without downloading its content. The
+MD5 is printed base64-encoded, as by the Azure portal, and only if it was
+stored when the blob was written. Use --output json to parse them.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if outputFormat != "text" && outputFormat != "json" {
+				return fmt.Errorf(`flag "--output" should be one of "text" or "json"`)
+			}
+
+			client, release, err := getClient(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			attrs, err := client.Stat(ctx, blobKey)
+			if err != nil {
+				return err
+			}
+			props := newBlobProperties(attrs)
+
+			if outputFormat == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(props)
+			}
+
+			printBlobProperties(out, props)
+			return nil
+		},
+	}
+)
+
+// newBlobProperties returns the properties of a blob from its attributes.
+func newBlobProperties(attrs *blobstore.Attributes) blobProperties {
+	props := blobProperties{
+		Key:         attrs.Key,
+		ContentType: attrs.ContentType,
+		Size:        attrs.Size,
+		ModTime:     attrs.ModTime,
+		ETag:        attrs.ETag,
+		Metadata:    attrs.Metadata,
+	}
+	if len(attrs.MD5) > 0 {
+		props.MD5 = base64.StdEncoding.EncodeToString(attrs.MD5)
+	}
+	if props.Metadata == nil {
+		props.Metadata = map[string]string{}
+	}
+	return props
+}
+
+// printBlobProperties prints props as aligned "name: value" lines followed
+// by the metadata sorted by name.
+func printBlobProperties(out io.Writer, props blobProperties) {
+	md5 := props.MD5
+	if md5 == "" {
+		md5 = "not stored"
+	}
+	for _, line := range [][2]string{
+		{"Key", props.Key},
+		{"Content-Type", props.ContentType},
+		{"Size", fmt.Sprintf("%s (%d bytes)", formatBytes(props.Size), props.Size)},
+		{"Last modified", props.ModTime.Local().Format(time.RFC1123)},
+		{"MD5", md5},
+		{"ETag", props.ETag},
+	} {
+		fmt.Fprintf(out, "%-14s %s\n", line[0]+":", line[1])
+	}
+
+	if len(props.Metadata) == 0 {
+		fmt.Fprintf(out, "%-14s %s\n", "Metadata:", "none")
+		return
+	}
+	fmt.Fprintln(out, "Metadata:")
+	names := make([]string, 0, len(props.Metadata))
+	for name := range props.Metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s=%s\n", name, props.Metadata[name])
+	}
+}
+
+func init() {
+	blobPropertiesCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to show the properties of")
+	blobPropertiesCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "indicate an output format (text or json)")
+
+	rootCmd.AddCommand(blobPropertiesCmd)
+}
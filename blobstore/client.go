@@ -0,0 +1,287 @@
+// Package blobstore provides the blob operations of the azure command as a
+// library, for programmatic use of an Azure Storage container.
+//
+// A Client is bound to a container:
+//
+//	c, err := blobstore.Open(ctx, blobstore.Config{
+//		AccountName: name,
+//		Pipeline:    azureblob.NewPipeline(credential, azblob.PipelineOptions{}),
+//		ServiceURL:  url.URL{Scheme: "https", Host: name + ".blob.core.windows.net"},
+//		Container:   "my-container",
+//		Options:     &azureblob.Options{Credential: credential},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	res, err := c.Write(ctx, "greeting", strings.NewReader("hello\n"), nil)
+package blobstore
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/azureblob"
+)
+
+// Config configures a Client.
+type Config struct {
+	// AccountName is the name of the storage account.
+	AccountName azureblob.AccountName
+	// Pipeline sends the requests, e.g. one built by azureblob.NewPipeline.
+	Pipeline pipeline.Pipeline
+	// ServiceURL is the blob service endpoint of the account. It must match
+	// Options, and carries the SAS token if one is used.
+	ServiceURL url.URL
+	// Container is the name of the container the Client operates on.
+	Container string
+	// Options are used to open the container as a *blob.Bucket. The
+	// Credential is required to sign URLs.
+	Options *azureblob.Options
+}
+
+// WriteResult describes a blob written by Client.Write.
+type WriteResult struct {
+	Key string
+	// Size is the number of bytes written.
+	Size int64
+	ETag string
+	// MD5 is the MD5 of the content written, whether or not it is stored
+	// with the blob.
+	MD5         []byte
+	ContentType string
+}
+
+// Attributes are the attributes of a blob, as returned by Client.Stat.
+type Attributes struct {
+	Key  string
+	Size int64
+	ETag string
+	// MD5 is the MD5 stored with the blob, or nil if none was.
+	MD5             []byte
+	ContentType     string
+	ContentEncoding string
+	CacheControl    string
+	ModTime         time.Time
+	Metadata        map[string]string
+}
+
+// Client performs blob operations on a container.
+type Client struct {
+	bucket       *blob.Bucket
+	containerURL azblob.ContainerURL
+}
+
+// Open returns a Client for the container of config. The container does
+// not need to exist yet. The Client should be closed after use.
+func Open(ctx context.Context, config Config) (*Client, error) {
+	bucket, err := azureblob.OpenBucket(ctx, config.Pipeline, config.AccountName, config.Container, config.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		bucket:       bucket,
+		containerURL: azblob.NewServiceURL(config.ServiceURL, config.Pipeline).NewContainerURL(config.Container),
+	}, nil
+}
+
+// Close releases the resources of the Client.
+func (c *Client) Close() error {
+	return c.bucket.Close()
+}
+
+// Bucket returns the container as a *blob.Bucket, for operations the Client
+// doesn't provide.
+func (c *Client) Bucket() *blob.Bucket {
+	return c.bucket
+}
+
+// ContainerURL returns the URL of the container, for operations that need
+// the Azure SDK.
+func (c *Client) ContainerURL() azblob.ContainerURL {
+	return c.containerURL
+}
+
+// CreateContainer creates the container, without public access.
+func (c *Client) CreateContainer(ctx context.Context) error {
+	return c.CreateContainerWithAccess(ctx, azblob.PublicAccessNone)
+}
+
+// CreateContainerWithAccess creates the container with the public access
+// level access.
+func (c *Client) CreateContainerWithAccess(ctx context.Context, access azblob.PublicAccessType) error {
+	_, err := c.containerURL.Create(ctx, azblob.Metadata{}, access)
+	return err
+}
+
+// ContainerExists reports whether the container exists.
+func (c *Client) ContainerExists(ctx context.Context) (bool, error) {
+	_, err := c.containerURL.GetProperties(ctx, azblob.LeaseAccessConditions{})
+	if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeContainerNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DeleteContainer deletes the container and all of its blobs.
+func (c *Client) DeleteContainer(ctx context.Context) error {
+	_, err := c.containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
+	return err
+}
+
+// Write writes the content of r to the blob key and describes the blob
+// written. opts may be nil. If reading r fails, the upload is aborted and
+// the blob is left unchanged. The ETag and content type are those the blob
+// has right after the write.
+func (c *Client) Write(ctx context.Context, key string, r io.Reader, opts *blob.WriterOptions) (*WriteResult, error) {
+	// Cancelling the writer's context before Close aborts the upload.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := c.bucket.NewWriter(wctx, key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := md5.New()
+	n, err := io.Copy(w, io.TeeReader(r, sum))
+	if err != nil {
+		cancel()
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	attrs, err := c.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &WriteResult{Key: key, Size: n, ETag: attrs.ETag, MD5: sum.Sum(nil), ContentType: attrs.ContentType}, nil
+}
+
+// Stat returns the attributes of the blob key, without reading its content.
+func (c *Client) Stat(ctx context.Context, key string) (*Attributes, error) {
+	attrs, err := c.bucket.Attributes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Attributes{
+		Key:             key,
+		Size:            attrs.Size,
+		ETag:            attrs.ETag,
+		MD5:             attrs.MD5,
+		ContentType:     attrs.ContentType,
+		ContentEncoding: attrs.ContentEncoding,
+		CacheControl:    attrs.CacheControl,
+		ModTime:         attrs.ModTime,
+		Metadata:        attrs.Metadata,
+	}, nil
+}
+
+// Read copies the content of the blob key to w.
+func (c *Client) Read(ctx context.Context, key string, w io.Writer) error {
+	r, err := c.NewRangeReader(ctx, key, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// NewRangeReader returns a reader of length bytes of the blob key from
+// offset. A length of -1 reads to the end of the blob.
+func (c *Client) NewRangeReader(ctx context.Context, key string, offset, length int64) (*blob.Reader, error) {
+	return c.bucket.NewRangeReader(ctx, key, offset, length, nil)
+}
+
+// NewSnapshotRangeReader is like NewRangeReader, but reads the snapshot of
+// the blob key taken at the timestamp snapshot. An empty snapshot reads the
+// blob itself.
+func (c *Client) NewSnapshotRangeReader(ctx context.Context, key, snapshot string, offset, length int64) (*blob.Reader, error) {
+	if snapshot == "" {
+		return c.NewRangeReader(ctx, key, offset, length)
+	}
+	opts := &blob.ReaderOptions{
+		BeforeRead: func(as func(interface{}) bool) error {
+			var u *azblob.BlockBlobURL
+			if !as(&u) {
+				return errors.New("blobstore: reading snapshots is not supported by the bucket")
+			}
+			*u = u.WithSnapshot(snapshot)
+			return nil
+		},
+	}
+	return c.bucket.NewRangeReader(ctx, key, offset, length, opts)
+}
+
+// List calls fn for every entry under prefix, using "/" as the delimiter
+// between directories. With recursive set, directories are descended into
+// right after they are passed to fn, and depth is the number of directories
+// descended into. Listing stops at the first error returned by fn.
+func (c *Client) List(ctx context.Context, prefix string, recursive bool, fn func(obj *blob.ListObject, depth int) error) error {
+	return c.list(ctx, prefix, recursive, false, 0, fn)
+}
+
+// ListIncludingDeleted is like List, but also lists soft-deleted blobs.
+// They can be told apart with Deleted.
+func (c *Client) ListIncludingDeleted(ctx context.Context, prefix string, recursive bool, fn func(obj *blob.ListObject, depth int) error) error {
+	return c.list(ctx, prefix, recursive, true, 0, fn)
+}
+
+// Deleted reports whether the listed blob obj is soft-deleted, and if so
+// returns its properties, which include when it was deleted and how long
+// it can still be restored.
+func Deleted(obj *blob.ListObject) (bool, azblob.BlobProperties) {
+	var item azblob.BlobItemInternal
+	if !obj.As(&item) || !item.Deleted {
+		return false, azblob.BlobProperties{}
+	}
+	return true, item.Properties
+}
+
+func (c *Client) list(ctx context.Context, prefix string, recursive, deleted bool, depth int, fn func(obj *blob.ListObject, depth int) error) error {
+	opts := &blob.ListOptions{
+		Delimiter: "/",
+		Prefix:    prefix,
+	}
+	if deleted {
+		opts.BeforeList = func(as func(interface{}) bool) error {
+			var azOpts *azblob.ListBlobsSegmentOptions
+			if !as(&azOpts) {
+				return errors.New("blobstore: listing deleted blobs is not supported by the bucket")
+			}
+			azOpts.Details.Deleted = true
+			return nil
+		}
+	}
+	iter := c.bucket.List(opts)
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(obj, depth); err != nil {
+			return err
+		}
+		if obj.IsDir && recursive {
+			if err := c.list(ctx, obj.Key, recursive, deleted, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+}
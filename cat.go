@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	catKeys      []string
+	catSeparator string
+
+	// Commands
+	catCmd = &cobra.Command{
+		Use:   "cat",
+		Short: "Concatenate several blobs to stdout",
+		Long: `Concatenate several blobs to stdout.
+
+The blobs given with --blob-key, which can be repeated, or all blobs under
+--blob-prefix are written to stdout one after another in key order, e.g. to
+assemble sharded output:
+
+  azure cat --blob-prefix results/part- > results.csv
+
+Blobs are streamed one at a time, so they are never held in memory. With
+--separator, the string is written between blobs, e.g. $'\n' to end every
+blob with a newline. Only the content is printed, so the output can be
+piped, and the first blob that cannot be read stops the command.
+
+Like read, blobs are verified against their stored MD5 and decompressed if
+gzip, deflate or br encoded, unless --raw is set.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			usePrefix := cmd.Flags().Changed("blob-prefix")
+			if len(catKeys) == 0 && !usePrefix {
+				return fmt.Errorf(`flag "--blob-key" or "--blob-prefix" should be set`)
+			}
+			if len(catKeys) > 0 && usePrefix {
+				return fmt.Errorf(`flags "--blob-key" and "--blob-prefix" cannot be combined`)
+			}
+			for _, key := range catKeys {
+				if key == "" {
+					return fmt.Errorf(`flag "--blob-key" should not be empty`)
+				}
+			}
+
+			keys := append([]string(nil), catKeys...)
+			if usePrefix {
+				warnFullScan(errOut, blobPrefix)
+				items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{})
+				if err != nil {
+					return err
+				}
+				for _, item := range items {
+					keys = append(keys, item.Name)
+				}
+			}
+			sort.Strings(keys)
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			for i, key := range keys {
+				if i > 0 && catSeparator != "" {
+					if _, err := io.WriteString(out, catSeparator); err != nil {
+						return err
+					}
+				}
+				if err := catBlob(ctx, out, bucket, key); err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(errOut, "Concatenated %d blobs\n", len(keys))
+			return nil
+		},
+	}
+)
+
+// catBlob writes the decoded content of the blob key in b to out. Errors
+// name the key, to tell which of the blobs failed.
+func catBlob(ctx context.Context, out io.Writer, b *blob.Bucket, key string) error {
+	r, err := b.NewReader(ctx, key, nil)
+	if err != nil {
+		return readError(key, err)
+	}
+	defer r.Close()
+
+	src, err := decodedReader(r)
+	if err != nil {
+		return fmt.Errorf("reading %q: %v", key, err)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		return fmt.Errorf("reading %q: %v", key, err)
+	}
+	return nil
+}
+
+func init() {
+	catCmd.PersistentFlags().StringArrayVar(&catKeys, "blob-key", nil, "indicate a blob key to concatenate (repeatable)")
+	catCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to concatenate all blobs under")
+	catCmd.PersistentFlags().StringVar(&catSeparator, "separator", "", "indicate a string to write between blobs")
+	catCmd.PersistentFlags().BoolVar(&rawRead, "raw", false, "write encoded blobs without decompressing them")
+	catCmd.PersistentFlags().BoolVar(&assumeYes, "yes", false, "do not warn when concatenating the entire container")
+
+	rootCmd.AddCommand(catCmd)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	force bool
+
+	// Commands
+	clearCmd = &cobra.Command{
+		Use:         "clear",
+		Short:       "Truncate an append blob to zero length",
+		Annotations: mutating,
+		Long: `Truncate an append blob to zero length.
+
+Append blobs can only grow. This command replaces the append blob at
+--blob-key with a fresh, empty append blob, keeping its key, content
+headers and metadata, which is useful for rotating logs. The blob is only
+replaced if it has not been modified since it was inspected. Since all
+content is lost, --force is required.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			if !force {
+				return fmt.Errorf(`clearing %q discards all of its content, set "--force" to proceed`, blobKey)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			blobURL := newBlobURL(containerName, blobKey)
+			props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return err
+			}
+
+			if props.BlobType() != azblob.BlobAppendBlob {
+				return fmt.Errorf("blob %q is a %s, not an append blob", blobKey, props.BlobType())
+			}
+
+			// Recreate the blob only if nobody appended to it in the meantime.
+			_, err = blobURL.ToAppendBlobURL().Create(ctx, props.NewHTTPHeaders(), props.NewMetadata(),
+				azblob.BlobAccessConditions{
+					ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: props.ETag()},
+				}, nil, azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully cleared %q (%s discarded)", blobKey, formatBytes(props.ContentLength())))
+			return nil
+		},
+	}
+)
+
+func init() {
+	clearCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate an append blob key to clear")
+	clearCmd.PersistentFlags().BoolVar(&force, "force", false, "confirm that the content of the blob should be discarded")
+
+	rootCmd.AddCommand(clearCmd)
+}
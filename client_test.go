@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"strings"
+	"testing"
+
+	"gocloud.dev/blob"
+)
+
+func TestClientWriteResult(t *testing.T) {
+	s := newFakeService(t, "test")
+	openFakeBucket(t, s)
+	saved := containerName
+	t.Cleanup(func() { containerName = saved })
+	containerName = "test"
+
+	c, err := openClient(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	content := []byte("hello\n")
+	res, err := c.Write(context.Background(), "greeting", strings.NewReader(string(content)), &blob.WriterOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum(content)
+	b := s.blob("test", "greeting")
+	if res.Key != "greeting" || res.Size != int64(len(content)) || !bytes.Equal(res.MD5, sum[:]) || res.ContentType != "text/plain" {
+		t.Errorf("got %+v", res)
+	}
+	if res.ETag != b.header.Get("ETag") {
+		t.Errorf("got ETag %s, want %s", res.ETag, b.header.Get("ETag"))
+	}
+
+	attrs, err := c.Stat(context.Background(), "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Key != "greeting" || attrs.Size != res.Size || attrs.ETag != res.ETag || attrs.ContentType != "text/plain" {
+		t.Errorf("Stat returned %+v after writing %+v", attrs, res)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// ANSI escape sequences used to colorize output on interactive terminals.
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorBlue   = "\033[34m"
+	colorYellow = "\033[33m"
+)
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports whether output written to w should be colorized.
+// Color is disabled by --no-color, by a non-empty NO_COLOR environment
+// variable (https://no-color.org), and whenever w is not a terminal,
+// including when output is redirected to something other than a file.
+func useColor(w io.Writer) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// colorize wraps s in the given color if output to w should be colorized.
+func colorize(w io.Writer, color, s string) string {
+	if !useColor(w) {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commands
+	compareDirCmd = &cobra.Command{
+		Use:   "compare-dir",
+		Short: "Compare a local directory with the blobs under a prefix",
+		Long: `Compare a local directory with the blobs under a prefix.
+
+Every regular file under --local-dir is matched with the blob named
+--blob-prefix followed by its relative path, with "/" separators. The keys
+are reported in three groups, as by container-diff with the directory as
+the source: files missing from the container, blobs missing from the
+directory, and files whose content differs from their blob. Content is
+compared by size and, when the blob has a stored MD5, by the MD5 of the
+file, computed for --concurrency files at a time.
+
+The command exits with a non-zero status if any difference is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if localDir == "" {
+				return fmt.Errorf(`flag "--local-dir" should be set`)
+			}
+
+			if outputFormat != "text" && outputFormat != "json" {
+				return fmt.Errorf(`flag "--output" should be one of "text" or "json"`)
+			}
+
+			// Map keys to the local files they correspond to
+			files := make(map[string]string)
+			err := filepath.Walk(localDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+				rel, err := filepath.Rel(localDir, path)
+				if err != nil {
+					return err
+				}
+				files[blobPrefix+filepath.ToSlash(rel)] = path
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			objs, err := listContainer(ctx, containerName, blobPrefix)
+			if err != nil {
+				return err
+			}
+
+			diff := &containerDiff{
+				OnlyInSource: []string{},
+				OnlyInDest:   []string{},
+				Differing:    []blobDiffers{},
+			}
+
+			// Compare sizes first and hash only the files that could match
+			var toHash []string
+			for key, path := range files {
+				obj, ok := objs[key]
+				if !ok {
+					diff.OnlyInSource = append(diff.OnlyInSource, key)
+					continue
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				switch {
+				case info.Size() != obj.Size:
+					diff.Differing = append(diff.Differing, blobDiffers{
+						Key:    key,
+						Reason: fmt.Sprintf("size %d != %d", info.Size(), obj.Size),
+					})
+				case obj.MD5 != nil:
+					toHash = append(toHash, key)
+				}
+			}
+			for key := range objs {
+				if _, ok := files[key]; !ok {
+					diff.OnlyInDest = append(diff.OnlyInDest, key)
+				}
+			}
+
+			sums := make([][]byte, len(toHash))
+			errs := runPool(len(toHash), concurrency, func(i int) error {
+				sum, err := fileMD5(files[toHash[i]])
+				sums[i] = sum
+				return err
+			})
+			if len(errs) > 0 {
+				return errs[0].Err
+			}
+			for i, key := range toHash {
+				if !bytes.Equal(sums[i], objs[key].MD5) {
+					diff.Differing = append(diff.Differing, blobDiffers{
+						Key:    key,
+						Reason: fmt.Sprintf("md5 %x != %x", sums[i], objs[key].MD5),
+					})
+				}
+			}
+
+			sort.Strings(diff.OnlyInSource)
+			sort.Strings(diff.OnlyInDest)
+			sort.Slice(diff.Differing, func(i, j int) bool {
+				return diff.Differing[i].Key < diff.Differing[j].Key
+			})
+
+			if outputFormat == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(diff); err != nil {
+					return err
+				}
+			} else {
+				printKeys := func(title string, keys []string) {
+					fmt.Fprintf(out, "%s (%d):\n", title, len(keys))
+					for _, key := range keys {
+						fmt.Fprintf(out, "  %s\n", key)
+					}
+				}
+				printKeys(fmt.Sprintf("Only in %q", localDir), diff.OnlyInSource)
+				printKeys(fmt.Sprintf("Only in %q", containerName), diff.OnlyInDest)
+				fmt.Fprintf(out, "Differing (%d):\n", len(diff.Differing))
+				for _, d := range diff.Differing {
+					fmt.Fprintf(out, "  %s (%s)\n", d.Key, d.Reason)
+				}
+			}
+
+			if !diff.empty() {
+				return &exitError{Code: 1}
+			}
+
+			logger.Info(fmt.Sprintf("Directory %q and container %q are identical", localDir, containerName))
+			return nil
+		},
+	}
+)
+
+func init() {
+	compareDirCmd.PersistentFlags().StringVar(&localDir, "local-dir", "", "indicate a local directory to compare")
+	compareDirCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix the directory corresponds to")
+	compareDirCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "indicate an output format (text or json)")
+
+	rootCmd.AddCommand(compareDirCmd)
+}
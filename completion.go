@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds the requests made to complete flag values, so
+// that pressing tab never hangs the shell on an unreachable account.
+const completionTimeout = 5 * time.Second
+
+var (
+	// Execute registers the flag completions on its first call
+	registerCompletionsOnce sync.Once
+
+	// Commands
+	completionCmd = &cobra.Command{
+		Use:         "completion [bash|zsh|fish|powershell]",
+		Short:       "Generate a shell completion script",
+		Annotations: accountLevel,
+		Long: `Generate a shell completion script.
+
+The script for the given shell is written to stdout. Besides commands and
+flags, it completes the values of --container-name with the containers of
+the account and of --blob-key with the blobs of the container, one "/" level
+at a time, which requires the account to be configured in the environment.
+
+To load completions in the current bash session:
+
+  source <(azure completion bash)
+
+To load them for every session, write the script to the completion
+directory of the shell, e.g. for zsh:
+
+  azure completion zsh > "${fpath[1]}/_azure"`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.ExactValidArgs(1),
+		DisableFlagsInUseLine: true,
+		// Generating the script needs no account
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			switch args[0] {
+			case "bash":
+				return rootCmd.GenBashCompletion(out)
+			case "zsh":
+				return rootCmd.GenZshCompletion(out)
+			case "fish":
+				return rootCmd.GenFishCompletion(out, true)
+			default:
+				return rootCmd.GenPowerShellCompletion(out)
+			}
+		},
+	}
+)
+
+// registerCompletions registers the dynamic completion of --container-name
+// and of --blob-key on every command that has it. It runs once all commands
+// have been added to rootCmd.
+func registerCompletions() {
+	if err := rootCmd.RegisterFlagCompletionFunc("container-name", completeContainerNames); err != nil {
+		panic(err)
+	}
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if cmd.LocalFlags().Lookup("blob-key") != nil {
+			if err := cmd.RegisterFlagCompletionFunc("blob-key", completeBlobKeys); err != nil {
+				panic(err)
+			}
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(rootCmd)
+}
+
+// completionContext configures the account like rootCmd does before running
+// a command, which doesn't happen when completing, and returns a context
+// bounded by completionTimeout.
+func completionContext(cmd *cobra.Command) (context.Context, context.CancelFunc, error) {
+	if err := resolveAccount(cmd); err != nil {
+		return nil, nil, err
+	}
+	if err := checkAuthMode(); err != nil {
+		return nil, nil, err
+	}
+	if err := checkAccount(); err != nil {
+		return nil, nil, err
+	}
+	if err := initPipeline(); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	return ctx, cancel, nil
+}
+
+// completeContainerNames completes --container-name with the containers of
+// the account starting with toComplete.
+func completeContainerNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, err := completionContext(cmd)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer cancel()
+
+	// A single segment is plenty to pick from
+	svcURL := azblob.NewServiceURL(serviceURL(), pline)
+	resp, err := svcURL.ListContainersSegment(ctx, azblob.Marker{}, azblob.ListContainersSegmentOptions{
+		Prefix: toComplete,
+	})
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, item := range resp.ContainerItems {
+		names = append(names, item.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeBlobKeys completes --blob-key with the blobs of the container
+// starting with toComplete. Blobs are listed one "/" level at a time, so
+// that large containers complete like directories.
+func completeBlobKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, err := completionContext(cmd)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer cancel()
+
+	resp, err := newContainerURL(containerName).ListBlobsHierarchySegment(ctx, azblob.Marker{}, "/",
+		azblob.ListBlobsSegmentOptions{Prefix: toComplete, MaxResults: listPageSize})
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var keys []string
+	for _, item := range resp.Segment.BlobItems {
+		keys = append(keys, item.Name)
+	}
+	directive := cobra.ShellCompDirectiveNoFileComp
+	for _, prefix := range resp.Segment.BlobPrefixes {
+		keys = append(keys, prefix.Name)
+		// Don't end the word after a "directory", so that it can be completed further
+		if strings.HasSuffix(prefix.Name, "/") {
+			directive |= cobra.ShellCompDirectiveNoSpace
+		}
+	}
+	return keys, directive
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
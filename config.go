@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob/azureblob"
+)
+
+const (
+	// defaultConfigName is the name of the config file in the home directory.
+	defaultConfigName = ".azure-cli.yaml"
+	// defaultProfile is the profile of the config file used without --profile.
+	defaultProfile = "default"
+)
+
+// accountLevelAnnotation is set in the Annotations of commands that work on
+// the account rather than on a container, so that they run without a
+// container configured.
+const accountLevelAnnotation = "account-level"
+
+// accountLevel is used as the Annotations of commands that don't use
+// --container-name.
+var accountLevel = map[string]string{accountLevelAnnotation: "true"}
+
+// The keys of the config file, named after the flags they provide defaults
+// for.
+var configKeys = []string{"account-name", "account-key", "endpoint-suffix", "container-name"}
+
+var (
+	// Flags
+	configFile string
+	profile    string
+
+	// creatingProfile is set by init, which may write a profile that
+	// doesn't exist yet.
+	creatingProfile bool
+
+	// accountConfigSource describes the config file and profile the account
+	// name was read from, if any.
+	accountConfigSource string
+
+	// Commands
+	initCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Write a config file with the account and default container",
+		Long: `Write a config file with the account and default container.
+
+The account name, account key, endpoint suffix and container name that
+commands would use, given the flags and environment variables, are written
+to the --profile profile of --config, ~/` + defaultConfigName + ` by default, so
+that they needn't be passed every time. Later commands read the profile
+selected by their --profile, but flags and environment variables still
+take precedence over it.
+
+A config file holds any number of profiles, e.g. one per account, and init
+leaves the other profiles of the file in place. The file is only readable
+by its owner, since it holds account keys. An existing profile is only
+replaced with --force.`,
+		// Only resolve the account, since init creates the configuration
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if err := initLogger(cmd.ErrOrStderr()); err != nil {
+				return err
+			}
+			creatingProfile = true
+			return resolveAccount(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkAccount(); err != nil {
+				return err
+			}
+
+			path, err := configPath()
+			if err != nil {
+				return err
+			}
+			profiles, err := readConfigFile(path)
+			if os.IsNotExist(err) {
+				profiles = make(map[string]map[string]string)
+			} else if err != nil {
+				return err
+			}
+			if _, ok := profiles[profile]; ok && !force {
+				return fmt.Errorf("profile %q already exists in config file %q, use \"--force\" to replace it", profile, path)
+			}
+
+			values := map[string]string{
+				"account-name": string(accountName),
+			}
+			if containerName != "" {
+				values["container-name"] = containerName
+			}
+			if sasToken == "" && !useAAD() {
+				values["account-key"] = string(accountKey)
+			}
+			// The emulator and custom service URLs have no endpoint suffix
+			if d := string(storageDomain); strings.HasPrefix(d, "blob.") && customServiceURL == nil {
+				values["endpoint-suffix"] = strings.TrimPrefix(d, "blob.")
+			}
+			profiles[profile] = values
+			if err := writeConfigFile(path, profiles); err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully written profile %q to config file %q", profile, path))
+			return nil
+		},
+	}
+)
+
+// configPath returns the path of the config file, --config or the default
+// one in the home directory.
+func configPath() (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find the config file: %v", err)
+	}
+	return filepath.Join(home, defaultConfigName), nil
+}
+
+// loadConfigFile sets the account, endpoint suffix and container from the
+// --profile profile of the config file, for those that are neither set by a
+// flag nor by an environment variable. A missing default config file or
+// default profile is ignored, but a missing --config or a profile selected
+// with --profile is an error.
+func loadConfigFile(cmd *cobra.Command) error {
+	if err := checkProfileName(profile); err != nil {
+		return fmt.Errorf(`flag "--profile": %v`, err)
+	}
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	profiles, err := readConfigFile(path)
+	if os.IsNotExist(err) {
+		if creatingProfile || (configFile == "" && !cmd.Flags().Changed("profile")) {
+			return nil
+		}
+		return fmt.Errorf("config file %q doesn't exist, create it with init", path)
+	}
+	if err != nil {
+		return err
+	}
+	values, ok := profiles[profile]
+	if !ok {
+		if creatingProfile || !cmd.Flags().Changed("profile") {
+			return nil
+		}
+		return fmt.Errorf("no profile %q in config file %q, the profiles are: %s", profile, path, strings.Join(profileNames(profiles), ", "))
+	}
+
+	flags := cmd.Flags()
+	if v := values["account-name"]; v != "" && !flags.Changed("account-name") && os.Getenv("AZURE_STORAGE_ACCOUNT") == "" {
+		accountName = azureblob.AccountName(v)
+		accountConfigSource = fmt.Sprintf("config file %q, profile %q", path, profile)
+	}
+	if v := values["account-key"]; v != "" && !flags.Changed("account-key") && os.Getenv("AZURE_STORAGE_KEY") == "" {
+		accountKey = azureblob.AccountKey(v)
+	}
+	if v := values["endpoint-suffix"]; v != "" && !flags.Changed("endpoint-suffix") {
+		if err := setEndpointSuffix(v); err != nil {
+			return fmt.Errorf("config file %q: %v", path, err)
+		}
+	}
+	if v := values["container-name"]; v != "" && !flags.Changed("container-name") {
+		containerName = v
+	}
+	return nil
+}
+
+// checkContainer returns an error unless cmd works on the account only or a
+// container is configured, by --container-name or else by the container-name
+// of the --profile profile of the config file.
+func checkContainer(cmd *cobra.Command) error {
+	if _, ok := cmd.Annotations[accountLevelAnnotation]; ok || containerName != "" {
+		return nil
+	}
+	return fmt.Errorf(`no container is configured, set "--container-name" or the container-name of profile %q of the config file, e.g. with init`, profile)
+}
+
+// readConfigFile reads the config file at path, a YAML mapping of profile
+// names to mappings of the keys in configKeys to string values:
+//
+//	prod:
+//	  account-name: "prodaccount"
+//	  container-name: "logs"
+//
+// Values may be quoted, blank lines and lines starting with "#" are
+// skipped. Keys that are not indented under a profile belong to the
+// default profile, as written by earlier versions of init.
+func readConfigFile(path string) (map[string]map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[string]map[string]string)
+	current := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		indented := strings.TrimLeft(raw, " \t") != raw
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("config file %q: line %d: expected key: value, got %q", path, n, line)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if !indented {
+			current = ""
+			if value == "" {
+				// A profile starts
+				if err := checkProfileName(key); err != nil {
+					return nil, fmt.Errorf("config file %q: line %d: %v", path, n, err)
+				}
+				current = key
+				if profiles[current] == nil {
+					profiles[current] = make(map[string]string)
+				}
+				continue
+			}
+		}
+		profile := current
+		if profile == "" {
+			if indented {
+				return nil, fmt.Errorf("config file %q: line %d: indented key %q is not under a profile", path, n, key)
+			}
+			profile = defaultProfile
+		}
+
+		if !isConfigKey(key) {
+			return nil, fmt.Errorf("config file %q: line %d: unknown key %q, should be one of %s", path, n, key, strings.Join(configKeys, ", "))
+		}
+		switch {
+		case strings.HasPrefix(value, `"`):
+			if value, err = strconv.Unquote(value); err != nil {
+				return nil, fmt.Errorf("config file %q: line %d: invalid quoted value", path, n)
+			}
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+		if profiles[profile] == nil {
+			profiles[profile] = make(map[string]string)
+		}
+		profiles[profile][key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// writeConfigFile writes profiles to the config file at path, readable only
+// by its owner.
+func writeConfigFile(path string, profiles map[string]map[string]string) error {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, `# Written by "azure init". Flags and environment variables take precedence.`)
+	for _, name := range profileNames(profiles) {
+		fmt.Fprintf(&buf, "%s:\n", name)
+		for _, key := range configKeys {
+			if v, ok := profiles[name][key]; ok {
+				fmt.Fprintf(&buf, "  %s: %s\n", key, strconv.Quote(v))
+			}
+		}
+	}
+
+	// WriteFile keeps the permissions of an existing file, so restrict them
+	// before the key is written
+	if err := os.Chmod(path, 0600); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// checkProfileName returns an error if name can't be used as a profile.
+func checkProfileName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t:#\"'") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
+
+// profileNames returns the sorted names of profiles.
+func profileNames(profiles map[string]map[string]string) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isConfigKey reports whether key is one of configKeys.
+func isConfigKey(key string) bool {
+	for _, k := range configKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", defaultProfile, "indicate a profile of the config file to use")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "indicate a config file with the account and default container (~/"+defaultConfigName+" if empty)")
+
+	initCmd.PersistentFlags().BoolVar(&force, "force", false, "replace an existing config file")
+
+	rootCmd.AddCommand(initCmd)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// effectiveConfig is the configuration commands run with, without secrets.
+type effectiveConfig struct {
+	Account          string `json:"account"`
+	AccountSource    string `json:"accountSource"`
+	Endpoint         string `json:"endpoint"`
+	Auth             string `json:"auth"`
+	Credential       string `json:"credential"`
+	Container        string `json:"container"`
+	Concurrency      int    `json:"concurrency"`
+	ReadOnly         bool   `json:"readOnly"`
+	RetryStatusCodes []int  `json:"retryStatusCodes"`
+}
+
+var (
+	// Commands
+	configShowCmd = &cobra.Command{
+		Use:         "config-show",
+		Short:       "Show the configuration commands would use",
+		Annotations: accountLevel,
+		Long: `Show the configuration commands would use.
+
+The account, endpoint, authentication mode and defaults are printed after
+merging the flags, environment variables, connection string and config
+file, along with where the account came from, to debug why a command
+targets the wrong account. The account key and the signature of a SAS
+token are never printed. Unlike other commands, config-show also works when no account is
+configured.`,
+		// Only resolve the account, so that a missing account can be shown
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if err := initLogger(cmd.ErrOrStderr()); err != nil {
+				return err
+			}
+			return resolveAccount(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if outputFormat != "text" && outputFormat != "json" {
+				return fmt.Errorf(`flag "--output" should be one of "text" or "json"`)
+			}
+
+			config := effectiveConfig{
+				Account:          string(accountName),
+				AccountSource:    accountSource(cmd),
+				Endpoint:         redactedURL(serviceURL()),
+				Auth:             "shared key",
+				Credential:       "account key (redacted)",
+				Container:        containerName,
+				Concurrency:      concurrency,
+				ReadOnly:         isReadOnly(),
+				RetryStatusCodes: retryStatusCodes,
+			}
+			switch {
+			case useAAD():
+				config.Auth = "Azure AD"
+				config.Credential = aadCredentialSource()
+			case sasToken != "":
+				config.Auth = "SAS token"
+				config.Credential = redactSASToken(sasToken)
+			case accountKey == "" || accountKey == defaultAccountKey:
+				config.Credential = "none"
+			}
+			if accountName == "" || accountName == defaultAccountName {
+				config.Account = "none"
+			}
+			if containerName == "" {
+				config.Container = "none"
+			}
+
+			if outputFormat == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(config)
+			}
+
+			printConfig(out, config)
+			return nil
+		},
+	}
+)
+
+// accountSource describes where the account name was taken from.
+func accountSource(cmd *cobra.Command) string {
+	switch {
+	case connectionString != "" && cmd.Flags().Changed("connection-string"):
+		return `flag "--connection-string"`
+	case connectionString != "":
+		return "AZURE_STORAGE_CONNECTION_STRING"
+	case cmd.Flags().Changed("account-name"):
+		return `flag "--account-name"`
+	case emulator:
+		return `flag "--emulator"`
+	case accountConfigSource != "":
+		return accountConfigSource
+	case accountName != defaultAccountName:
+		return "AZURE_STORAGE_ACCOUNT"
+	}
+	return "not configured"
+}
+
+// redactSASToken returns the SAS token with its signature redacted, keeping
+// the permissions and validity, which help to debug authorization failures.
+func redactSASToken(token string) string {
+	query, err := url.ParseQuery(strings.TrimPrefix(token, "?"))
+	if err != nil {
+		return "SAS token (redacted)"
+	}
+	if query.Get("sig") != "" {
+		query.Set("sig", "REDACTED")
+	}
+	return query.Encode()
+}
+
+// printConfig prints config as aligned "name: value" lines.
+func printConfig(out io.Writer, config effectiveConfig) {
+	codes := "none"
+	if len(config.RetryStatusCodes) > 0 {
+		codes = strings.Trim(fmt.Sprint(config.RetryStatusCodes), "[]")
+	}
+	for _, line := range [][2]string{
+		{"Account", config.Account},
+		{"Account source", config.AccountSource},
+		{"Endpoint", config.Endpoint},
+		{"Auth", config.Auth},
+		{"Credential", config.Credential},
+		{"Container", config.Container},
+		{"Concurrency", fmt.Sprint(config.Concurrency)},
+		{"Read-only", fmt.Sprint(config.ReadOnly)},
+		{"Retry status codes", codes},
+	} {
+		fmt.Fprintf(out, "%-19s %s\n", line[0]+":", line[1])
+	}
+}
+
+func init() {
+	configShowCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "indicate an output format (text or json)")
+
+	rootCmd.AddCommand(configShowCmd)
+}
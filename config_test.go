@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileDefaultContainer(t *testing.T) {
+	s := newFakeService(t, "logs", "other")
+	useTestAccount(t)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	content := "prod:\n  container-name: \"logs\"\nbare:\n  endpoint-suffix: \"core.windows.net\"\n"
+	if err := ioutil.WriteFile(config, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	account := []string{"--account-name", "testaccount", "--account-key", "a2V5", "--service-url", s.URL, "--config", config}
+	run := func(args ...string) error {
+		_, _, err := executeArgs(t, append(append([]string{}, args...), account...))
+		return err
+	}
+
+	// The container of the profile is used without --container-name
+	if err := run("write", "--blob-key", "k", "--blob-value", "v", "--profile", "prod"); err != nil {
+		t.Fatalf("write with the container of the profile: %v", err)
+	}
+	if s.blob("logs", "k") == nil {
+		t.Error("write didn't write to the container of the profile")
+	}
+
+	// --container-name takes precedence over the profile
+	if err := run("write", "--blob-key", "k2", "--blob-value", "v", "--profile", "prod", "--container-name", "other"); err != nil {
+		t.Fatalf("write with --container-name: %v", err)
+	}
+	if s.blob("other", "k2") == nil || s.blob("logs", "k2") != nil {
+		t.Error("write with --container-name didn't write to that container")
+	}
+
+	// Without either, commands on a container fail
+	err := run("write", "--blob-key", "k3", "--blob-value", "v", "--profile", "bare")
+	if err == nil || !strings.Contains(err.Error(), "no container is configured") {
+		t.Errorf("write without a container: got error %v, want no container to be configured", err)
+	}
+	// but commands on the account don't need one
+	if err := run("version", "--profile", "bare"); err != nil {
+		t.Errorf("version without a container: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gocloud.dev/blob/azureblob"
+)
+
+// defaultEndpointSuffix is the endpoint suffix of the public Azure cloud.
+const defaultEndpointSuffix = "core.windows.net"
+
+// parseConnectionString sets the account, protocol and storage domain from
+// an Azure Storage connection string such as
+// "DefaultEndpointsProtocol=https;AccountName=...;AccountKey=...;EndpointSuffix=core.windows.net".
+// Settings other than these are ignored.
+func parseConnectionString(s string) error {
+	settings := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		// Keys are base64 and may end with '=', so only split at the first one
+		i := strings.Index(part, "=")
+		if i < 0 {
+			return fmt.Errorf("invalid connection string setting %q, should be NAME=VALUE", part)
+		}
+		settings[strings.TrimSpace(part[:i])] = strings.TrimSpace(part[i+1:])
+	}
+
+	name, key := settings["AccountName"], settings["AccountKey"]
+	if name == "" || key == "" {
+		return fmt.Errorf("connection string should have an AccountName and an AccountKey")
+	}
+
+	protocol := settings["DefaultEndpointsProtocol"]
+	switch protocol {
+	case "":
+		protocol = "https"
+	case "http", "https":
+	default:
+		return fmt.Errorf("invalid DefaultEndpointsProtocol %q in connection string, should be http or https", protocol)
+	}
+
+	suffix := settings["EndpointSuffix"]
+	if suffix == "" {
+		suffix = defaultEndpointSuffix
+	}
+
+	accountName = azureblob.AccountName(name)
+	accountKey = azureblob.AccountKey(key)
+	storageProtocol = azureblob.Protocol(protocol)
+	storageDomain = azureblob.StorageDomain("blob." + suffix)
+	return nil
+}
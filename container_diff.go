@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	sourceContainer string
+	destContainer   string
+	outputFormat    string
+
+	// Commands
+	containerDiffCmd = &cobra.Command{
+		Use:         "container-diff",
+		Short:       "Compare two containers and report differences",
+		Annotations: accountLevel,
+		Long: `Compare two containers and report differences.
+
+Both containers are listed under --blob-prefix and the keys are reported in
+three groups: keys only in the source, keys only in the destination, and
+keys present in both whose content differs. Content is compared by size
+and, when both blobs have one, by their stored MD5. ETags are not compared
+since they always differ between containers.
+
+The command exits with a non-zero status if any difference is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if sourceContainer == "" {
+				return fmt.Errorf(`flag "--source-container" should be set`)
+			}
+
+			if destContainer == "" {
+				return fmt.Errorf(`flag "--dest-container" should be set`)
+			}
+
+			if outputFormat != "text" && outputFormat != "json" {
+				return fmt.Errorf(`flag "--output" should be one of "text" or "json"`)
+			}
+
+			src, err := listContainer(ctx, sourceContainer, blobPrefix)
+			if err != nil {
+				return err
+			}
+
+			dst, err := listContainer(ctx, destContainer, blobPrefix)
+			if err != nil {
+				return err
+			}
+
+			diff := diffListings(src, dst)
+			if outputFormat == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(diff); err != nil {
+					return err
+				}
+			} else {
+				printKeys := func(title string, keys []string) {
+					fmt.Fprintf(out, "%s (%d):\n", title, len(keys))
+					for _, key := range keys {
+						fmt.Fprintf(out, "  %s\n", key)
+					}
+				}
+				printKeys(fmt.Sprintf("Only in %q", sourceContainer), diff.OnlyInSource)
+				printKeys(fmt.Sprintf("Only in %q", destContainer), diff.OnlyInDest)
+				fmt.Fprintf(out, "Differing (%d):\n", len(diff.Differing))
+				for _, d := range diff.Differing {
+					fmt.Fprintf(out, "  %s (%s)\n", d.Key, d.Reason)
+				}
+			}
+
+			if !diff.empty() {
+				return &exitError{Code: 1}
+			}
+
+			logger.Info(fmt.Sprintf("Containers %q and %q are identical", sourceContainer, destContainer))
+			return nil
+		},
+	}
+)
+
+// containerDiff is the result of comparing the listings of two containers.
+type containerDiff struct {
+	OnlyInSource []string      `json:"onlyInSource"`
+	OnlyInDest   []string      `json:"onlyInDest"`
+	Differing    []blobDiffers `json:"differing"`
+}
+
+// blobDiffers describes a key present in both containers with different content.
+type blobDiffers struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+func (d *containerDiff) empty() bool {
+	return len(d.OnlyInSource) == 0 && len(d.OnlyInDest) == 0 && len(d.Differing) == 0
+}
+
+// listContainer lists all blobs under prefix in the named container,
+// keyed by blob key.
+func listContainer(ctx context.Context, name, prefix string) (map[string]*blob.ListObject, error) {
+	bucket, err := openContainer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer bucket.Close()
+
+	objs := make(map[string]*blob.ListObject)
+	iter := bucket.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing container %q: %v", name, err)
+		}
+		objs[obj.Key] = obj
+	}
+	return objs, nil
+}
+
+// diffListings compares two container listings. All key lists in the
+// result are sorted.
+func diffListings(src, dst map[string]*blob.ListObject) *containerDiff {
+	diff := &containerDiff{
+		OnlyInSource: []string{},
+		OnlyInDest:   []string{},
+		Differing:    []blobDiffers{},
+	}
+
+	for key, s := range src {
+		d, ok := dst[key]
+		if !ok {
+			diff.OnlyInSource = append(diff.OnlyInSource, key)
+			continue
+		}
+		switch {
+		case s.Size != d.Size:
+			diff.Differing = append(diff.Differing, blobDiffers{
+				Key:    key,
+				Reason: fmt.Sprintf("size %d != %d", s.Size, d.Size),
+			})
+		case s.MD5 != nil && d.MD5 != nil && !bytes.Equal(s.MD5, d.MD5):
+			diff.Differing = append(diff.Differing, blobDiffers{
+				Key:    key,
+				Reason: fmt.Sprintf("md5 %x != %x", s.MD5, d.MD5),
+			})
+		}
+	}
+	for key := range dst {
+		if _, ok := src[key]; !ok {
+			diff.OnlyInDest = append(diff.OnlyInDest, key)
+		}
+	}
+
+	sort.Strings(diff.OnlyInSource)
+	sort.Strings(diff.OnlyInDest)
+	sort.Slice(diff.Differing, func(i, j int) bool {
+		return diff.Differing[i].Key < diff.Differing[j].Key
+	})
+	return diff
+}
+
+func init() {
+	containerDiffCmd.PersistentFlags().StringVar(&sourceContainer, "source-container", "", "indicate a name of the container to compare from")
+	containerDiffCmd.PersistentFlags().StringVar(&destContainer, "dest-container", "", "indicate a name of the container to compare against")
+	containerDiffCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to scope the comparison to")
+	containerDiffCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "indicate an output format (text or json)")
+
+	rootCmd.AddCommand(containerDiffCmd)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commands
+	containerExistsCmd = &cobra.Command{
+		Use:   "container-exists",
+		Short: "Check whether a container exists",
+		Long: `Check whether a container exists.
+
+The command exits with status 0 if --container-name exists and 1 if it
+doesn't, as blob-exists does for blobs. If the check itself fails, it exits
+with status 2. With --quiet, nothing is printed and only the exit status
+reports the outcome.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// fail reports err unless --quiet is set and exits with status 2.
+			fail := func(err error) error {
+				if !quiet {
+					logger.Error(err.Error())
+				}
+				return &exitError{Code: 2}
+			}
+
+			client, release, err := getClient(ctx)
+			if err != nil {
+				return fail(err)
+			}
+			defer release()
+
+			exists, err := client.ContainerExists(ctx)
+			if err != nil {
+				return fail(err)
+			}
+
+			if !exists {
+				if !quiet {
+					logger.Info(fmt.Sprintf("Container %q does not exist", containerName))
+				}
+				return &exitError{Code: 1}
+			}
+
+			if !quiet {
+				logger.Info(fmt.Sprintf("Container %q exists", containerName))
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(containerExistsCmd)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	clearMetadata bool
+
+	// Commands
+	setContainerMetadataCmd = &cobra.Command{
+		Use:         "set-container-metadata",
+		Short:       "Set the metadata of a container",
+		Annotations: mutating,
+		Long: `Set the metadata of a container.
+
+Unlike set-metadata for blobs, the metadata of the container is replaced by
+the --meta NAME=VALUE pairs, as Azure sets container metadata as a whole:
+names that are not given are removed, and pairs with an empty value are
+left out. Use --clear instead of --meta to remove all metadata. Names must
+be valid C# identifiers and are case-insensitive. With --meta-file, the
+pairs are loaded from a JSON object of string values and merged with the
+--meta pairs, which take precedence.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if len(metaPairs) == 0 && metaFile == "" && !clearMetadata {
+				return fmt.Errorf(`flag "--meta" or "--clear" should be set`)
+			}
+			if (len(metaPairs) > 0 || metaFile != "") && clearMetadata {
+				return fmt.Errorf(`flag "--meta" cannot be combined with "--clear"`)
+			}
+			pairs, err := metadataOfFlags()
+			if err != nil {
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			metadata := azblob.Metadata{}
+			for name, value := range pairs {
+				if value != "" {
+					metadata[strings.ToLower(name)] = value
+				}
+			}
+
+			_, err = newContainerURL(containerName).SetMetadata(ctx, metadata, azblob.ContainerAccessConditions{})
+			if err != nil {
+				return err
+			}
+
+			if len(metadata) == 0 {
+				logger.Info(fmt.Sprintf("Successfully cleared metadata of container %q", containerName))
+				return nil
+			}
+			logger.Info(fmt.Sprintf("Successfully set metadata of container %q", containerName))
+			return nil
+		},
+	}
+
+	getContainerMetadataCmd = &cobra.Command{
+		Use:   "get-container-metadata",
+		Short: "Print the metadata of a container",
+		Long: `Print the metadata of a container.
+
+The metadata of the container is printed as NAME=VALUE lines sorted by
+name.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			props, err := newContainerURL(containerName).GetProperties(ctx, azblob.LeaseAccessConditions{})
+			if err != nil {
+				return err
+			}
+
+			printMetadata(cmd.OutOrStdout(), props.NewMetadata())
+			return nil
+		},
+	}
+)
+
+func init() {
+	setContainerMetadataCmd.PersistentFlags().StringArrayVar(&metaPairs, "meta", nil, "indicate a NAME=VALUE metadata pair to set (repeatable)")
+	setContainerMetadataCmd.PersistentFlags().StringVar(&metaFile, "meta-file", "", "indicate a JSON file of metadata pairs to set, overridden by --meta")
+	setContainerMetadataCmd.PersistentFlags().BoolVar(&clearMetadata, "clear", false, "remove all metadata of the container")
+
+	rootCmd.AddCommand(setContainerMetadataCmd)
+	rootCmd.AddCommand(getContainerMetadataCmd)
+}
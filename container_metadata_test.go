@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestContainerMetadataReplaced(t *testing.T) {
+	s := newFakeService(t, "test")
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"--meta", "Owner=ops", "--meta", "env=prod"}, want: "env=prod\nowner=ops\n"},
+		// Names that are not given are removed
+		{args: []string{"--meta", "team=storage"}, want: "team=storage\n"},
+		{args: []string{"--clear"}, want: ""},
+	}
+	for _, step := range steps {
+		if _, _, err := executeFake(t, s, append([]string{"set-container-metadata"}, step.args...)...); err != nil {
+			t.Fatalf("set-container-metadata %v: %v", step.args, err)
+		}
+		got, _, err := executeFake(t, s, "get-container-metadata")
+		if err != nil {
+			t.Fatalf("get-container-metadata: %v", err)
+		}
+		if got != step.want {
+			t.Errorf("after set-container-metadata %v, got metadata %q, want %q", step.args, got, step.want)
+		}
+	}
+}
+
+func TestContainerMetadataInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"--meta", "novalue"},
+		{"--meta", "1st=x"},
+		{"--meta", "a=b", "--clear"},
+	} {
+		if _, _, err := execute(t, append([]string{"set-container-metadata"}, args...)...); err == nil {
+			t.Errorf("set-container-metadata %v succeeded", args)
+		}
+	}
+}
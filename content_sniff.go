@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+var (
+	// Flags
+	detectMagic bool
+)
+
+// sniffLen is the number of leading bytes the content type is sniffed from.
+const sniffLen = 512
+
+// magicTypes are the content types of formats http.DetectContentType
+// doesn't know, by the magic bytes found at offset in their content.
+var magicTypes = []struct {
+	offset      int
+	magic       string
+	contentType string
+}{
+	{0, "PAR1", "application/vnd.apache.parquet"},
+	{0, "Obj\x01", "application/avro"},
+	{0, "SQLite format 3\x00", "application/vnd.sqlite3"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{257, "ustar", "application/x-tar"},
+}
+
+// sniffContentType returns the content type of the content of r, from its
+// magic bytes or else as http.DetectContentType finds it, along with a
+// reader of the whole content, including the bytes sniffed.
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	return detectContentType(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// detectContentType returns the content type of content that starts with
+// head.
+func detectContentType(head []byte) string {
+	for _, m := range magicTypes {
+		if len(head) >= m.offset && bytes.HasPrefix(head[m.offset:], []byte(m.magic)) {
+			return m.contentType
+		}
+	}
+	return http.DetectContentType(head)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar\x0000")
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"parquet", []byte("PAR1\x15\x04"), "application/vnd.apache.parquet"},
+		{"tar", tar, "application/x-tar"},
+		{"short", []byte("ust"), "text/plain; charset=utf-8"},
+		// Formats http.DetectContentType knows are left to it
+		{"png", []byte("\x89PNG\x0d\x0a\x1a\x0a"), "image/png"},
+		{"text", []byte("hello, world\n"), "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		if got := detectContentType(tt.head); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSniffContentTypeKeepsContent(t *testing.T) {
+	content := "PAR1" + strings.Repeat("x", 2*sniffLen)
+	contentType, r, err := sniffContentType(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/vnd.apache.parquet" {
+		t.Errorf("got content type %q", contentType)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("got %d bytes back, want the %d bytes sniffed", len(got), len(content))
+	}
+}
+
+func TestUploadFileDetectMagic(t *testing.T) {
+	s := newFakeService(t, "test")
+	path := filepath.Join(t.TempDir(), "data")
+	content := append([]byte("PAR1"), bytes.Repeat([]byte{0}, 100)...)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := executeFake(t, s, "upload-file", "--blob-key", "data", "--file", path, "--content-type-detect-magic"); err != nil {
+		t.Fatalf("upload-file --content-type-detect-magic: %v", err)
+	}
+	b := s.blob("test", "data")
+	if b == nil {
+		t.Fatal("upload-file wrote no blob")
+	}
+	if got := b.header.Get("Content-Type"); got != "application/vnd.apache.parquet" {
+		t.Errorf("blob has Content-Type %q", got)
+	}
+	if !bytes.Equal(b.content, content) {
+		t.Errorf("blob has %d bytes, want %d", len(b.content), len(content))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commands
+	fixContentTypesCmd = &cobra.Command{
+		Use:   "fix-content-types",
+		Short: "Correct generic content types from blob key extensions",
+		Long: `Correct generic content types from blob key extensions.
+
+Every blob under --blob-prefix whose stored content type is empty or
+application/octet-stream, but whose key has an extension with a known
+content type (e.g. ".html" or ".png"), gets that content type. The other
+content headers of the blob are kept. Use --dry-run to only print what
+would be corrected.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if !dryRun {
+				if err := checkWritable("fix content types"); err != nil {
+					return err
+				}
+			}
+
+			items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{})
+			if err != nil {
+				return err
+			}
+
+			var (
+				mu        sync.Mutex
+				corrected int
+			)
+			errs := runPool(len(items), concurrency, func(i int) error {
+				item := items[i]
+				p := item.Properties
+
+				var current string
+				if p.ContentType != nil {
+					current = *p.ContentType
+				}
+				if current != "" && current != "application/octet-stream" {
+					return nil
+				}
+				contentType := mime.TypeByExtension(path.Ext(item.Name))
+				if contentType == "" {
+					return nil
+				}
+
+				if !dryRun {
+					h := azblob.BlobHTTPHeaders{
+						ContentType:        contentType,
+						ContentMD5:         p.ContentMD5,
+						ContentEncoding:    stringValue(p.ContentEncoding),
+						ContentLanguage:    stringValue(p.ContentLanguage),
+						ContentDisposition: stringValue(p.ContentDisposition),
+						CacheControl:       stringValue(p.CacheControl),
+					}
+					// Only update the blob if it was not replaced since it was listed.
+					_, err := newBlobURL(containerName, item.Name).SetHTTPHeaders(ctx, h, azblob.BlobAccessConditions{
+						ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: p.Etag},
+					})
+					if err != nil {
+						return err
+					}
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				corrected++
+				if current == "" {
+					current = "(none)"
+				}
+				if dryRun {
+					fmt.Fprintf(out, "would fix %s: %s -> %s\n", item.Name, current, contentType)
+				} else {
+					fmt.Fprintf(out, "FIXED %s: %s -> %s\n", item.Name, current, contentType)
+				}
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", items[e.Index].Name, e.Err))
+			}
+
+			verb := "Corrected"
+			if dryRun {
+				verb = "Would correct"
+			}
+			logger.Info(fmt.Sprintf("%s: %d of %d blobs, failed: %d", verb, corrected, len(items), len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if !dryRun {
+				logger.Info(fmt.Sprintf("Successfully fixed content types under %q", blobPrefix))
+			}
+			return nil
+		},
+	}
+)
+
+// stringValue returns the string pointed to by s, or "" if s is nil.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func init() {
+	fixContentTypesCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to fix content types under")
+
+	rootCmd.AddCommand(fixContentTypesCmd)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+// copySourceExpiry is how long the signed source URL of a copy stays valid.
+// The service only needs to read the source until the copy completes.
+const copySourceExpiry = 24 * time.Hour
+
+var (
+	// Flags
+	sourceKey  string
+	copyUpdate bool
+
+	// Commands
+	copyBlobCmd = &cobra.Command{
+		Use:         "copy-blob",
+		Short:       "Copy a blob within the account on the server",
+		Annotations: mutating,
+		Long: `Copy a blob within the account on the server.
+
+--source-key is copied to --dest-key, in --dest-container if set or in the
+same container otherwise, by the service with Copy Blob, without
+downloading and re-uploading the content. The command waits until the copy
+is no longer pending and reports its final status.
+
+The service reads the source through a URL signed with the account key,
+valid for a day, or through the --sas-token, which must then grant read
+access to the source.
+
+With --update, like rsync, the copy is skipped if --dest-key exists and
+was modified no earlier than --source-key, and the copied and skipped
+counts are reported.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if sourceKey == "" {
+				return fmt.Errorf(`flag "--source-key" should be set`)
+			}
+			if destKey == "" {
+				return fmt.Errorf(`flag "--dest-key" should be set`)
+			}
+			dstContainer := destContainer
+			if dstContainer == "" {
+				dstContainer = containerName
+			}
+			if dstContainer == containerName && destKey == sourceKey {
+				return fmt.Errorf(`flags "--source-key" and "--dest-key" should differ`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			blobURL := newBlobURL(dstContainer, destKey)
+			srcConditions := azblob.ModifiedAccessConditions{}
+			if copyUpdate {
+				upToDate, etag, err := destUpToDate(containerName, sourceKey, blobURL)
+				if err != nil {
+					return err
+				}
+				if upToDate {
+					logger.Info(fmt.Sprintf("Blob %q is at least as new as %q, not copied", destKey, sourceKey))
+					logger.Info("Copied: 0, skipped: 1")
+					return nil
+				}
+				// Only copy the source that was compared
+				srcConditions.IfMatch = etag
+			}
+			if err := copyBlob(out, containerName, sourceKey, blobURL, srcConditions); err != nil {
+				return err
+			}
+			if copyUpdate {
+				logger.Info("Copied: 1, skipped: 0")
+			}
+
+			logger.Info(fmt.Sprintf("Successfully copied %q to %q in container %q", sourceKey, destKey, dstContainer))
+			return nil
+		},
+	}
+)
+
+// copyBlob copies key in the named container to blobURL on the server and
+// waits until the copy is no longer pending, printing its status to out. It
+// returns an error unless the copy succeeded. The copy only starts if the
+// source matches srcConditions.
+func copyBlob(out io.Writer, container, key string, blobURL azblob.BlobURL, srcConditions azblob.ModifiedAccessConditions) error {
+	src, err := copySourceURL(container, key)
+	if err != nil {
+		return err
+	}
+
+	resp, err := blobURL.StartCopyFromURL(ctx, src, azblob.Metadata{}, srcConditions,
+		azblob.BlobAccessConditions{}, azblob.AccessTierNone, nil)
+	if err != nil {
+		return fmt.Errorf("starting copy of %q: %v", key, err)
+	}
+	fmt.Fprintf(out, "Copy %s is %s\n", resp.CopyID(), resp.CopyStatus())
+
+	if err := waitForCopy(ctx, blobURL, resp.CopyID(), resp.CopyStatus()); err != nil {
+		return fmt.Errorf("copying %q: %v", key, err)
+	}
+	if resp.CopyStatus() == azblob.CopyStatusPending {
+		fmt.Fprintf(out, "Copy %s is %s\n", resp.CopyID(), azblob.CopyStatusSuccess)
+	}
+	return nil
+}
+
+// destUpToDate reports whether blobURL exists and was modified no earlier
+// than key in the named container, and returns the ETag of key.
+func destUpToDate(container, key string, blobURL azblob.BlobURL) (bool, azblob.ETag, error) {
+	src, err := newBlobURL(container, key).GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return false, azblob.ETagNone, err
+	}
+	dst, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if isBlobNotFound(err) {
+		return false, src.ETag(), nil
+	}
+	if err != nil {
+		return false, azblob.ETagNone, err
+	}
+	return !dst.LastModified().Before(src.LastModified()), src.ETag(), nil
+}
+
+// copySourceURL returns a URL the service can read key in the named
+// container through. With the account key it is signed for reading, in
+// Azure AD mode with a user delegation key, and in SAS mode it carries the
+// SAS token.
+func copySourceURL(container, key string) (url.URL, error) {
+	if useAAD() {
+		return delegationSignedURL(ctx, container, key, azblob.BlobSASPermissions{Read: true}, copySourceExpiry)
+	}
+	u := newBlobURL(container, key).URL()
+	if credential == nil {
+		return u, nil
+	}
+
+	parts := azblob.NewBlobURLParts(u)
+	sas, err := azblob.BlobSASSignatureValues{
+		Protocol:      sasProtocol(),
+		ExpiryTime:    time.Now().UTC().Add(copySourceExpiry),
+		ContainerName: parts.ContainerName,
+		BlobName:      parts.BlobName,
+		Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
+	}.NewSASQueryParameters(credential)
+	if err != nil {
+		return url.URL{}, err
+	}
+	parts.SAS = sas
+	return parts.URL(), nil
+}
+
+func init() {
+	copyBlobCmd.PersistentFlags().StringVar(&sourceKey, "source-key", "", "indicate a blob key to copy from")
+	copyBlobCmd.PersistentFlags().StringVar(&destKey, "dest-key", "", "indicate a blob key to copy to")
+	copyBlobCmd.PersistentFlags().StringVar(&destContainer, "dest-container", "", "indicate a container to copy to (defaults to --container-name)")
+	copyBlobCmd.PersistentFlags().BoolVar(&copyUpdate, "update", false, "indicate to skip the copy if the destination is at least as new as the source")
+
+	rootCmd.AddCommand(copyBlobCmd)
+}
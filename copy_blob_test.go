@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyBlobUpdate(t *testing.T) {
+	s := newFakeService(t, "test")
+	for _, key := range []string{"src", "dst"} {
+		if _, _, err := executeFake(t, s, "write", "--blob-key", key, "--blob-value", "content of "+key); err != nil {
+			t.Fatalf("write %q: %v", key, err)
+		}
+	}
+
+	// dst was written after src, so it is up to date
+	_, stderr, err := executeFake(t, s, "copy-blob", "--source-key", "src", "--dest-key", "dst", "--update")
+	if err != nil {
+		t.Fatalf("copy-blob --update: %v", err)
+	}
+	if !strings.Contains(stderr, "Copied: 0, skipped: 1") {
+		t.Errorf("copy-blob --update of an older source printed %q", stderr)
+	}
+	if got := string(s.blob("test", "dst").content); got != "content of dst\n" {
+		t.Errorf("dst is %q after copy-blob --update of an older source", got)
+	}
+
+	// Once dst is older than src, it is copied over
+	s.blob("test", "dst").header.Set("Last-Modified", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	_, stderr, err = executeFake(t, s, "copy-blob", "--source-key", "src", "--dest-key", "dst", "--update")
+	if err != nil {
+		t.Fatalf("copy-blob --update: %v", err)
+	}
+	if !strings.Contains(stderr, "Copied: 1, skipped: 0") {
+		t.Errorf("copy-blob --update of a newer source printed %q", stderr)
+	}
+	if got := string(s.blob("test", "dst").content); got != "content of src\n" {
+		t.Errorf("dst is %q after copy-blob --update of a newer source", got)
+	}
+
+	// A missing destination is copied
+	if _, _, err := executeFake(t, s, "copy-blob", "--source-key", "src", "--dest-key", "new", "--update"); err != nil {
+		t.Fatalf("copy-blob --update to a missing blob: %v", err)
+	}
+	if s.blob("test", "new") == nil {
+		t.Error("copy-blob --update did not copy to a missing blob")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	copyURL string
+
+	// Commands
+	copyFromURLCmd = &cobra.Command{
+		Use:         "copy-from-url",
+		Short:       "Import an external object into a blob and wait for the copy",
+		Annotations: mutating,
+		Long: `Import an external object into a blob and wait for the copy.
+
+The object at --url is copied by the service into the block blob
+--blob-key, without its content going through this machine, e.g. to import
+public datasets. The source must be readable by the service, e.g. public or
+a signed URL.
+
+Sources up to 256 MiB are copied synchronously with Copy Blob From URL.
+Larger sources, or sources whose size a HEAD request doesn't report, are
+copied asynchronously with Copy Blob, and the command polls the copy until
+it completes, fails or --timeout expires. Unlike put-from-url, the command
+only returns once the blob is complete.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if copyURL == "" {
+				return fmt.Errorf(`flag "--url" should be set`)
+			}
+			src, err := url.Parse(copyURL)
+			if err != nil || (src.Scheme != "https" && src.Scheme != "http") || src.Host == "" {
+				return fmt.Errorf(`flag "--url" should be an http(s) URL`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			blobURL := newBlobURL(containerName, blobKey)
+
+			size, err := sourceSize(src)
+			if err == nil && size >= 0 && size <= azblob.BlockBlobMaxUploadBlobBytes {
+				_, err = blobURL.ToBlockBlobURL().CopyFromURL(ctx, *src, azblob.Metadata{}, azblob.ModifiedAccessConditions{},
+					azblob.BlobAccessConditions{}, nil, azblob.AccessTierNone, nil)
+				if err != nil {
+					return fmt.Errorf("copying %q: %v", redactedURL(*src), err)
+				}
+
+				logger.Info(fmt.Sprintf("Successfully copied %s to %q", formatBytes(size), blobKey))
+				return nil
+			}
+
+			if err != nil {
+				fmt.Fprintf(errOut, "Cannot determine the size of the source (%v), copying asynchronously\n", err)
+			} else if size < 0 {
+				fmt.Fprintln(errOut, "The source doesn't report its size, copying asynchronously")
+			} else {
+				fmt.Fprintf(errOut, "The source is %s, over the %s limit of a synchronous copy, copying asynchronously\n",
+					formatBytes(size), formatBytes(azblob.BlockBlobMaxUploadBlobBytes))
+			}
+
+			resp, err := blobURL.StartCopyFromURL(ctx, *src, azblob.Metadata{}, azblob.ModifiedAccessConditions{},
+				azblob.BlobAccessConditions{}, azblob.AccessTierNone, nil)
+			if err != nil {
+				return fmt.Errorf("starting copy of %q: %v", redactedURL(*src), err)
+			}
+			fmt.Fprintf(errOut, "Waiting for copy %s\n", resp.CopyID())
+			if err := waitForCopy(ctx, blobURL, resp.CopyID(), resp.CopyStatus()); err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully copied %q to %q", redactedURL(*src), blobKey))
+			return nil
+		},
+	}
+)
+
+func init() {
+	copyFromURLCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to copy to")
+	copyFromURLCmd.PersistentFlags().StringVar(&copyURL, "url", "", "indicate a URL of the object to copy")
+
+	rootCmd.AddCommand(copyFromURLCmd)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	deleteDuplicates bool
+
+	// Commands
+	dedupeReportCmd = &cobra.Command{
+		Use:   "dedupe-report",
+		Short: "Report blobs with identical content under a prefix",
+		Long: `Report blobs with identical content under a prefix.
+
+Blobs under --blob-prefix are grouped by their stored Content-MD5, and every
+group of two or more blobs is reported together with the bytes wasted by
+the extra copies. Blobs without a stored MD5 are skipped.
+
+With --delete-duplicates, the first key of every group (in lexicographical
+order) is kept and the others are deleted as by delete-prefix, --concurrency
+at a time, unless they were modified since they were listed. Combine with
+--dry-run to only print what would be deleted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			if deleteDuplicates && !dryRun {
+				if err := checkWritable("delete duplicates"); err != nil {
+					return err
+				}
+			}
+
+			items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{})
+			if err != nil {
+				return err
+			}
+
+			groups := make(map[string][]azblob.BlobItemInternal)
+			skipped := 0
+			for _, item := range items {
+				if len(item.Properties.ContentMD5) == 0 {
+					skipped++
+					continue
+				}
+				sum := fmt.Sprintf("%x", item.Properties.ContentMD5)
+				groups[sum] = append(groups[sum], item)
+			}
+
+			var sums []string
+			for sum, group := range groups {
+				if len(group) > 1 {
+					sums = append(sums, sum)
+				}
+			}
+			sort.Strings(sums)
+
+			var (
+				wasted     int64
+				duplicates []azblob.BlobItemInternal
+			)
+			for _, sum := range sums {
+				// Listings are in lexicographical order, so the first key
+				// is kept
+				group := groups[sum]
+				var size int64
+				if group[0].Properties.ContentLength != nil {
+					size = *group[0].Properties.ContentLength
+				}
+				extra := size * int64(len(group)-1)
+				wasted += extra
+				fmt.Fprintf(out, "%s (%d copies, %s wasted):\n", sum, len(group), formatBytes(extra))
+				for _, item := range group {
+					fmt.Fprintf(out, "  %s\n", item.Name)
+				}
+				duplicates = append(duplicates, group[1:]...)
+			}
+			logger.Info(fmt.Sprintf("Duplicate sets: %d, wasted: %s, skipped (no MD5): %d", len(sums), formatBytes(wasted), skipped))
+
+			if deleteDuplicates {
+				// Duplicates modified since they were listed are not
+				// deleted
+				deleted, size, errs := deleteBlobItems(out, errOut, duplicates)
+				verb := "Deleted"
+				if dryRun {
+					verb = "Would delete"
+				}
+				logger.Info(fmt.Sprintf("%s: %d (%s), failed: %d", verb, deleted, formatBytes(size), len(errs)))
+				if len(errs) > 0 {
+					return &exitError{Code: 1}
+				}
+			}
+
+			logger.Info(fmt.Sprintf("Successfully checked duplicates under %q", blobPrefix))
+			return nil
+		},
+	}
+)
+
+func init() {
+	dedupeReportCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to look for duplicates under")
+	dedupeReportCmd.PersistentFlags().BoolVar(&deleteDuplicates, "delete-duplicates", false, "keep the first key of every duplicate set and delete the others")
+
+	rootCmd.AddCommand(dedupeReportCmd)
+}
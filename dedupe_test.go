@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestDedupeDeleteDuplicates(t *testing.T) {
+	s := newFakeService(t, "test")
+	for key, value := range map[string]string{"a": "same", "b": "same", "c": "same", "d": "other"} {
+		if _, _, err := executeFake(t, s, "write", "--blob-key", key, "--blob-value", value); err != nil {
+			t.Fatalf("write %q: %v", key, err)
+		}
+	}
+
+	stdout, _, err := executeFake(t, s, "dedupe-report", "--delete-duplicates")
+	if err != nil {
+		t.Fatalf("dedupe-report --delete-duplicates: %v", err)
+	}
+	for key, kept := range map[string]bool{"a": true, "b": false, "c": false, "d": true} {
+		if got := s.blob("test", key) != nil; got != kept {
+			t.Errorf("%q exists: %v, want %v", key, got, kept)
+		}
+		if deleted := strings.Contains(stdout, "DELETED "+key+"\n"); deleted == kept {
+			t.Errorf("dedupe-report printed %q, deleting %q: %v", stdout, key, deleted)
+		}
+	}
+}
+
+func TestDeleteBlobItemIfUnmodified(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "k", "--blob-value", "v"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	openFakeBucket(t, s)
+	saved := containerName
+	t.Cleanup(func() { containerName = saved })
+	containerName = "test"
+
+	// A blob rewritten since it was listed is kept
+	stale := azblob.BlobItemInternal{Name: "k", Properties: azblob.BlobProperties{Etag: `"0x8D9stale"`}}
+	if err := deleteBlobItem(stale); err == nil {
+		t.Error("deleteBlobItem of a modified blob succeeded")
+	}
+	if s.blob("test", "k") == nil {
+		t.Fatal("deleteBlobItem deleted a modified blob")
+	}
+
+	listed := azblob.BlobItemInternal{Name: "k", Properties: azblob.BlobProperties{Etag: azblob.ETag(s.blob("test", "k").header.Get("ETag"))}}
+	if err := deleteBlobItem(listed); err != nil {
+		t.Fatalf("deleteBlobItem: %v", err)
+	}
+	if s.blob("test", "k") != nil {
+		t.Error("deleteBlobItem kept an unmodified blob")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	sasPermissions string
+
+	// Commands
+	delegationSASCmd = &cobra.Command{
+		Use:   "delegation-sas",
+		Short: "Print a URL to a blob signed with a user delegation key",
+		Long: `Print a URL to a blob signed with a user delegation key.
+
+A user delegation key is requested with the Azure AD credential of
+--auth-mode aad and used to sign a URL to --blob-key, granting
+--permissions for --expiry, so that Azure AD users can share short-lived
+links without the account key. --permissions takes the letters of a blob
+SAS, e.g. "r" (the default) to read or "cw" to upload.
+
+The service issues user delegation keys for at most 7 days, so --expiry
+can't be longer. The SAS is also revoked early if the key is revoked or the
+signing identity loses its role on the account.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if expiry <= 0 {
+				return fmt.Errorf(`flag "--expiry" should be positive`)
+			}
+			if expiry > maxDelegationKeyLifetime {
+				return fmt.Errorf(`flag "--expiry" should be at most %s, the longest a user delegation key is valid for`, maxDelegationKeyLifetime)
+			}
+			var perms azblob.BlobSASPermissions
+			if err := perms.Parse(sasPermissions); err != nil || sasPermissions == "" {
+				return fmt.Errorf(`flag "--permissions" should be blob SAS permissions, e.g. "r" or "cw"`)
+			}
+			if !useAAD() {
+				return fmt.Errorf(`user delegation keys are only issued to Azure AD identities, set "--auth-mode" aad`)
+			}
+
+			u, err := delegationSignedURL(ctx, containerName, blobKey, perms, expiry)
+			if err != nil {
+				return err
+			}
+
+			// Print only the URL, so the output can be captured.
+			fmt.Fprintln(out, u.String())
+			return nil
+		},
+	}
+)
+
+func init() {
+	delegationSASCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to sign a URL for")
+	delegationSASCmd.PersistentFlags().DurationVar(&expiry, "expiry", time.Hour, "indicate how long the URL stays valid (at most 168h)")
+	delegationSASCmd.PersistentFlags().StringVar(&sasPermissions, "permissions", "r", "indicate the permissions the URL grants, as blob SAS letters (e.g. \"r\" or \"cw\")")
+
+	rootCmd.AddCommand(delegationSASCmd)
+}
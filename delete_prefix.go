@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commands
+	deletePrefixCmd = &cobra.Command{
+		Use:   "delete-prefix",
+		Short: "Delete all blobs under a prefix",
+		Long: `Delete all blobs under a prefix.
+
+Every blob under --blob-prefix, at any depth, is deleted along with its
+snapshots, --concurrency blobs at a time. Blobs modified since they were
+listed are not deleted. Failures don't stop the other deletions and are
+reported at the end.
+
+Since this can delete a lot of data, the deletion must be confirmed,
+either interactively or with --yes. Use --dry-run to only print what would
+be deleted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			if !dryRun {
+				if err := checkWritable("delete blobs"); err != nil {
+					return err
+				}
+			}
+
+			warnFullScan(errOut, blobPrefix)
+			items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{})
+			if err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				fmt.Fprintf(errOut, "No blobs under %q\n", blobPrefix)
+				return nil
+			}
+
+			if !dryRun && !assumeYes {
+				ok, err := confirm(cmd.InOrStdin(), errOut, fmt.Sprintf("Delete %d blobs under %q in container %q?", len(items), blobPrefix, containerName))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("deletion not confirmed")
+				}
+			}
+
+			deleted, size, errs := deleteBlobItems(out, errOut, items)
+
+			verb := "Deleted"
+			if dryRun {
+				verb = "Would delete"
+			}
+			logger.Info(fmt.Sprintf("%s: %d (%s), failed: %d", verb, deleted, formatBytes(size), len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if !dryRun {
+				logger.Info(fmt.Sprintf("Successfully deleted the blobs under %q", blobPrefix))
+			}
+			return nil
+		},
+	}
+)
+
+// deleteBlobItems deletes the listed blobs of --container-name with their
+// snapshots, --concurrency at a time, printing every deletion to out and
+// every failure to errOut, and returns the number of blobs deleted, their
+// size and the failures. Blobs modified since they were listed are not
+// deleted. Soft-deleted blobs are deleted permanently. With --dry-run, only
+// what would be deleted is printed.
+func deleteBlobItems(out, errOut io.Writer, items []azblob.BlobItemInternal) (int, int64, []itemError) {
+	var (
+		mu      sync.Mutex
+		deleted int
+		size    int64
+	)
+	errs := runPool(len(items), concurrency, func(i int) error {
+		item := items[i]
+		if !dryRun {
+			if err := deleteBlobItem(item); err != nil {
+				return err
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		deleted++
+		if item.Properties.ContentLength != nil {
+			size += *item.Properties.ContentLength
+		}
+		if dryRun {
+			fmt.Fprintf(out, "would delete %s\n", item.Name)
+		} else {
+			fmt.Fprintf(out, "DELETED %s\n", item.Name)
+		}
+		return nil
+	})
+	for _, e := range errs {
+		logger.Error(fmt.Sprintf("%s: %v", items[e.Index].Name, e.Err))
+	}
+	return deleted, size, errs
+}
+
+// deleteBlobItem deletes the listed blob item of --container-name with its
+// snapshots, unless it was modified since it was listed. A soft-deleted
+// blob is deleted permanently, which the SDK doesn't expose, so the
+// deletetype query parameter is added to its URL, where the SDK keeps it.
+func deleteBlobItem(item azblob.BlobItemInternal) error {
+	if item.Deleted {
+		u := newBlobURL(containerName, item.Name).URL()
+		query := u.Query()
+		query.Set("deletetype", string(azblob.BlobDeletePermanent))
+		u.RawQuery = query.Encode()
+		_, err := azblob.NewBlobURL(u, pline).Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+		return err
+	}
+
+	_, err := newBlobURL(containerName, item.Name).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: item.Properties.Etag},
+	})
+	return err
+}
+
+// confirm asks question on errOut and reports whether it was answered with
+// yes on in, which must be a terminal.
+func confirm(in io.Reader, errOut io.Writer, question string) (bool, error) {
+	if f, ok := in.(*os.File); !ok || !isTerminal(f) {
+		return false, fmt.Errorf(`cannot ask for confirmation without a terminal, set "--yes" to proceed`)
+	}
+
+	fmt.Fprintf(errOut, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func init() {
+	deletePrefixCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to delete the blobs under")
+	deletePrefixCmd.PersistentFlags().BoolVar(&assumeYes, "yes", false, "delete without asking for confirmation")
+
+	rootCmd.AddCommand(deletePrefixCmd)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	outputPath string
+
+	// Commands
+	downloadFileCmd = &cobra.Command{
+		Use:   "download-file",
+		Short: "Download a blob to a local file",
+		Long: `Download a blob to a local file.
+
+The content of --blob-key is streamed to the file --output, which defaults
+to the last path segment of the key, e.g. "report.pdf" for
+"reports/2024/report.pdf". Unlike read, nothing but the content is written
+to the file, so binary blobs are saved intact. Messages go to standard
+error.
+
+If an MD5 is stored with the blob, the content is checked against it, and
+the file is removed and the command fails if it doesn't match. Use
+--no-verify to skip the check. Blobs stored with Content-Encoding gzip,
+deflate or br are decompressed unless --raw is set. Blobs written with --encryption-key can
+only be downloaded with the same --encryption-key. With --progress, the
+number of bytes downloaded is printed to standard error as the download
+goes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			// Send the customer-provided key, if any, with the requests
+			ctx, err := encryptionContext(ctx)
+			if err != nil {
+				return err
+			}
+
+			dst := outputPath
+			if dst == "" {
+				dst = path.Base(blobKey)
+				if dst == "/" || dst == "." || dst == ".." {
+					return fmt.Errorf(`flag "--output" should be set, %q has no file name`, blobKey)
+				}
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			var progressOut io.Writer
+			if showProgress {
+				progressOut = errOut
+			}
+			n, err := downloadFile(ctx, bucket, blobKey, dst, progressOut)
+			if err != nil {
+				return readError(blobKey, err)
+			}
+
+			logger.Info(fmt.Sprintf("Successfully read %q (%s) to %q", blobKey, formatBytes(n), dst))
+			return nil
+		},
+	}
+)
+
+// downloadFile copies key in b to the local file at path and returns the
+// number of bytes written. The content is verified against the stored MD5
+// and decompressed if encoded, as by decodedReader, and the file is
+// removed if the copy or the verification fails. If progressOut is not nil,
+// the progress is printed to it.
+func downloadFile(ctx context.Context, b *blob.Bucket, key, path string, progressOut io.Writer) (int64, error) {
+	r, err := b.NewReader(ctx, key, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	src, err := decodedReader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		dst io.Writer = f
+		p   *progress
+	)
+	if progressOut != nil {
+		// The size of decompressed content is unknown
+		total := r.Size()
+		if isEncoded(r) {
+			total = -1
+		}
+		p = newProgress(progressOut, total)
+		dst = io.MultiWriter(f, p)
+	}
+	n, err := io.Copy(dst, src)
+	if p != nil {
+		p.done()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return n, err
+	}
+	return n, nil
+}
+
+// readError explains the errors of reading key that come from how the blob
+// is stored: archived, or encrypted with another customer-provided key.
+func readError(key string, err error) error {
+	var serr azblob.StorageError
+	if !errors.As(err, &serr) {
+		return err
+	}
+	switch serr.ServiceCode() {
+	case azblob.ServiceCodeBlobArchived:
+		return fmt.Errorf("blob %q is archived and must be rehydrated before it can be read, e.g. with \"azure rehydrate --blob-key %s --tier Hot\": %v", key, key, err)
+	case "BlobUsesCustomerSpecifiedEncryption":
+		if encryptionKey == "" {
+			return fmt.Errorf("blob %q is encrypted with a customer-provided key, set \"--encryption-key\" to the key it was written with: %v", key, err)
+		}
+		return fmt.Errorf("blob %q is encrypted with another key than \"--encryption-key\": %v", key, err)
+	case "BlobDoesNotUseCustomerSpecifiedEncryption":
+		return fmt.Errorf("blob %q is not encrypted with a customer-provided key, unset \"--encryption-key\": %v", key, err)
+	}
+	return err
+}
+
+func init() {
+	downloadFileCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to download")
+	downloadFileCmd.PersistentFlags().StringVar(&outputPath, "output", "", "indicate a local file to download to (the last path segment of the key if empty)")
+
+	downloadFileCmd.PersistentFlags().StringVar(&encryptionKey, "encryption-key", "", "indicate a base64 encoded AES-256 key the blob was written with")
+	downloadFileCmd.PersistentFlags().StringVar(&encryptionKeySHA256, "encryption-key-sha256", "", "indicate the base64 encoded SHA-256 of --encryption-key (computed if empty)")
+	downloadFileCmd.PersistentFlags().BoolVar(&showProgress, "progress", false, "print the progress of the download to stderr")
+	downloadFileCmd.PersistentFlags().BoolVar(&rawRead, "raw", false, "save encoded blobs without decompressing them")
+
+	rootCmd.AddCommand(downloadFileCmd)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	destDir string
+
+	// Commands
+	downloadPrefixCmd = &cobra.Command{
+		Use:   "download-prefix",
+		Short: "Download all blobs under a prefix into a local directory",
+		Long: `Download all blobs under a prefix into a local directory.
+
+Every blob under --blob-prefix, at any depth, is downloaded as by
+download-file, --concurrency blobs at a time, into --dest-dir at its key
+with the prefix stripped, creating the parent directories as needed. For
+example, with --blob-prefix logs/ and --dest-dir out, "logs/a/app.log" is
+downloaded to "out/a/app.log". Keys that are not safe to use as relative
+file paths are skipped with a warning.
+
+The first failing download cancels the remaining ones.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if destDir == "" {
+				return fmt.Errorf(`flag "--dest-dir" should be set`)
+			}
+
+			items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{})
+			if err != nil {
+				return err
+			}
+
+			// Map every key to its local path
+			var keys, paths []string
+			for _, item := range items {
+				rel := downloadPath(blobPrefix, item.Name)
+				if !isSafeRelativePath(rel) {
+					logger.Warn(fmt.Sprintf("Skipping %q: not safe to use as a file path", item.Name))
+					continue
+				}
+				keys = append(keys, item.Name)
+				paths = append(paths, filepath.Join(destDir, filepath.FromSlash(rel)))
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			// Cancelling dctx stops the downloads after the first failure.
+			dctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			var (
+				mu         sync.Mutex
+				downloaded int
+				size       int64
+			)
+			errs := runPool(len(keys), concurrency, func(i int) error {
+				if dctx.Err() != nil {
+					return nil
+				}
+
+				err := os.MkdirAll(filepath.Dir(paths[i]), 0755)
+				var n int64
+				if err == nil {
+					n, err = downloadFile(dctx, bucket, keys[i], paths[i], nil)
+				}
+				if err != nil {
+					if dctx.Err() != nil {
+						// Aborted because of another failure
+						return nil
+					}
+					cancel()
+					return err
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				downloaded++
+				size += n
+				fmt.Fprintf(out, "DOWNLOADED %s -> %s\n", keys[i], paths[i])
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", keys[e.Index], e.Err))
+			}
+			logger.Info(fmt.Sprintf("Downloaded: %d (%s), failed: %d, not downloaded: %d",
+				downloaded, formatBytes(size), len(errs), len(keys)-downloaded-len(errs)))
+
+			// An interrupt or the global --timeout also cancels dctx
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			logger.Info(fmt.Sprintf("Successfully downloaded %q to %q", blobPrefix, destDir))
+			return nil
+		},
+	}
+)
+
+// downloadPath returns the relative local path of key with prefix stripped.
+// A key equal to the prefix keeps its last path segment.
+func downloadPath(prefix, key string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/")
+	if rel == "" {
+		return path.Base(key)
+	}
+	return rel
+}
+
+func init() {
+	downloadPrefixCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to download the blobs under")
+	downloadPrefixCmd.PersistentFlags().StringVar(&destDir, "dest-dir", "", "indicate a local directory to download to")
+	downloadPrefixCmd.PersistentFlags().BoolVar(&rawRead, "raw", false, "save encoded blobs without decompressing them")
+
+	rootCmd.AddCommand(downloadPrefixCmd)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// dryRun is set with the persistent --dry-run flag. Batch commands check it
+// themselves to print what they would change, while mutating commands print
+// a description of what they would do once their flags are checked.
+var dryRun bool
+
+// dryRunKeyFlags are the flags naming the blobs a mutating command acts on.
+var dryRunKeyFlags = []string{"blob-key", "source-key", "dest-key"}
+
+// isDryRunCommand reports whether cmd is a mutating command run with
+// --dry-run.
+func isDryRunCommand(cmd *cobra.Command) bool {
+	_, ok := cmd.Annotations[mutatingAnnotation]
+	return ok && dryRun
+}
+
+// skipDryRun prints the command cmd, a mutating command, would run and the
+// container and blobs it would act on, and reports true if --dry-run is set,
+// in which case cmd should return without changing anything. It is called
+// after the flags are checked, so that invalid flags fail with or without
+// --dry-run.
+func skipDryRun(cmd *cobra.Command) bool {
+	if !isDryRunCommand(cmd) {
+		return false
+	}
+	printDryRun(cmd.OutOrStdout(), cmd)
+	return true
+}
+
+// printDryRun describes the mutating command cmd to out.
+func printDryRun(out io.Writer, cmd *cobra.Command) {
+	fmt.Fprintf(out, "Dry run: would run %q (%s)\n", cmd.Name(), cmd.Short)
+	fmt.Fprintf(out, "  container: %s\n", redactedURL(newContainerURL(containerName).URL()))
+	for _, name := range dryRunKeyFlags {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Value.String() == "" {
+			continue
+		}
+		fmt.Fprintf(out, "  %s: %s\n", name, redactedURL(newBlobURL(containerName, f.Value.String()).URL()))
+	}
+}
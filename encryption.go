@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+var (
+	// Flags
+	encryptionKey       string
+	encryptionKeySHA256 string
+)
+
+// encryptionContext returns ctx carrying the customer-provided key set with
+// --encryption-key, or ctx itself if it is not set. The SHA-256 of the key
+// is computed unless --encryption-key-sha256 is set, in which case it must
+// match.
+func encryptionContext(ctx context.Context) (context.Context, error) {
+	if encryptionKey == "" {
+		if encryptionKeySHA256 != "" {
+			return nil, fmt.Errorf(`flag "--encryption-key-sha256" cannot be set without "--encryption-key"`)
+		}
+		return ctx, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf(`flag "--encryption-key" should be a base64 encoded 256-bit key`)
+	}
+	sum := sha256.Sum256(key)
+	keySHA256 := base64.StdEncoding.EncodeToString(sum[:])
+	if encryptionKeySHA256 != "" && encryptionKeySHA256 != keySHA256 {
+		return nil, fmt.Errorf(`flag "--encryption-key-sha256" doesn't match the SHA-256 of "--encryption-key"`)
+	}
+	if storageProtocol != "https" {
+		return nil, fmt.Errorf(`flag "--encryption-key" can only be sent over https`)
+	}
+
+	// The blob readers and writers don't take the key, so it is added to
+	// the requests by requestHeadersPipeline
+	cpk := azblob.NewClientProvidedKeyOptions(&encryptionKey, &keySHA256, nil)
+	return withRequestHeaders(ctx, http.Header{
+		"X-Ms-Encryption-Key":        {*cpk.EncryptionKey},
+		"X-Ms-Encryption-Key-Sha256": {*cpk.EncryptionKeySha256},
+		"X-Ms-Encryption-Algorithm":  {string(cpk.EncryptionAlgorithm)},
+	}), nil
+}
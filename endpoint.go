@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gocloud.dev/blob/azureblob"
+)
+
+// The well-known account of the Azurite and Azure Storage emulators, used
+// with --emulator. The key is public and the same for every installation.
+const (
+	emulatorAccountName azureblob.AccountName   = "devstoreaccount1"
+	emulatorAccountKey  azureblob.AccountKey    = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	emulatorDomain      azureblob.StorageDomain = "127.0.0.1:10000"
+)
+
+var (
+	// Flags
+	endpointSuffix string
+	emulator       bool
+	serviceURLFlag string
+
+	// customServiceURL is the base URL set by --service-url, if any.
+	customServiceURL *url.URL
+)
+
+// useEmulator points the account at the local emulator.
+func useEmulator() {
+	accountName, accountKey = emulatorAccountName, emulatorAccountKey
+	storageProtocol = "http"
+	storageDomain = emulatorDomain
+}
+
+// setEndpointSuffix points the account at the cloud with the endpoint
+// suffix, e.g. "core.usgovcloudapi.net" for Azure Government.
+func setEndpointSuffix(suffix string) error {
+	suffix = strings.Trim(suffix, ".")
+	if suffix == "" || strings.ContainsAny(suffix, "/:") {
+		return fmt.Errorf(`flag "--endpoint-suffix" should be a domain such as %q`, defaultEndpointSuffix)
+	}
+	storageDomain = azureblob.StorageDomain("blob." + suffix)
+	return nil
+}
+
+// setServiceURL points the account at the base URL raw, used as is instead
+// of the URL built from the account name and endpoint suffix, e.g.
+// "http://host:10000/account" for Azurite or a reverse proxy. Containers
+// and blobs are addressed below its path.
+func setServiceURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf(`flag "--service-url" should be an http or https URL such as "http://127.0.0.1:10000/devstoreaccount1"`)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf(`flag "--service-url" should have no query, set a SAS token with "--sas-token"`)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+
+	storageProtocol = azureblob.Protocol(u.Scheme)
+	storageDomain = azureblob.StorageDomain(u.Host + u.EscapedPath())
+	if isLocalDomain() {
+		// azureblob.OpenBucket appends the account name to the path of local
+		// domains, so the path is passed as the account name instead
+		if u.Path == "" {
+			return fmt.Errorf(`flag "--service-url" should have the account in its path for a local host, e.g. "http://%s/%s"`, u.Host, emulatorAccountName)
+		}
+		storageDomain = azureblob.StorageDomain(u.Host)
+	}
+	customServiceURL = u
+	return nil
+}
+
+// bucketAccountName returns the account name to open buckets with, which
+// is the path of a local --service-url, see setServiceURL.
+func bucketAccountName() azureblob.AccountName {
+	if customServiceURL != nil && isLocalDomain() {
+		return azureblob.AccountName(strings.TrimPrefix(customServiceURL.EscapedPath(), "/"))
+	}
+	return accountName
+}
+
+// isLocalDomain reports whether the storage domain is a local emulator,
+// which addresses the account in the path rather than in the host name.
+// This matches how azureblob.OpenBucket builds its URLs.
+func isLocalDomain() bool {
+	d := string(storageDomain)
+	return strings.HasPrefix(d, "127.0.0.1") || strings.HasPrefix(d, "localhost")
+}
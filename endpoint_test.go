@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSetServiceURL(t *testing.T) {
+	tests := []struct {
+		raw         string
+		wantAccount string
+		wantBlobURL string
+	}{
+		{
+			raw:         "http://127.0.0.1:10000/devstoreaccount1",
+			wantAccount: "devstoreaccount1",
+			wantBlobURL: "http://127.0.0.1:10000/devstoreaccount1/c/dir/a%20b.txt",
+		},
+		{
+			raw:         "http://localhost:10000/devstoreaccount1/",
+			wantAccount: "devstoreaccount1",
+			wantBlobURL: "http://localhost:10000/devstoreaccount1/c/dir/a%20b.txt",
+		},
+		{
+			raw:         "https://proxy.example.com/storage",
+			wantAccount: "testaccount",
+			wantBlobURL: "https://proxy.example.com/storage/c/dir/a%20b.txt",
+		},
+		{
+			raw:         "https://proxy.example.com",
+			wantAccount: "testaccount",
+			wantBlobURL: "https://proxy.example.com/c/dir/a%20b.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			useTestAccount(t)
+			if err := setServiceURL(tt.raw); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(bucketAccountName()); got != tt.wantAccount {
+				t.Errorf("bucketAccountName() = %q, want %q", got, tt.wantAccount)
+			}
+			u := newBlobURL("c", "dir/a b.txt").URL()
+			if got := u.String(); got != tt.wantBlobURL {
+				t.Errorf("newBlobURL() = %s, want %s", got, tt.wantBlobURL)
+			}
+		})
+	}
+}
+
+func TestSetServiceURLInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"127.0.0.1:10000/devstoreaccount1",
+		"ftp://127.0.0.1/devstoreaccount1",
+		"http:///devstoreaccount1",
+		"http://127.0.0.1:10000/devstoreaccount1?sv=1",
+		// Local hosts need the account in the path
+		"http://127.0.0.1:10000",
+	} {
+		useTestAccount(t)
+		if err := setServiceURL(raw); err == nil {
+			t.Errorf("setServiceURL(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestServiceURLRequests(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "dir/a b.txt", "--blob-value", "v"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, _, err := executeFake(t, s, "read", "--blob-key", "dir/a b.txt"); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	// The container and key are joined onto the path of the service URL
+	want := "/" + string(emulatorAccountName) + "/test/dir/a b.txt"
+	var reads int
+	for _, r := range s.requests {
+		if r.URL.Path != want {
+			t.Errorf("%s request to %q, want %q", r.Method, r.URL.Path, want)
+		}
+		if r.Method == http.MethodGet {
+			reads++
+		}
+	}
+	if reads == 0 {
+		t.Error("read sent no GET request")
+	}
+}
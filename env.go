@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// maxEnvBlobSize is the largest blob read --as-env accepts. Environment
+// blobs are meant to be small configuration files.
+const maxEnvBlobSize = 1 << 20
+
+// envNameRE matches valid shell variable names.
+var envNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// writeEnvExports reads KEY=VALUE lines from r and writes them to w as shell
+// export statements suitable for eval. Blank lines and lines starting with
+// "#" are skipped, and an optional leading "export " is accepted. Values
+// may be wrapped in single or double quotes, which are removed.
+func writeEnvExports(w io.Writer, r io.Reader) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxEnvBlobSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxEnvBlobSize {
+		return fmt.Errorf("blob is larger than %s, too large to read as environment", formatBytes(maxEnvBlobSize))
+	}
+
+	var exports []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return fmt.Errorf("line %d: expected KEY=VALUE, got %q", n, line)
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if !envNameRE.MatchString(name) {
+			return fmt.Errorf("line %d: %q is not a valid variable name", n, name)
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		exports = append(exports, fmt.Sprintf("export %s=%s", name, shellQuote(value)))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Only print once the whole blob is known to be valid, so that a
+	// failing read never evals a partial environment.
+	for _, e := range exports {
+		if _, err := fmt.Fprintln(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell using single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
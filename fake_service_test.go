@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gocloud.dev/blob"
+)
+
+// fakeService is an in-memory blob service with the path-style layout of
+// Azurite, implementing the few operations the commands under test send:
+// container properties and metadata, block blob uploads, and reading,
+// deleting (if matching If-Match) and setting the metadata of blobs, copies
+// within the account, which complete at once, and listings in a single
+// page. Requests aren't authenticated.
+type fakeService struct {
+	URL string
+
+	mu         sync.Mutex
+	containers map[string]*fakeContainer
+	// requests records the method, path and headers of every request.
+	requests []*http.Request
+}
+
+type fakeContainer struct {
+	metadata http.Header
+	blobs    map[string]*fakeBlob
+	staged   map[string][]byte
+}
+
+// fakeBlob is a blob with its content and the headers it is served with.
+type fakeBlob struct {
+	content []byte
+	header  http.Header
+}
+
+// blobHeaders maps the headers a blob is written with to those it is
+// served with.
+var blobHeaders = map[string]string{
+	"x-ms-blob-content-type":     "Content-Type",
+	"x-ms-blob-content-encoding": "Content-Encoding",
+	"x-ms-blob-cache-control":    "Cache-Control",
+	"x-ms-blob-content-md5":      "Content-MD5",
+}
+
+// newFakeService starts a fake service with the named containers, whose
+// account is at its URL.
+func newFakeService(t *testing.T, containers ...string) *fakeService {
+	s := &fakeService{containers: make(map[string]*fakeContainer)}
+	for _, name := range containers {
+		s.containers[name] = newFakeContainer()
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	s.URL = srv.URL + "/" + string(emulatorAccountName)
+	return s
+}
+
+func newFakeContainer() *fakeContainer {
+	return &fakeContainer{metadata: http.Header{}, blobs: make(map[string]*fakeBlob), staged: make(map[string][]byte)}
+}
+
+// blob returns the named blob, or nil if it doesn't exist.
+func (s *fakeService) blob(container, key string) *fakeBlob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.containers[container]; c != nil {
+		return c.blobs[key]
+	}
+	return nil
+}
+
+func (s *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+
+	// The path is /account/container[/key]
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != string(emulatorAccountName) {
+		fakeError(w, http.StatusBadRequest, "InvalidUri")
+		return
+	}
+	q := r.URL.Query()
+	if len(parts) == 2 {
+		s.serveContainer(w, r, parts[1], q.Get("comp"))
+		return
+	}
+	c := s.containers[parts[1]]
+	if c == nil {
+		fakeError(w, http.StatusNotFound, "ContainerNotFound")
+		return
+	}
+	s.serveBlob(w, r, c, parts[2], q.Get("comp"))
+}
+
+func (s *fakeService) serveContainer(w http.ResponseWriter, r *http.Request, name, comp string) {
+	c := s.containers[name]
+	switch {
+	case r.Method == http.MethodPut && comp == "":
+		if c != nil {
+			fakeError(w, http.StatusConflict, "ContainerAlreadyExists")
+			return
+		}
+		s.containers[name] = newFakeContainer()
+		fakeWritten(w, http.StatusCreated)
+	case c == nil:
+		fakeError(w, http.StatusNotFound, "ContainerNotFound")
+	case r.Method == http.MethodPut && comp == "metadata":
+		c.metadata = metadataHeaders(r.Header)
+		fakeWritten(w, http.StatusOK)
+	case r.Method == http.MethodGet && comp == "list":
+		serveFakeList(w, r, c)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && comp == "":
+		copyHeaders(w.Header(), c.metadata)
+		fakeWritten(w, http.StatusOK)
+	case r.Method == http.MethodDelete:
+		delete(s.containers, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		fakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeService) serveBlob(w http.ResponseWriter, r *http.Request, c *fakeContainer, key, comp string) {
+	b := c.blobs[key]
+	switch {
+	case r.Method == http.MethodPut && comp == "block":
+		body, _ := ioutil.ReadAll(r.Body)
+		c.staged[r.URL.Query().Get("blockid")] = body
+		fakeWritten(w, http.StatusCreated)
+	case r.Method == http.MethodPut && comp == "blocklist":
+		var list struct {
+			Blocks []string `xml:",any"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			fakeError(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		var content []byte
+		for _, id := range list.Blocks {
+			content = append(content, c.staged[id]...)
+		}
+		c.blobs[key] = newFakeBlob(content, r.Header)
+		fakeBlobWritten(w, c.blobs[key], http.StatusCreated)
+	case r.Method == http.MethodPut && comp == "" && r.Header.Get("x-ms-copy-source") != "":
+		s.serveCopy(w, c, key, r.Header.Get("x-ms-copy-source"))
+	case r.Method == http.MethodPut && comp == "" && r.Header.Get("x-ms-blob-type") != "":
+		if b != nil && r.Header.Get("If-None-Match") == "*" {
+			fakeError(w, http.StatusConflict, "BlobAlreadyExists")
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		c.blobs[key] = newFakeBlob(body, r.Header)
+		fakeBlobWritten(w, c.blobs[key], http.StatusCreated)
+	case b == nil:
+		fakeError(w, http.StatusNotFound, "BlobNotFound")
+	case r.Method == http.MethodPut && comp == "appendblock":
+		if b.header.Get("x-ms-blob-type") != "AppendBlob" {
+			fakeError(w, http.StatusConflict, "InvalidBlobType")
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("x-ms-blob-append-offset", strconv.Itoa(len(b.content)))
+		b.content = append(b.content, body...)
+		b.header.Set("ETag", newFakeETag())
+		fakeBlobWritten(w, b, http.StatusCreated)
+	case r.Method == http.MethodPut && comp == "metadata":
+		for name := range b.header {
+			if strings.HasPrefix(strings.ToLower(name), "x-ms-meta-") {
+				b.header.Del(name)
+			}
+		}
+		copyHeaders(b.header, metadataHeaders(r.Header))
+		b.header.Set("ETag", newFakeETag())
+		fakeBlobWritten(w, b, http.StatusOK)
+	case r.Method == http.MethodHead && comp == "":
+		copyHeaders(w.Header(), b.header)
+		w.Header().Set("Content-Length", strconv.Itoa(len(b.content)))
+		fakeWritten(w, http.StatusOK)
+	case r.Method == http.MethodGet && comp == "":
+		serveFakeContent(w, r, b)
+	case r.Method == http.MethodDelete:
+		if m := r.Header.Get("If-Match"); m != "" && m != b.header.Get("ETag") {
+			fakeError(w, http.StatusPreconditionFailed, "ConditionNotMet")
+			return
+		}
+		delete(c.blobs, key)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		fakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// fakeListing is the response of List Blobs.
+type fakeListing struct {
+	XMLName  xml.Name `xml:"EnumerationResults"`
+	Prefix   string
+	Blobs    []fakeListedBlob `xml:"Blobs>Blob"`
+	Prefixes []string         `xml:"Blobs>BlobPrefix>Name"`
+	// An empty NextMarker ends the listing
+	NextMarker string
+}
+
+type fakeListedBlob struct {
+	Name       string
+	Properties struct {
+		LastModified  string `xml:"Last-Modified"`
+		Etag          string
+		ContentLength int    `xml:"Content-Length"`
+		ContentType   string `xml:"Content-Type"`
+		ContentMD5    string `xml:"Content-MD5"`
+		BlobType      string
+	}
+}
+
+// serveFakeList lists the blobs of c under the prefix of r, grouped by its
+// delimiter if any, in one page.
+func serveFakeList(w http.ResponseWriter, r *http.Request, c *fakeContainer) {
+	q := r.URL.Query()
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	keys := make([]string, 0, len(c.blobs))
+	for key := range c.blobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	listing := fakeListing{Prefix: prefix}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			dir := key[:len(prefix)+i+len(delimiter)]
+			if n := len(listing.Prefixes); n == 0 || listing.Prefixes[n-1] != dir {
+				listing.Prefixes = append(listing.Prefixes, dir)
+			}
+			continue
+		}
+		b := c.blobs[key]
+		item := fakeListedBlob{Name: key}
+		item.Properties.LastModified = b.header.Get("Last-Modified")
+		item.Properties.Etag = b.header.Get("ETag")
+		item.Properties.ContentLength = len(b.content)
+		item.Properties.ContentType = b.header.Get("Content-Type")
+		item.Properties.ContentMD5 = b.header.Get("Content-MD5")
+		item.Properties.BlobType = "BlockBlob"
+		listing.Blobs = append(listing.Blobs, item)
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(listing)
+}
+
+// serveCopy copies the blob at the URL source to key in c.
+func (s *fakeService) serveCopy(w http.ResponseWriter, c *fakeContainer, key, source string) {
+	u, err := url.Parse(source)
+	if err != nil {
+		fakeError(w, http.StatusBadRequest, "InvalidHeaderValue")
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 3)
+	var src *fakeBlob
+	if len(parts) == 3 && s.containers[parts[1]] != nil {
+		src = s.containers[parts[1]].blobs[parts[2]]
+	}
+	if src == nil {
+		fakeError(w, http.StatusNotFound, "CannotVerifyCopySource")
+		return
+	}
+
+	b := &fakeBlob{content: src.content, header: http.Header{}}
+	copyHeaders(b.header, src.header)
+	b.header.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	b.header.Set("ETag", newFakeETag())
+	c.blobs[key] = b
+	w.Header().Set("x-ms-copy-id", strconv.FormatInt(time.Now().UnixNano(), 16))
+	w.Header().Set("x-ms-copy-status", "success")
+	fakeBlobWritten(w, b, http.StatusAccepted)
+}
+
+// newFakeBlob returns a blob with content and the headers set by a request
+// with header.
+func newFakeBlob(content []byte, header http.Header) *fakeBlob {
+	b := &fakeBlob{content: content, header: metadataHeaders(header)}
+	for from, to := range blobHeaders {
+		if v := header.Get(from); v != "" {
+			b.header.Set(to, v)
+		}
+	}
+	if b.header.Get("Content-Type") == "" {
+		b.header.Set("Content-Type", "application/octet-stream")
+	}
+	b.header.Set("x-ms-blob-type", "BlockBlob")
+	if header.Get("x-ms-blob-type") == "AppendBlob" {
+		b.header.Set("x-ms-blob-type", "AppendBlob")
+	}
+	b.header.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	b.header.Set("ETag", newFakeETag())
+	return b
+}
+
+// serveFakeContent serves the content of b, or the range of it given by
+// the x-ms-range or Range header.
+func serveFakeContent(w http.ResponseWriter, r *http.Request, b *fakeBlob) {
+	copyHeaders(w.Header(), b.header)
+	rng := r.Header.Get("x-ms-range")
+	if rng == "" {
+		rng = r.Header.Get("Range")
+	}
+	if rng == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(b.content)))
+		fakeWritten(w, http.StatusOK)
+		w.Write(b.content)
+		return
+	}
+
+	var start, end int
+	if n, _ := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); n == 0 || start >= len(b.content) {
+		fakeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+		return
+	} else if n == 1 || end >= len(b.content) {
+		end = len(b.content) - 1
+	}
+	// Ranges are served with the MD5 of the whole blob in its own header
+	w.Header().Del("Content-MD5")
+	if sum := b.header.Get("Content-MD5"); sum != "" {
+		w.Header().Set("x-ms-blob-content-md5", sum)
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(b.content)))
+	w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+	fakeWritten(w, http.StatusPartialContent)
+	w.Write(b.content[start : end+1])
+}
+
+// metadataHeaders returns the x-ms-meta- headers of header.
+func metadataHeaders(header http.Header) http.Header {
+	metadata := http.Header{}
+	for name, values := range header {
+		if strings.HasPrefix(strings.ToLower(name), "x-ms-meta-") {
+			metadata[name] = values
+		}
+	}
+	return metadata
+}
+
+func copyHeaders(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = values
+	}
+}
+
+// fakeWritten writes the status of a successful response, with a new ETag
+// and modified now unless it serves a blob.
+func fakeWritten(w http.ResponseWriter, status int) {
+	if w.Header().Get("ETag") == "" {
+		w.Header().Set("ETag", newFakeETag())
+	}
+	if w.Header().Get("Last-Modified") == "" {
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(status)
+}
+
+// fakeBlobWritten writes the status of a successful write of b.
+func fakeBlobWritten(w http.ResponseWriter, b *fakeBlob, status int) {
+	w.Header().Set("ETag", b.header.Get("ETag"))
+	w.Header().Set("Last-Modified", b.header.Get("Last-Modified"))
+	fakeWritten(w, status)
+}
+
+// newFakeETag returns a new ETag, unique within the process.
+func newFakeETag() string {
+	return `"0x8D9` + strconv.FormatInt(time.Now().UnixNano(), 16) + `"`
+}
+
+// fakeError writes an error response of the service.
+func fakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// contentMD5 returns the base64 MD5 of content, as in a Content-MD5 header.
+func contentMD5(content []byte) string {
+	sum := md5.Sum(content)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// executeFake runs the command line args against s, as execute does.
+func executeFake(t *testing.T, s *fakeService, args ...string) (string, string, error) {
+	t.Helper()
+	useTestAccount(t)
+	return execute(t, append(args, "--service-url", s.URL)...)
+}
+
+// openFakeBucket opens the test container of s as commands do.
+func openFakeBucket(t *testing.T, s *fakeService) *blob.Bucket {
+	t.Helper()
+	useTestAccount(t)
+	if err := setServiceURL(s.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := initPipeline(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := openContainer(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	scriptStyle string
+
+	// Commands
+	generateScriptCmd = &cobra.Command{
+		Use:   "generate-script",
+		Short: "Generate a shell script fetching every blob under a prefix",
+		Long: `Generate a shell script fetching every blob under a prefix.
+
+A POSIX shell script with one command per blob under --blob-prefix is
+printed, to be reviewed and run later or handed off as a reproducible fetch
+plan. Every blob is fetched into a file named after its key, under the
+current directory, creating its directories first. With --style download
+(the default), every command is an "azure download-file" of the blob,
+which verifies its MD5. With --style curl, every command downloads a
+signed URL, valid for --expiry, with curl; such a script needs no
+credentials to run. Keys that are not safe to use as relative file paths
+are left out with a warning.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if scriptStyle != "download" && scriptStyle != "curl" {
+				return fmt.Errorf(`flag "--style" should be one of "download" or "curl"`)
+			}
+			if scriptStyle == "curl" && expiry <= 0 {
+				return fmt.Errorf(`flag "--expiry" should be positive`)
+			}
+			if scriptStyle == "curl" {
+				if err := checkSharedKey(errOut, "signing the URLs of --style curl"); err != nil {
+					return err
+				}
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			fmt.Fprintln(out, "#!/bin/sh")
+			fmt.Fprintf(out, "# Fetch the blobs under %q in container %q.\n", blobPrefix, containerName)
+			fmt.Fprintln(out, "set -e")
+
+			var (
+				n       int
+				lastDir string
+			)
+			iter := bucket.List(&blob.ListOptions{Prefix: blobPrefix})
+			for {
+				obj, err := iter.Next(ctx)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+
+				if !isSafeRelativePath(obj.Key) {
+					fmt.Fprintf(errOut, "Skipping %q: not safe to use as a file path\n", obj.Key)
+					continue
+				}
+				// mkdir -p of a directory that exists is a no-op, so it is
+				// only left out for the directory of the previous key
+				if dir := path.Dir(obj.Key); dir != "." && dir != lastDir {
+					fmt.Fprintf(out, "mkdir -p -- %s\n", shellQuote(dir))
+					lastDir = dir
+				}
+
+				if scriptStyle == "download" {
+					fmt.Fprintf(out, "azure download-file --container-name %s --blob-key %s --output %s\n", shellQuote(containerName), shellQuote(obj.Key), shellQuote(obj.Key))
+					n++
+					continue
+				}
+
+				u, err := signURL(ctx, bucket, obj.Key, &blob.SignedURLOptions{Expiry: expiry})
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "curl -fsS -o %s %s\n", shellQuote(obj.Key), shellQuote(u))
+				n++
+			}
+
+			fmt.Fprintf(errOut, "Generated %d commands\n", n)
+			return nil
+		},
+	}
+)
+
+// isSafeRelativePath reports whether key can be used as a file path that
+// stays within the current directory.
+func isSafeRelativePath(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return false
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	generateScriptCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to fetch the blobs under")
+	generateScriptCmd.PersistentFlags().StringVar(&scriptStyle, "style", "download", "indicate a script style (download or curl)")
+	generateScriptCmd.PersistentFlags().DurationVar(&expiry, "expiry", time.Hour, "indicate how long the signed URLs of a curl script stay valid")
+
+	rootCmd.AddCommand(generateScriptCmd)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateScript(t *testing.T) {
+	s := newFakeService(t, "test")
+	for _, key := range []string{"logs/a b.txt", "logs/it's.txt", "top.txt", "bad/../x"} {
+		if _, _, err := executeFake(t, s, "write", "--blob-key", key, "--blob-value", "v"); err != nil {
+			t.Fatalf("write %q: %v", key, err)
+		}
+	}
+
+	stdout, stderr, err := executeFake(t, s, "generate-script")
+	if err != nil {
+		t.Fatalf("generate-script: %v", err)
+	}
+	want := `#!/bin/sh
+# Fetch the blobs under "" in container "test".
+set -e
+mkdir -p -- 'logs'
+azure download-file --container-name 'test' --blob-key 'logs/a b.txt' --output 'logs/a b.txt'
+azure download-file --container-name 'test' --blob-key 'logs/it'\''s.txt' --output 'logs/it'\''s.txt'
+azure download-file --container-name 'test' --blob-key 'top.txt' --output 'top.txt'
+`
+	if stdout != want {
+		t.Errorf("generate-script printed\n%s\nwant\n%s", stdout, want)
+	}
+	if !strings.Contains(stderr, `Skipping "bad/../x"`) || !strings.Contains(stderr, "Generated 3 commands") {
+		t.Errorf("generate-script printed %q to stderr", stderr)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/andybalholm/brotli"
+	"gocloud.dev/blob"
+)
+
+// rawRead is set with --raw to read encoded blobs without decompressing
+// them.
+var rawRead bool
+
+// identityEncodingPipeline asks for the content as stored. Otherwise the
+// HTTP transport asks for gzip itself and silently decompresses blobs
+// stored with Content-Encoding gzip, which then neither match their
+// Content-MD5 nor can be read raw.
+type identityEncodingPipeline struct {
+	pipeline.Pipeline
+}
+
+func (p identityEncodingPipeline) Do(ctx context.Context, methodFactory pipeline.Factory, request pipeline.Request) (pipeline.Response, error) {
+	request.Header.Set("Accept-Encoding", "identity")
+	return p.Pipeline.Do(ctx, methodFactory, request)
+}
+
+// gzipReader returns a reader of the content of src compressed with gzip.
+// The content is compressed as it is read, and closing the reader stops
+// the compression.
+func gzipReader(src io.Reader) *io.PipeReader {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, src)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// decodedReader returns a reader of the whole blob r, verified against its
+// stored MD5 and decompressed according to its Content-Encoding, unless
+// --raw is set. Encodings applied in turn, e.g. "deflate, gzip", are
+// undone in reverse order. gzip, deflate and br are supported, and other
+// encodings are an error.
+func decodedReader(r *blob.Reader) (io.Reader, error) {
+	src := verifyMD5(r)
+	if rawRead {
+		return src, nil
+	}
+
+	encodings := strings.Split(contentEncoding(r), ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch encoding := strings.ToLower(strings.TrimSpace(encodings[i])); encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			src, err = gzip.NewReader(src)
+		case "deflate":
+			src, err = deflateReader(src)
+		case "br":
+			src = brotli.NewReader(src)
+		default:
+			return nil, fmt.Errorf("cannot decompress Content-Encoding %q, set \"--raw\" to read the blob as stored", encoding)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return src, nil
+}
+
+// isEncoded reports whether the blob r reads is decompressed by
+// decodedReader, so that its stored size is not the size read.
+func isEncoded(r *blob.Reader) bool {
+	encoding := strings.TrimSpace(contentEncoding(r))
+	return !rawRead && encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+// deflateReader returns a reader of the content of src compressed with
+// deflate. As in HTTP, the content should be zlib wrapped, but raw deflate,
+// which some servers send instead, is read as well.
+func deflateReader(src io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(src)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// A zlib header is a deflate method byte and a check of both bytes
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// contentEncoding returns the Content-Encoding of the blob r reads.
+func contentEncoding(r *blob.Reader) string {
+	var resp azblob.DownloadResponse
+	if !r.As(&resp) {
+		return ""
+	}
+	return resp.ContentEncoding()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compress returns content compressed with the writer newWriter returns.
+func compress(t *testing.T, content []byte, newWriter func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadDecodesContentEncoding(t *testing.T) {
+	content := bytes.Repeat([]byte("pre-compressed web asset\n"), 100)
+	gzipWriter := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	zlibWriter := func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	flateWriter := func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}
+	brotliWriter := func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+
+	tests := []struct {
+		name     string
+		encoding string
+		stored   []byte
+		wantErr  bool
+	}{
+		{"gzip", "gzip", compress(t, content, gzipWriter), false},
+		{"deflate", "deflate", compress(t, content, zlibWriter), false},
+		{"raw deflate", "deflate", compress(t, content, flateWriter), false},
+		{"brotli", "br", compress(t, content, brotliWriter), false},
+		{"chain", "deflate, gzip", compress(t, compress(t, content, zlibWriter), gzipWriter), false},
+		{"unsupported", "compress", content, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeService(t, "test")
+			s.containers["test"].blobs["asset"] = newFakeBlob(tt.stored, http.Header{
+				"X-Ms-Blob-Content-Encoding": {tt.encoding},
+			})
+			path := filepath.Join(t.TempDir(), "asset")
+
+			_, _, err := executeFake(t, s, "download-file", "--blob-key", "asset", "--output", path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("download-file of Content-Encoding %q succeeded", tt.encoding)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("download-file: %v", err)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("downloaded %d bytes, want the %d bytes decompressed", len(got), len(content))
+			}
+		})
+	}
+}
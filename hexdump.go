@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	dumpBytes int64
+
+	// Commands
+	hexdumpCmd = &cobra.Command{
+		Use:   "hexdump",
+		Short: "Print the first bytes of a blob as a hex dump",
+		Long: `Print the first bytes of a blob as a hex dump.
+
+Only the first --bytes bytes of --blob-key are fetched, with a range read,
+and printed as offset, hex and ASCII columns, which is useful to inspect the
+header or magic number of a large binary blob without downloading it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if dumpBytes < 1 {
+				return fmt.Errorf(`flag "--bytes" should be at least 1`)
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			r, err := bucket.NewRangeReader(ctx, blobKey, 0, dumpBytes, nil)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			d := hex.Dumper(out)
+			if _, err := io.Copy(d, r); err != nil {
+				return err
+			}
+			if err := d.Close(); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	hexdumpCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to dump")
+	hexdumpCmd.PersistentFlags().Int64Var(&dumpBytes, "bytes", 256, "indicate a number of bytes to dump from the start of the blob")
+
+	rootCmd.AddCommand(hexdumpCmd)
+}
@@ -0,0 +1,123 @@
+//go:build integration
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The integration tests run the commands against Azurite, started e.g. with
+//
+//	docker run -p 10000:10000 mcr.microsoft.com/azure-storage/azurite azurite-blob --blobHost 0.0.0.0
+//
+// and run with
+//
+//	go test -tags integration ./...
+//
+// They use the well-known account of the emulator, at the address of
+// --emulator or at AZURITE_SERVICE_URL, e.g.
+// "http://azurite:10000/devstoreaccount1", and are skipped if Azurite
+// can't be reached.
+
+// azuriteArgs returns the flags pointing commands at Azurite, skipping the
+// test if it isn't running.
+func azuriteArgs(t *testing.T) []string {
+	t.Helper()
+	args := []string{"--emulator"}
+	host := string(emulatorDomain)
+	if raw := os.Getenv("AZURITE_SERVICE_URL"); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("AZURITE_SERVICE_URL: %v", err)
+		}
+		args = []string{"--service-url", raw, "--account-name", string(emulatorAccountName), "--account-key", string(emulatorAccountKey)}
+		host = u.Host
+	}
+
+	conn, err := net.DialTimeout("tcp", host, time.Second)
+	if err != nil {
+		t.Skipf("Azurite is not running at %s: %v", host, err)
+	}
+	conn.Close()
+	return args
+}
+
+// azurite runs the command line args against Azurite in container, as
+// execute does.
+func azurite(t *testing.T, container string, args ...string) (string, string, error) {
+	t.Helper()
+	useTestAccount(t)
+	args = append(append([]string{}, args...), "--container-name", container)
+	return executeArgs(t, append(args, azuriteArgs(t)...))
+}
+
+func TestAzurite(t *testing.T) {
+	azuriteArgs(t)
+	container := fmt.Sprintf("integration-%d", time.Now().UnixNano())
+
+	if _, _, err := azurite(t, container, "create-container"); err != nil {
+		t.Fatalf("create-container: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, _, err := azurite(t, container, "delete-container"); err != nil {
+			t.Errorf("delete-container: %v", err)
+		}
+	})
+	// A second create fails unless --if-not-exists is set
+	if _, _, err := azurite(t, container, "create-container"); err == nil {
+		t.Error("create-container of an existing container succeeded")
+	}
+	if _, _, err := azurite(t, container, "create-container", "--if-not-exists"); err != nil {
+		t.Errorf("create-container --if-not-exists: %v", err)
+	}
+
+	keys := []string{"logs/a b.txt", "logs/ü.txt", "other.txt"}
+	for _, key := range keys {
+		if _, _, err := azurite(t, container, "write", "--blob-key", key, "--blob-value", "content of "+key, "--content-type", "text/plain"); err != nil {
+			t.Fatalf("write %q: %v", key, err)
+		}
+	}
+
+	stdout, _, err := azurite(t, container, "read", "--blob-key", "logs/a b.txt")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "content of logs/a b.txt\n"; stdout != want {
+		t.Errorf("read printed %q, want %q", stdout, want)
+	}
+
+	stdout, _, err = azurite(t, container, "list", "--blob-prefix", "logs/", "--output", "csv")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for _, key := range keys {
+		if listed := strings.Contains(stdout, key); listed != strings.HasPrefix(key, "logs/") {
+			t.Errorf("list of logs/ printed %q, listing %q: %v", stdout, key, listed)
+		}
+	}
+
+	if _, _, err := azurite(t, container, "delete-prefix", "--blob-prefix", "logs/", "--yes"); err != nil {
+		t.Fatalf("delete-prefix: %v", err)
+	}
+	for _, key := range keys {
+		_, _, err := azurite(t, container, "blob-exists", "--blob-key", key, "--quiet")
+		var exitErr *exitError
+		switch {
+		case strings.HasPrefix(key, "logs/") && !(errors.As(err, &exitErr) && exitErr.Code == 1):
+			t.Errorf("blob-exists %q after delete-prefix: got %v, want exit status 1", key, err)
+		case !strings.HasPrefix(key, "logs/") && err != nil:
+			t.Errorf("blob-exists %q after delete-prefix: %v", key, err)
+		}
+	}
+
+	if _, _, err := azurite(t, container, "read", "--blob-key", "logs/a b.txt"); err == nil {
+		t.Error("read of a deleted blob succeeded")
+	}
+}
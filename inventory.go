@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	outputFile string
+
+	// Commands
+	inventoryCmd = &cobra.Command{
+		Use:   "inventory",
+		Short: "Export the blobs of a container as CSV",
+		Long: `Export the blobs of a container as CSV.
+
+Every blob under --blob-prefix is written as a row of key, size, modtime,
+content-type, etag and tier to --output-file, or to standard output if it is
+not set. The container is listed one page at a time and every page is written
+out before the next one is fetched, so memory use does not grow with the size
+of the container.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			errOut := cmd.ErrOrStderr()
+
+			if outputFile == "" {
+				n, err := writeInventory(ctx, cmd.OutOrStdout(), blobPrefix)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(errOut, "Exported %d blobs\n", n)
+				return nil
+			}
+
+			f, err := os.Create(outputFile)
+			if err != nil {
+				return err
+			}
+			n, err := writeInventory(ctx, f, blobPrefix)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(errOut, "Exported %d blobs\n", n)
+
+			logger.Info(fmt.Sprintf("Successfully exported inventory of %q to %q", containerName, outputFile))
+			return nil
+		},
+	}
+)
+
+// inventoryHeader is the header row of the inventory CSV.
+var inventoryHeader = []string{"key", "size", "modtime", "content-type", "etag", "tier"}
+
+// writeInventory writes the blobs under prefix to out as CSV and returns the
+// number of blobs written.
+func writeInventory(ctx context.Context, out io.Writer, prefix string) (int, error) {
+	w := csv.NewWriter(out)
+	if err := w.Write(inventoryHeader); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	containerURL := newContainerURL(containerName)
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix:     prefix,
+			MaxResults: listPageSize,
+		})
+		if err != nil {
+			return n, err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			var size int64
+			if item.Properties.ContentLength != nil {
+				size = *item.Properties.ContentLength
+			}
+			var contentType string
+			if item.Properties.ContentType != nil {
+				contentType = *item.Properties.ContentType
+			}
+			if err := w.Write([]string{
+				item.Name,
+				strconv.FormatInt(size, 10),
+				item.Properties.LastModified.UTC().Format(time.RFC3339),
+				contentType,
+				string(item.Properties.Etag),
+				string(item.Properties.AccessTier),
+			}); err != nil {
+				return n, err
+			}
+			n++
+		}
+
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return n, err
+		}
+		marker = resp.NextMarker
+	}
+	return n, nil
+}
+
+func init() {
+	inventoryCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to export the blobs under")
+	inventoryCmd.PersistentFlags().StringVar(&outputFile, "output-file", "", "indicate a file to write the CSV to (stdout if empty)")
+
+	rootCmd.AddCommand(inventoryCmd)
+}
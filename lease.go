@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	leaseDuration int
+	leaseID       string
+
+	// Commands
+	leaseAcquireCmd = &cobra.Command{
+		Use:         "lease-acquire",
+		Short:       "Acquire a lease on a blob",
+		Annotations: mutating,
+		Long: `Acquire a lease on a blob.
+
+A lease of --duration seconds, between 15 and 60, or -1 for a lease that
+never expires, is acquired on --blob-key, so that only the lease holder can
+write or delete the blob. The lease ID is printed to standard output, so it
+can be captured with:
+
+  lease=$(azure lease-acquire --blob-key foo --duration 60)
+
+Acquiring fails if the blob is already leased.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if leaseDuration != -1 && (leaseDuration < 15 || leaseDuration > 60) {
+				return fmt.Errorf(`flag "--duration" should be between 15 and 60 seconds, or -1 for an infinite lease`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			resp, err := newBlobURL(containerName, blobKey).AcquireLease(ctx, "", int32(leaseDuration), azblob.ModifiedAccessConditions{})
+			if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeLeaseAlreadyPresent {
+				return fmt.Errorf("blob %q is already leased", blobKey)
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(out, resp.LeaseID())
+			logger.Info(fmt.Sprintf("Successfully acquired a lease on %q", blobKey))
+			return nil
+		},
+	}
+
+	leaseReleaseCmd = &cobra.Command{
+		Use:         "lease-release",
+		Short:       "Release a lease on a blob",
+		Annotations: mutating,
+		Long: `Release a lease on a blob.
+
+The lease --lease-id, as printed by lease-acquire, is released, so that the
+blob can be written and leased by others right away.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if leaseID == "" {
+				return fmt.Errorf(`flag "--lease-id" should be set`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			_, err := newBlobURL(containerName, blobKey).ReleaseLease(ctx, leaseID, azblob.ModifiedAccessConditions{})
+			if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeLeaseIDMismatchWithLeaseOperation {
+				return fmt.Errorf("blob %q is not leased with lease ID %q", blobKey, leaseID)
+			}
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully released the lease on %q", blobKey))
+			return nil
+		},
+	}
+
+	leaseBreakCmd = &cobra.Command{
+		Use:         "lease-break",
+		Short:       "Break the lease on a blob",
+		Annotations: mutating,
+		Long: `Break the lease on a blob.
+
+The lease on --blob-key is broken immediately without knowing its lease ID,
+e.g. to recover from a lease holder that died. The blob can then be leased
+again.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			_, err := newBlobURL(containerName, blobKey).BreakLease(ctx, 0, azblob.ModifiedAccessConditions{})
+			if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeLeaseNotPresentWithLeaseOperation {
+				return fmt.Errorf("blob %q is not leased", blobKey)
+			}
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully broke the lease on %q", blobKey))
+			return nil
+		},
+	}
+)
+
+func init() {
+	leaseAcquireCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to lease")
+	leaseAcquireCmd.PersistentFlags().IntVar(&leaseDuration, "duration", 60, "indicate a lease duration in seconds (15 to 60, or -1 for infinite)")
+	leaseReleaseCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to release the lease on")
+	leaseReleaseCmd.PersistentFlags().StringVar(&leaseID, "lease-id", "", "indicate a lease ID to release")
+	leaseBreakCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to break the lease on")
+
+	rootCmd.AddCommand(leaseAcquireCmd)
+	rootCmd.AddCommand(leaseReleaseCmd)
+	rootCmd.AddCommand(leaseBreakCmd)
+}
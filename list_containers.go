@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	containerPrefix string
+
+	// Commands
+	listContainersCmd = &cobra.Command{
+		Use:         "list-containers",
+		Short:       "List the containers of the account",
+		Annotations: accountLevel,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			n := 0
+			svcURL := azblob.NewServiceURL(serviceURL(), pline)
+			// The service returns at most 5000 containers per segment, so
+			// keep going until it stops returning a marker.
+			for marker := (azblob.Marker{}); marker.NotDone(); {
+				resp, err := svcURL.ListContainersSegment(ctx, marker, azblob.ListContainersSegmentOptions{
+					Prefix: containerPrefix,
+				})
+				if err != nil {
+					return err
+				}
+				for _, item := range resp.ContainerItems {
+					fmt.Fprintln(out, item.Name)
+				}
+				n += len(resp.ContainerItems)
+				marker = resp.NextMarker
+			}
+
+			if !quiet {
+				fmt.Fprintf(errOut, "Summary: %d containers\n", n)
+			}
+			logger.Info(fmt.Sprintf("Successfully listed containers of %q", accountName))
+			return nil
+		},
+	}
+)
+
+func init() {
+	listContainersCmd.PersistentFlags().StringVar(&containerPrefix, "prefix", "", "indicate a container name prefix to filter by")
+
+	rootCmd.AddCommand(listContainersCmd)
+}
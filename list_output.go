@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gocloud.dev/blob"
+)
+
+// listRecord is an entry of a listing printed with --output json or csv.
+type listRecord struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	IsDir   bool      `json:"isDir"`
+}
+
+// listWriter writes the entries of a listing as a JSON array or as CSV
+// rows with a header, with full keys and without indentation, so the output
+// can be parsed.
+type listWriter struct {
+	out    io.Writer
+	format string
+	csv    *csv.Writer
+	n      int
+}
+
+// newListWriter returns a listWriter writing to out in format, "json" or
+// "csv".
+func newListWriter(out io.Writer, format string) (*listWriter, error) {
+	w := &listWriter{out: out, format: format}
+	if format == "csv" {
+		w.csv = csv.NewWriter(out)
+		if err := w.csv.Write([]string{"key", "size", "modtime", "isdir"}); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	if _, err := fmt.Fprint(out, "["); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// write writes obj, whose key is the full key.
+func (w *listWriter) write(obj *blob.ListObject) error {
+	rec := listRecord{Key: obj.Key, Size: obj.Size, ModTime: obj.ModTime, IsDir: obj.IsDir}
+	w.n++
+
+	if w.csv != nil {
+		var modTime string
+		if !rec.ModTime.IsZero() {
+			modTime = rec.ModTime.UTC().Format(time.RFC3339)
+		}
+		return w.csv.Write([]string{rec.Key, strconv.FormatInt(rec.Size, 10), modTime, strconv.FormatBool(rec.IsDir)})
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if w.n == 1 {
+		sep = ""
+	}
+	_, err = fmt.Fprintf(w.out, "%s\n  %s", sep, data)
+	return err
+}
+
+// close finishes the output. A JSON array ends with a {"summary": ...}
+// object holding stats.
+func (w *listWriter) close(stats *listStats) error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+
+	data, err := json.Marshal(struct {
+		Summary *listStats `json:"summary"`
+	}{stats})
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if w.n == 0 {
+		sep = ""
+	}
+	_, err = fmt.Fprintf(w.out, "%s\n  %s\n]\n", sep, data)
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gocloud.dev/blob"
+)
+
+func TestListWriterJSONSummary(t *testing.T) {
+	objs := []*blob.ListObject{
+		{Key: "logs/", IsDir: true},
+		{Key: "logs/a.txt", Size: 3, ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	for n := 0; n <= len(objs); n++ {
+		var buf bytes.Buffer
+		w, err := newListWriter(&buf, "json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stats listStats
+		for _, obj := range objs[:n] {
+			if err := w.write(obj); err != nil {
+				t.Fatal(err)
+			}
+			stats.add(obj)
+		}
+		if err := w.close(&stats); err != nil {
+			t.Fatal(err)
+		}
+
+		// The output is a single array, ending with the summary
+		var entries []map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+			t.Fatalf("%d entries: invalid JSON %q: %v", n, buf.String(), err)
+		}
+		if len(entries) != n+1 {
+			t.Fatalf("%d entries: got %d elements, want %d", n, len(entries), n+1)
+		}
+		for i, obj := range objs[:n] {
+			if entries[i]["key"] != obj.Key {
+				t.Errorf("element %d has key %v, want %q", i, entries[i]["key"], obj.Key)
+			}
+		}
+		summary, ok := entries[n]["summary"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("%d entries: last element is %v, want the summary", n, entries[n])
+		}
+		if summary["files"] != float64(stats.Files) || summary["dirs"] != float64(stats.Dirs) || summary["bytes"] != float64(stats.Bytes) {
+			t.Errorf("%d entries: got summary %v, want %+v", n, summary, stats)
+		}
+	}
+}
+
+func TestPrintSummaryQuiet(t *testing.T) {
+	t.Cleanup(func() { quiet = false })
+	stats := &listStats{Files: 2, Dirs: 1, Bytes: 10}
+
+	var buf bytes.Buffer
+	quiet = false
+	printSummary(&buf, stats)
+	if want := "Summary: " + stats.String() + "\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	quiet = true
+	printSummary(&buf, stats)
+	if buf.Len() != 0 {
+		t.Errorf("got %q with --quiet, want nothing", buf.String())
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+
+	"gocloud.dev/blob"
+)
+
+// parallelPrefixes is set by --parallel-prefixes.
+var parallelPrefixes bool
+
+// listFunc lists the entries under prefix like blobstore.Client.List.
+type listFunc func(ctx context.Context, prefix string, recursive bool, fn func(obj *blob.ListObject, depth int) error) error
+
+// listedEntry is an entry of a listing with its depth under the prefix.
+type listedEntry struct {
+	obj   *blob.ListObject
+	depth int
+}
+
+// listParallel lists the entries under prefix recursively with list,
+// calling fn for each like list does and in the same order. The top level is
+// listed first, and then the directories of the top level are listed
+// concurrently on --concurrency workers, each into memory, and passed to fn
+// in order. fn is only called from the calling goroutine.
+func listParallel(ctx context.Context, list listFunc, prefix string, fn func(obj *blob.ListObject, depth int) error) error {
+	var top []*blob.ListObject
+	err := list(ctx, prefix, false, func(obj *blob.ListObject, depth int) error {
+		top = append(top, obj)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	subtrees := make([][]listedEntry, len(top))
+	errs := runPool(len(top), concurrency, func(i int) error {
+		if !top[i].IsDir {
+			return nil
+		}
+		return list(ctx, top[i].Key, true, func(obj *blob.ListObject, depth int) error {
+			subtrees[i] = append(subtrees[i], listedEntry{obj: obj, depth: depth + 1})
+			return nil
+		})
+	})
+	if len(errs) > 0 {
+		return errs[0].Err
+	}
+
+	for i, obj := range top {
+		if err := fn(obj, 0); err != nil {
+			return err
+		}
+		for _, e := range subtrees[i] {
+			if err := fn(e.obj, e.depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"gocloud.dev/blob"
+)
+
+// listShard is a part of the keyspace under a prefix, listed concurrently
+// with the others by listSharded.
+type listShard struct {
+	// prefix is the prefix listed. Unless the shard is the catch-all one,
+	// its keys are exactly those starting with it.
+	prefix string
+	// catchAll is set for the shard of the keys that the prefixes of the
+	// other shards can't select: the key equal to the listing prefix and
+	// the keys whose first character after it is not printable ASCII.
+	// There are too many such characters to list each as a prefix, so the
+	// shard lists every key under the listing prefix and skips the others.
+	catchAll bool
+}
+
+// covers reports whether the shard of the listing of prefix holds key.
+func (s listShard) covers(prefix, key string) bool {
+	if !s.catchAll {
+		return strings.HasPrefix(key, s.prefix)
+	}
+	if !strings.HasPrefix(key, prefix) {
+		return false
+	}
+	rest := key[len(prefix):]
+	return rest == "" || rest[0] < ' ' || rest[0] > '~'
+}
+
+// listShards returns the shards of the keyspace under prefix: one per
+// printable ASCII character after it, and the catch-all shard unless
+// asciiKeys is set. The catch-all shard comes first, as it is the slowest
+// to list.
+func listShards(prefix string, asciiKeys bool) []listShard {
+	var shards []listShard
+	if !asciiKeys {
+		shards = append(shards, listShard{prefix: prefix, catchAll: true})
+	}
+	for c := byte(' '); c <= '~'; c++ {
+		shards = append(shards, listShard{prefix: prefix + string(c)})
+	}
+	return shards
+}
+
+// listSharded lists all blobs in b under prefix as a flat namespace,
+// splitting the keyspace into the shards of listShards and listing them
+// concurrently with the given number of workers. Keys are printed to out as
+// they are found, so their order is not guaranteed. With asciiKeys, keys
+// whose first character after prefix is not printable ASCII are not
+// listed. It returns a summary of the blobs listed.
+func listSharded(ctx context.Context, out io.Writer, b *blob.Bucket, prefix string, workers int, asciiKeys bool) (*listStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	shards := make(chan listShard)
+	go func() {
+		defer close(shards)
+		for _, shard := range listShards(prefix, asciiKeys) {
+			select {
+			case shards <- shard:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		stats    listStats
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range shards {
+				iter := b.List(&blob.ListOptions{Prefix: shard.prefix})
+				for {
+					obj, err := iter.Next(ctx)
+					if err == io.EOF {
+						break
+					}
+					mu.Lock()
+					if err != nil {
+						if firstErr == nil {
+							firstErr = err
+							cancel()
+						}
+						mu.Unlock()
+						return
+					}
+					if shard.covers(prefix, obj.Key) {
+						fmt.Fprintln(out, obj.Key)
+						stats.add(obj)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return &stats, firstErr
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestListShardsCoverKeyspace(t *testing.T) {
+	const prefix = "logs/"
+
+	// Keys with every possible first byte after the prefix, and with
+	// multibyte characters
+	keys := []string{prefix, prefix + "été", prefix + "日本", prefix + "\U0001F600", prefix + "\x7f", prefix + "a\x00"}
+	for c := 0; c < 256; c++ {
+		keys = append(keys, prefix+string([]byte{byte(c)})+"x")
+	}
+	for r := rune(0); r <= utf8.MaxRune; r += 97 {
+		if utf8.ValidRune(r) {
+			keys = append(keys, prefix+string(r))
+		}
+	}
+
+	shards := listShards(prefix, false)
+	for _, key := range keys {
+		var n int
+		for _, shard := range shards {
+			if shard.covers(prefix, key) {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Errorf("key %q is covered by %d shards, want 1", key, n)
+		}
+	}
+
+	// Keys outside the prefix belong to no shard
+	for _, shard := range shards {
+		if shard.covers(prefix, "other/a") {
+			t.Errorf("shard %+v covers a key outside the prefix", shard)
+		}
+	}
+}
+
+func TestListShardsASCIIKeys(t *testing.T) {
+	shards := listShards("", true)
+	if len(shards) != '~'-' '+1 {
+		t.Errorf("got %d shards, want one per printable ASCII character", len(shards))
+	}
+	for _, shard := range shards {
+		if shard.catchAll {
+			t.Error("got the catch-all shard with --ascii-keys")
+		}
+	}
+	for _, key := range []string{"a", "~x", " "} {
+		var covered bool
+		for _, shard := range shards {
+			covered = covered || shard.covers("", key)
+		}
+		if !covered {
+			t.Errorf("key %q is not covered", key)
+		}
+	}
+}
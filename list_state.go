@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// listPageSize is the number of blobs fetched per page in resumable listings.
+const listPageSize = 1000
+
+// listState is persisted to --state-file so that an interrupted listing can
+// resume where it left off.
+type listState struct {
+	// Prefix is the prefix being listed. A state file recorded for one
+	// prefix cannot be used to resume the listing of another.
+	Prefix string `json:"prefix"`
+	// Marker is the service's continuation marker of the next page to fetch.
+	Marker string `json:"marker,omitempty"`
+	// Seen maps the keys listed by the last completed incremental listing
+	// to their ETags.
+	Seen map[string]string `json:"seen,omitempty"`
+	// Pending maps the keys listed so far by the incremental listing in
+	// progress to their ETags. It replaces Seen once the listing completes.
+	Pending map[string]string `json:"pending,omitempty"`
+}
+
+// readListState reads the list state from path. A missing file yields an
+// empty state.
+func readListState(path string) (*listState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &listState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state listState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("reading state file %q: %v", path, err)
+	}
+	return &state, nil
+}
+
+// writeListState atomically replaces the state file at path with state.
+func writeListState(path string, state *listState) error {
+	return writeStateFile(path, state)
+}
+
+// writeStateFile atomically replaces the state file at path with state
+// encoded as JSON, so that a crash never leaves a partial state behind.
+func writeStateFile(path string, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// listResumable lists all blobs in the container under prefix to out as a
+// flat namespace, one page at a time, recording the continuation marker in
+// the state file at path after each page. If the state file holds a marker
+// for prefix, the listing resumes from it. The state file is removed once
+// the listing completes. It returns a summary of the blobs listed by this run.
+//
+// If incremental is true, only keys that are new or whose ETag changed since
+// the last completed listing are printed, and the state file is kept after
+// completion to serve as the baseline of the next run.
+//
+// The service's own marker is persisted, rather than a blob.ListPage token,
+// since page tokens are only meaningful within the process that issued them.
+func listResumable(ctx context.Context, out, errOut io.Writer, prefix, path string, incremental bool) (*listStats, error) {
+	var stats listStats
+
+	state, err := readListState(path)
+	if err != nil {
+		return &stats, err
+	}
+
+	if (state.Marker != "" || state.Seen != nil) && state.Prefix != prefix {
+		return &stats, fmt.Errorf("state file %q was recorded for prefix %q, not %q", path, state.Prefix, prefix)
+	}
+	state.Prefix = prefix
+
+	marker := azblob.Marker{}
+	if state.Marker != "" {
+		fmt.Fprintf(errOut, "Resuming listing of %q from %q\n", prefix, path)
+		marker.Val = &state.Marker
+	} else {
+		state.Pending = nil
+	}
+	if incremental && state.Pending == nil {
+		state.Pending = make(map[string]string)
+	}
+
+	unchanged := 0
+
+	containerURL := newContainerURL(containerName)
+	for marker.NotDone() {
+		resp, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix:     prefix,
+			MaxResults: listPageSize,
+		})
+		if err != nil {
+			return &stats, err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			if incremental {
+				etag := string(item.Properties.Etag)
+				state.Pending[item.Name] = etag
+				if state.Seen[item.Name] == etag {
+					unchanged++
+					continue
+				}
+			}
+			fmt.Fprintln(out, item.Name)
+			stats.addBlob(*item.Properties.ContentLength)
+		}
+
+		marker = resp.NextMarker
+		if !marker.NotDone() {
+			break
+		}
+
+		state.Marker = *marker.Val
+		if err := writeListState(path, state); err != nil {
+			return &stats, err
+		}
+	}
+
+	if incremental {
+		fmt.Fprintf(errOut, "Skipped %d unchanged blobs\n", unchanged)
+
+		state.Marker = ""
+		state.Seen = state.Pending
+		state.Pending = nil
+		return &stats, writeListState(path, state)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return &stats, err
+	}
+	return &stats, nil
+}
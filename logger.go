@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// Flags
+	logLevel  string
+	logFormat string
+	quiet     bool
+
+	// logger reports the outcome of commands, warnings and errors to
+	// stderr, so that stdout only carries the output of commands, such as
+	// the content printed by read. It is configured by initLogger.
+	logger = slog.New(newCLIHandler(os.Stderr, slog.LevelInfo))
+)
+
+// initLogger configures logger with --log-level and --log-format, writing
+// to errOut, the error output of the command. With --verbose, the level is debug, so
+// that HTTP requests are logged. With --quiet, it is at least warn, so that
+// successes aren't logged.
+func initLogger(errOut io.Writer) error {
+	if quiet && verbose {
+		return fmt.Errorf(`flag "--quiet" cannot be combined with "--verbose"`)
+	}
+
+	var level slog.Level
+	switch logLevel {
+	case "debug":
+		level = slog.LevelDebug
+	case "info":
+		level = slog.LevelInfo
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return fmt.Errorf(`flag "--log-level" should be one of "debug", "info", "warn" or "error"`)
+	}
+	if verbose {
+		level = slog.LevelDebug
+	}
+	if quiet && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+
+	switch logFormat {
+	case "text":
+		logger = slog.New(newCLIHandler(errOut, level))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(errOut, &slog.HandlerOptions{Level: level}))
+	default:
+		return fmt.Errorf(`flag "--log-format" should be one of "text" or "json"`)
+	}
+	return nil
+}
+
+// cliHandler is the slog.Handler of --log-format text. It writes the
+// message of every record followed by its attributes as key=value, one
+// record per line, colorized by level on interactive terminals: successes
+// logged at info in green, warnings in yellow and errors in red.
+type cliHandler struct {
+	mu    *sync.Mutex
+	out   io.Writer
+	level slog.Leveler
+	attrs string
+}
+
+// newCLIHandler returns a cliHandler writing records of at least level to
+// out.
+func newCLIHandler(out io.Writer, level slog.Leveler) *cliHandler {
+	return &cliHandler{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+func (h *cliHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *cliHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		b.WriteString(" " + a.String())
+		return true
+	})
+
+	line := b.String()
+	switch {
+	case r.Level >= slog.LevelError:
+		line = colorize(h.out, colorRed, line)
+	case r.Level >= slog.LevelWarn:
+		line = colorize(h.out, colorYellow, line)
+	case r.Level >= slog.LevelInfo:
+		line = colorize(h.out, colorGreen, line)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.out, line)
+	return err
+}
+
+func (h *cliHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	for _, a := range attrs {
+		h2.attrs += " " + a.String()
+	}
+	return &h2
+}
+
+func (h *cliHandler) WithGroup(name string) slog.Handler {
+	// Groups are not used, so attributes are kept flat
+	return h
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInitLoggerWritesToErrOutput(t *testing.T) {
+	var errOut bytes.Buffer
+	rootCmd.SetErr(&errOut)
+	t.Cleanup(func() {
+		rootCmd.SetErr(nil)
+		initLogger(rootCmd.ErrOrStderr())
+	})
+
+	for _, format := range []string{"text", "json"} {
+		errOut.Reset()
+		logFormat = format
+		if err := initLogger(rootCmd.ErrOrStderr()); err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("Successfully tested")
+		if !bytes.Contains(errOut.Bytes(), []byte("Successfully tested")) {
+			t.Errorf("--log-format %s: got %q on the error output", format, errOut.String())
+		}
+	}
+	logFormat = "text"
+}
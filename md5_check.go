@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"gocloud.dev/blob"
+)
+
+// noVerify is set with --no-verify to skip checking downloads against the
+// stored Content-MD5.
+var noVerify bool
+
+// md5Reader computes the MD5 of the content read through it and fails the
+// read that reaches the end if it doesn't match the stored MD5.
+type md5Reader struct {
+	r    io.Reader
+	h    hash.Hash
+	want []byte
+}
+
+// verifyMD5 returns a reader of the whole blob r that fails at the end of
+// the content if its MD5 doesn't match the Content-MD5 stored with the
+// blob. r is returned as-is with --no-verify or if no MD5 is stored.
+func verifyMD5(r *blob.Reader) io.Reader {
+	if noVerify {
+		return r
+	}
+	want := storedMD5(r)
+	if len(want) == 0 {
+		return r
+	}
+	return &md5Reader{r: r, h: md5.New(), want: want}
+}
+
+func (m *md5Reader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.h.Write(p[:n])
+	if err == io.EOF {
+		if sum := m.h.Sum(nil); !bytes.Equal(sum, m.want) {
+			return n, fmt.Errorf("content is corrupted: stored MD5 %x, computed %x (use \"--no-verify\" to skip the check)", m.want, sum)
+		}
+	}
+	return n, err
+}
+
+// storedMD5 returns the Content-MD5 stored with the blob r reads, or nil
+// if there is none.
+func storedMD5(r *blob.Reader) []byte {
+	var resp azblob.DownloadResponse
+	if !r.As(&resp) {
+		return nil
+	}
+	// Range reads return the stored MD5 in a separate header
+	if sum := resp.BlobContentMD5(); len(sum) > 0 {
+		return sum
+	}
+	return resp.ContentMD5()
+}
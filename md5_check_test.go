@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// corruptReader flips a bit of the byte at offset of what it reads.
+type corruptReader struct {
+	r      io.Reader
+	offset int64
+	read   int64
+}
+
+func (c *corruptReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if i := c.offset - c.read; i >= 0 && i < int64(n) {
+		p[i] ^= 1
+	}
+	c.read += int64(n)
+	return n, err
+}
+
+func TestMD5ReaderCorrupted(t *testing.T) {
+	content := bytes.Repeat([]byte("blob content "), 1000)
+	sum := md5.Sum(content)
+
+	r := &md5Reader{r: &corruptReader{r: bytes.NewReader(content), offset: 4321}, h: md5.New(), want: sum[:]}
+	if _, err := ioutil.ReadAll(r); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("got error %v reading corrupted content, want a corruption error", err)
+	}
+
+	r = &md5Reader{r: bytes.NewReader(content), h: md5.New(), want: sum[:]}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Errorf("got error %v reading intact content", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content changed through the reader")
+	}
+}
+
+func TestReadVerifiesMD5(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "k", "--blob-value", "intact"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	b := s.blob("test", "k")
+	if b == nil || b.header.Get("Content-MD5") != contentMD5([]byte("intact\n")) {
+		t.Fatalf("write stored no Content-MD5")
+	}
+	// Corrupt the content at rest
+	b.content[0] ^= 1
+
+	if _, _, err := executeFake(t, s, "read", "--blob-key", "k"); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("read of a corrupted blob: got error %v, want a corruption error", err)
+	}
+	if _, _, err := executeFake(t, s, "read", "--blob-key", "k", "--no-verify"); err != nil {
+		t.Errorf("read --no-verify of a corrupted blob: %v", err)
+	}
+}
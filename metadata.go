@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	metaPairs []string
+	metaFile  string
+
+	// Commands
+	setMetadataCmd = &cobra.Command{
+		Use:         "set-metadata",
+		Short:       "Set metadata of a blob",
+		Annotations: mutating,
+		Long: `Set metadata of a blob.
+
+Every --meta NAME=VALUE pair is set on --blob-key without re-uploading its
+content. Existing metadata with other names is kept. An empty value, as in
+--meta NAME=, removes the name. Names must be valid C# identifiers, as
+required by Azure, and are case-insensitive.
+
+With --meta-file, the pairs are loaded from a file holding a JSON object of
+string values, e.g. {"owner": "data-team"}, and merged with the --meta
+pairs, which take precedence.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if len(metaPairs) == 0 && metaFile == "" {
+				return fmt.Errorf(`flag "--meta" or "--meta-file" should be set`)
+			}
+			pairs, err := metadataOfFlags()
+			if err != nil {
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			blobURL := newBlobURL(containerName, blobKey)
+			props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return err
+			}
+
+			metadata := props.NewMetadata()
+			for name, value := range pairs {
+				name = strings.ToLower(name)
+				if value == "" {
+					delete(metadata, name)
+				} else {
+					metadata[name] = value
+				}
+			}
+
+			// Don't overwrite metadata set since it was read
+			_, err = blobURL.SetMetadata(ctx, metadata, azblob.BlobAccessConditions{
+				ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: props.ETag()},
+			}, azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully set metadata of %q", blobKey))
+			return nil
+		},
+	}
+
+	getMetadataCmd = &cobra.Command{
+		Use:   "get-metadata",
+		Short: "Print the metadata of a blob",
+		Long: `Print the metadata of a blob.
+
+The metadata of --blob-key is printed as NAME=VALUE lines sorted by name,
+without downloading its content.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			attrs, err := bucket.Attributes(ctx, blobKey)
+			if err != nil {
+				return err
+			}
+
+			printMetadata(out, attrs.Metadata)
+			return nil
+		},
+	}
+)
+
+// metaNamePattern matches valid metadata names, which must be C#
+// identifiers.
+var metaNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// printMetadata prints metadata to out as NAME=VALUE lines sorted by name.
+func printMetadata(out io.Writer, metadata map[string]string) {
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "%s=%s\n", name, metadata[name])
+	}
+}
+
+// parseMetaPairs parses NAME=VALUE pairs into a map.
+func parseMetaPairs(pairs []string) (map[string]string, error) {
+	metadata := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf(`flag "--meta" should be NAME=VALUE, got %q`, pair)
+		}
+		if !metaNamePattern.MatchString(pair[:i]) {
+			return nil, fmt.Errorf(`flag "--meta" should have a valid C# identifier as NAME, got %q`, pair[:i])
+		}
+		metadata[pair[:i]] = pair[i+1:]
+	}
+	return metadata, nil
+}
+
+// metadataOfFlags returns the metadata pairs loaded from --meta-file, if
+// set, merged with the --meta pairs, which take precedence over names that
+// differ only by case.
+func metadataOfFlags() (map[string]string, error) {
+	metadata, err := readPairsFile("--meta-file", metaFile)
+	if err != nil {
+		return nil, err
+	}
+	for name := range metadata {
+		if !metaNamePattern.MatchString(name) {
+			return nil, fmt.Errorf(`flag "--meta-file" should have valid C# identifiers as names, got %q`, name)
+		}
+	}
+
+	pairs, err := parseMetaPairs(metaPairs)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range pairs {
+		for fileName := range metadata {
+			if strings.EqualFold(fileName, name) {
+				delete(metadata, fileName)
+			}
+		}
+		metadata[name] = value
+	}
+	return metadata, nil
+}
+
+// readPairsFile returns the pairs of the JSON object of string values in
+// the file at path, or no pairs if path is empty. flag names the flag path
+// was given with in errors.
+func readPairsFile(flag, path string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	if path == "" {
+		return pairs, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &pairs); err != nil {
+		return nil, fmt.Errorf(`flag %q should be a file of a JSON object of string values: %v`, flag, err)
+	}
+	return pairs, nil
+}
+
+func init() {
+	setMetadataCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to set metadata of")
+	setMetadataCmd.PersistentFlags().StringArrayVar(&metaPairs, "meta", nil, "indicate a NAME=VALUE metadata pair to set (repeatable)")
+	setMetadataCmd.PersistentFlags().StringVar(&metaFile, "meta-file", "", "indicate a JSON file of metadata pairs to set, overridden by --meta")
+	getMetadataCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to print the metadata of")
+
+	rootCmd.AddCommand(setMetadataCmd)
+	rootCmd.AddCommand(getMetadataCmd)
+}
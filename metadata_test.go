@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePairsFile writes content to a file in a temporary directory and
+// returns its path.
+func writePairsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pairs.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWriteMetaAndTagsFiles(t *testing.T) {
+	s := newFakeService(t, "test")
+	metaPath := writePairsFile(t, `{"owner": "data-team", "Stage": "draft"}`)
+	tagsPath := writePairsFile(t, `{"project": "alpha", "status": "new"}`)
+
+	_, _, err := executeFake(t, s, "write", "--blob-key", "k", "--blob-value", "v",
+		"--meta-file", metaPath, "--meta", "stage=final",
+		"--tags-file", tagsPath, "--tag", "status=done")
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	b := s.blob("test", "k")
+	if b == nil {
+		t.Fatal("write wrote no blob")
+	}
+	// Inline pairs take precedence, over names that differ only by case too
+	for name, want := range map[string]string{"owner": "data-team", "stage": "final"} {
+		if got := b.header.Get("x-ms-meta-" + name); got != want {
+			t.Errorf("metadata %q is %q, want %q", name, got, want)
+		}
+	}
+
+	var tags url.Values
+	for _, r := range s.requests {
+		if h := r.Header.Get("x-ms-tags"); h != "" {
+			if tags, err = url.ParseQuery(h); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if tags.Get("project") != "alpha" || tags.Get("status") != "done" || len(tags) != 2 {
+		t.Errorf("write sent tags %v, want project=alpha and status=done", tags)
+	}
+}
+
+func TestPairsFileInvalid(t *testing.T) {
+	saved := metaFile
+	t.Cleanup(func() { metaFile = saved })
+
+	for _, content := range []string{`["owner"]`, `{"owner": 1}`, `{"not a name": "x"}`, `{`} {
+		metaFile = writePairsFile(t, content)
+		if _, err := metadataOfFlags(); err == nil || !strings.Contains(err.Error(), "--meta-file") {
+			t.Errorf("metadataOfFlags of %s: got error %v, want one about --meta-file", content, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+// copyPollInterval is how often the status of a server-side copy is checked.
+const copyPollInterval = time.Second
+
+var (
+	// Flags
+	deleteSnapshots bool
+
+	// Commands
+	normalizeKeysCmd = &cobra.Command{
+		Use:   "normalize-keys",
+		Short: "Rename blobs under a prefix to lowercase keys",
+		Long: `Rename blobs under a prefix to lowercase keys.
+
+Every blob under --blob-prefix whose key has uppercase characters is copied
+server-side to the lowercased key and then deleted, --concurrency blobs at
+a time. The original is only deleted once the copy succeeded and if it was
+not modified in the meantime.
+
+Keys whose lowercased key is already taken, or shared with another key
+that differs only by case, are reported as collisions and left alone.
+
+Snapshots are not copied, so deleting the original would delete its
+snapshots as well. Blobs with snapshots are therefore skipped unless
+--delete-snapshots is set. Use --dry-run to only print the renames.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if !dryRun {
+				if err := checkWritable("rename blobs"); err != nil {
+					return err
+				}
+			}
+
+			items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{Snapshots: true})
+			if err != nil {
+				return err
+			}
+			targets, srcs, skipped := planRenames(out, items, deleteSnapshots)
+
+			var (
+				mu      sync.Mutex
+				renamed int
+			)
+			errs := runPool(len(targets), concurrency, func(i int) error {
+				src := srcs[targets[i]]
+				if !dryRun {
+					if err := renameBlob(ctx, src, targets[i], deleteSnapshots); err != nil {
+						return err
+					}
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				renamed++
+				if dryRun {
+					fmt.Fprintf(out, "would rename %s -> %s\n", src.Name, targets[i])
+				} else {
+					fmt.Fprintf(out, "RENAMED %s -> %s\n", src.Name, targets[i])
+				}
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", srcs[targets[e.Index]].Name, e.Err))
+			}
+
+			verb := "Renamed"
+			if dryRun {
+				verb = "Would rename"
+			}
+			logger.Info(fmt.Sprintf("%s: %d, skipped: %d, failed: %d", verb, renamed, skipped, len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if !dryRun {
+				logger.Info(fmt.Sprintf("Successfully normalized keys under %q", blobPrefix))
+			}
+			return nil
+		},
+	}
+)
+
+// planRenames returns the sorted lowercased keys to rename the blobs of
+// items to, along with the blob to rename to each. Keys that collide, and
+// blobs with snapshots unless withSnapshots is set, are reported to out
+// and counted in skipped. items may include snapshots, as listed with
+// their details.
+func planRenames(out io.Writer, items []azblob.BlobItemInternal, withSnapshots bool) (targets []string, srcs map[string]azblob.BlobItemInternal, skipped int) {
+	// Group the keys to rename by their lowercased key
+	existing := make(map[string]bool)
+	snapshots := make(map[string]int)
+	renames := make(map[string][]azblob.BlobItemInternal)
+	for _, item := range items {
+		existing[item.Name] = true
+		if item.Snapshot != "" {
+			snapshots[item.Name]++
+			continue
+		}
+		if lower := strings.ToLower(item.Name); lower != item.Name {
+			renames[lower] = append(renames[lower], item)
+		}
+	}
+
+	srcs = make(map[string]azblob.BlobItemInternal)
+	for lower, group := range renames {
+		if existing[lower] || len(group) > 1 {
+			for _, src := range group {
+				fmt.Fprintf(out, "SKIPPED %s: %q collides with another key\n", src.Name, lower)
+			}
+			skipped += len(group)
+			continue
+		}
+		src := group[0]
+		if n := snapshots[src.Name]; n > 0 && !withSnapshots {
+			fmt.Fprintf(out, "SKIPPED %s: has %d snapshots, which renaming would delete (use --delete-snapshots)\n", src.Name, n)
+			skipped++
+			continue
+		}
+		targets = append(targets, lower)
+		srcs[lower] = src
+	}
+	sort.Strings(targets)
+	return targets, srcs, skipped
+}
+
+// renameBlob copies src to the key dst server-side, waits for the copy to
+// complete and deletes src, along with its snapshots if withSnapshots is
+// set. dst must not exist, and src is only copied and deleted if it was
+// not modified since it was listed. The service reads src through a URL
+// signed as by copySourceURL, since private blobs can't be read otherwise.
+func renameBlob(ctx context.Context, src azblob.BlobItemInternal, dst string, withSnapshots bool) error {
+	srcURL := newBlobURL(containerName, src.Name)
+	dstURL := newBlobURL(containerName, dst)
+
+	source, err := copySourceURL(containerName, src.Name)
+	if err != nil {
+		return err
+	}
+	resp, err := dstURL.StartCopyFromURL(ctx, source, azblob.Metadata{},
+		azblob.ModifiedAccessConditions{IfMatch: src.Properties.Etag},
+		azblob.BlobAccessConditions{
+			ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny},
+		}, azblob.AccessTierNone, nil)
+	if err != nil {
+		return err
+	}
+	if err := waitForCopy(ctx, dstURL, resp.CopyID(), resp.CopyStatus()); err != nil {
+		return err
+	}
+
+	// Without withSnapshots, the delete fails rather than delete snapshots
+	// taken since the blob was listed
+	snapshots := azblob.DeleteSnapshotsOptionNone
+	if withSnapshots {
+		snapshots = azblob.DeleteSnapshotsOptionInclude
+	}
+	_, err = srcURL.Delete(ctx, snapshots, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: src.Properties.Etag},
+	})
+	return err
+}
+
+// waitForCopy polls blobURL until the copy copyID, whose last known status
+// is status, is no longer pending. It returns an error unless the copy
+// succeeded.
+func waitForCopy(ctx context.Context, blobURL azblob.BlobURL, copyID string, status azblob.CopyStatusType) error {
+	for status == azblob.CopyStatusPending {
+		select {
+		case <-time.After(copyPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return err
+		}
+		if props.CopyID() != copyID {
+			return fmt.Errorf("copy %s was superseded by copy %s", copyID, props.CopyID())
+		}
+		status = props.CopyStatus()
+		if status != azblob.CopyStatusPending && status != azblob.CopyStatusSuccess {
+			return fmt.Errorf("copy %s is %s: %s", copyID, status, props.CopyStatusDescription())
+		}
+	}
+	if status != azblob.CopyStatusSuccess {
+		return fmt.Errorf("copy %s is %s", copyID, status)
+	}
+	return nil
+}
+
+func init() {
+	normalizeKeysCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to normalize the keys under")
+	normalizeKeysCmd.PersistentFlags().BoolVar(&deleteSnapshots, "delete-snapshots", false, "indicate to rename blobs with snapshots too, deleting their snapshots")
+
+	rootCmd.AddCommand(normalizeKeysCmd)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestPlanRenames(t *testing.T) {
+	items := []azblob.BlobItemInternal{
+		{Name: "A.txt"},
+		{Name: "lower.txt"},
+		{Name: "Dup"},
+		{Name: "DUP"},
+		{Name: "Taken"},
+		{Name: "taken"},
+		{Name: "Snap.txt"},
+		{Name: "Snap.txt", Snapshot: "2021-01-01T00:00:00.0000000Z"},
+		{Name: "Snap.txt", Snapshot: "2021-01-02T00:00:00.0000000Z"},
+	}
+
+	tests := []struct {
+		withSnapshots bool
+		wantTargets   []string
+		wantSkipped   int
+	}{
+		{withSnapshots: false, wantTargets: []string{"a.txt"}, wantSkipped: 4},
+		{withSnapshots: true, wantTargets: []string{"a.txt", "snap.txt"}, wantSkipped: 3},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		targets, srcs, skipped := planRenames(&out, items, tt.withSnapshots)
+		if !reflect.DeepEqual(targets, tt.wantTargets) {
+			t.Errorf("withSnapshots %v: got targets %q, want %q", tt.withSnapshots, targets, tt.wantTargets)
+		}
+		if skipped != tt.wantSkipped {
+			t.Errorf("withSnapshots %v: got %d skipped, want %d", tt.withSnapshots, skipped, tt.wantSkipped)
+		}
+		for _, target := range targets {
+			if src := srcs[target]; src.Snapshot != "" || strings.ToLower(src.Name) != target {
+				t.Errorf("withSnapshots %v: %q is renamed from %+v", tt.withSnapshots, target, src)
+			}
+		}
+
+		reported := strings.Contains(out.String(), "SKIPPED Snap.txt: has 2 snapshots")
+		if reported == tt.withSnapshots {
+			t.Errorf("withSnapshots %v: reported %q", tt.withSnapshots, out.String())
+		}
+	}
+}
+
+func TestNormalizeKeysSignsCopySource(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "Upper.txt", "--blob-value", "v"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, _, err := executeFake(t, s, "normalize-keys"); err != nil {
+		t.Fatalf("normalize-keys: %v", err)
+	}
+	if s.blob("test", "upper.txt") == nil || s.blob("test", "Upper.txt") != nil {
+		t.Fatal("normalize-keys didn't rename Upper.txt")
+	}
+
+	// The fake service is reached over http, so the SAS must allow it
+	var source string
+	for _, r := range s.requests {
+		if h := r.Header.Get("x-ms-copy-source"); h != "" {
+			source = h
+		}
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := u.Query(); q.Get("sig") == "" || q.Get("spr") != string(azblob.SASProtocolHTTPSandHTTP) {
+		t.Errorf("normalize-keys copied from %q, want a URL signed for https and http", source)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	pingCount int
+
+	// Commands
+	pingCmd = &cobra.Command{
+		Use:   "ping",
+		Short: "Measure the latency of lightweight requests to the account",
+		Long: `Measure the latency of lightweight requests to the account.
+
+--count requests are sent one after the other and their latency percentiles
+are printed. With --blob-key, the properties of that blob are fetched (a
+HEAD request); otherwise the account information is. Failed requests are
+reported and left out of the percentiles.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if pingCount < 1 {
+				return fmt.Errorf(`flag "--count" should be at least 1`)
+			}
+
+			ping := func() error {
+				_, err := azblob.NewServiceURL(serviceURL(), pline).GetAccountInfo(ctx)
+				return err
+			}
+			target := fmt.Sprintf("account %q", accountName)
+			if blobKey != "" {
+				blobURL := newBlobURL(containerName, blobKey)
+				ping = func() error {
+					_, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+					return err
+				}
+				target = fmt.Sprintf("%q", blobKey)
+			}
+
+			var latencies []time.Duration
+			for i := 0; i < pingCount; i++ {
+				start := time.Now()
+				err := ping()
+				d := time.Since(start)
+				if err != nil {
+					logger.Error(fmt.Sprintf("request %d: %v", i+1, err))
+					continue
+				}
+				latencies = append(latencies, d)
+			}
+
+			fmt.Fprintf(out, "Pinged %s: %d sent, %d failed\n", target, pingCount, pingCount-len(latencies))
+			if len(latencies) == 0 {
+				return &exitError{Code: 1}
+			}
+			printLatencies(out, latencies)
+			return nil
+		},
+	}
+)
+
+// printLatencies prints the minimum, median, 90th and 99th percentile and
+// maximum of latencies to out.
+func printLatencies(out io.Writer, latencies []time.Duration) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	// percentile returns the nearest-rank p-th percentile.
+	percentile := func(p int) time.Duration {
+		i := (p*len(latencies)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return latencies[i]
+	}
+	fmt.Fprintf(out, "min %v, p50 %v, p90 %v, p99 %v, max %v\n",
+		latencies[0].Round(time.Millisecond/10),
+		percentile(50).Round(time.Millisecond/10),
+		percentile(90).Round(time.Millisecond/10),
+		percentile(99).Round(time.Millisecond/10),
+		latencies[len(latencies)-1].Round(time.Millisecond/10))
+}
+
+func init() {
+	pingCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to fetch the properties of (the account information if empty)")
+	pingCmd.PersistentFlags().IntVar(&pingCount, "count", 10, "indicate a number of requests to send")
+
+	rootCmd.AddCommand(pingCmd)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// concurrency is the number of workers batch commands run with, set by the
+// persistent --concurrency flag.
+var concurrency int
+
+// checkConcurrency returns an error if --concurrency is not positive.
+func checkConcurrency() error {
+	if concurrency < 1 {
+		return fmt.Errorf(`flag "--concurrency" should be at least 1`)
+	}
+	return nil
+}
+
+// itemError is the error returned by a runPool call for the i-th item.
+type itemError struct {
+	Index int
+	Err   error
+}
+
+// runPool calls fn for every index in [0, n) on at most workers goroutines
+// at a time and waits for all calls to return. The errors returned by fn are
+// collected and returned ordered by index, so that a failing item doesn't
+// stop the others from being processed. Items are not retried here, since
+// their requests already are by retryPipeline. Once ctx is done, e.g. on an
+// interrupt, or the requests retried more than --max-total-retries times,
+// no more items are started and each item left fails with the error of ctx
+// or errRetryBudget, while the calls in flight are left to fail.
+func runPool(n, workers int, fn func(i int) error) []itemError {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []itemError
+	)
+	fail := func(i int, err error) {
+		mu.Lock()
+		errs = append(errs, itemError{Index: i, Err: err})
+		mu.Unlock()
+	}
+
+	work := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				// select may dispatch an item even though ctx is done
+				if err := poolStopped(); err != nil {
+					fail(i, err)
+					continue
+				}
+				if err := fn(i); err != nil {
+					fail(i, err)
+				}
+			}
+		}()
+	}
+	i := 0
+dispatch:
+	for ; i < n && poolStopped() == nil; i++ {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+	for ; i < n; i++ {
+		errs = append(errs, itemError{Index: i, Err: poolStopped()})
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Index < errs[j].Index })
+	return errs
+}
+
+// poolStopped returns why runPool starts no more items, if it doesn't: the
+// error of ctx, or errRetryBudget.
+func poolStopped() error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if retryBudgetExceeded() {
+		return errRetryBudget
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunPoolErrorsOrderedByIndex(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = make(map[int]int)
+	)
+	errs := runPool(20, 4, func(i int) error {
+		mu.Lock()
+		calls[i]++
+		mu.Unlock()
+		if i%3 == 0 {
+			return fmt.Errorf("item %d failed", i)
+		}
+		return nil
+	})
+
+	for i := 0; i < 20; i++ {
+		if calls[i] != 1 {
+			t.Errorf("item %d was called %d times, want 1", i, calls[i])
+		}
+	}
+	var want []int
+	for i := 0; i < 20; i += 3 {
+		want = append(want, i)
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d", len(errs), len(want))
+	}
+	for n, e := range errs {
+		if e.Index != want[n] {
+			t.Errorf("error %d is for item %d, want %d", n, e.Index, want[n])
+		}
+		if e.Err == nil || e.Err.Error() != fmt.Sprintf("item %d failed", e.Index) {
+			t.Errorf("error for item %d is %v", e.Index, e.Err)
+		}
+	}
+}
+
+func TestRunPoolDoesNotRetry(t *testing.T) {
+	setRetryPolicy(t, 2)
+
+	// Requests are retried by the pipeline, so items fail on the first error
+	failed := errors.New("failed")
+	for _, err := range []error{failed, statusError(http.StatusServiceUnavailable)} {
+		var calls int32
+		errs := runPool(1, 1, func(i int) error {
+			atomic.AddInt32(&calls, 1)
+			return err
+		})
+		if calls != 1 {
+			t.Errorf("error %q: got %d calls, want 1", err, calls)
+		}
+		if len(errs) != 1 || errs[0].Err != err {
+			t.Errorf("error %q: got errors %v", err, errs)
+		}
+	}
+}
+
+func TestRunPoolStopsOnCancel(t *testing.T) {
+	saved := ctx
+	t.Cleanup(func() { ctx = saved })
+
+	for _, workers := range []int{1, 4, 8} {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(context.Background())
+
+		// The first items hold every worker on gate until all of them
+		// started and the last of them canceled, so that no other item
+		// can start in between
+		var (
+			mu          sync.Mutex
+			called      = make(map[int]bool)
+			afterCancel int
+			started     sync.WaitGroup
+			gate        = make(chan struct{})
+		)
+		started.Add(workers)
+		errs := runPool(20, workers, func(i int) error {
+			mu.Lock()
+			if ctx.Err() != nil {
+				afterCancel++
+			}
+			called[i] = true
+			mu.Unlock()
+			if i < workers {
+				started.Done()
+			}
+
+			if i == workers-1 {
+				started.Wait()
+				cancel()
+				close(gate)
+			}
+			<-gate
+			return nil
+		})
+		cancel()
+
+		if afterCancel > 0 {
+			t.Errorf("%d workers: %d items started after the cancel", workers, afterCancel)
+		}
+		if len(called) != workers {
+			t.Errorf("%d workers: %d items called, want %d", workers, len(called), workers)
+		}
+		if len(errs) != 20-workers {
+			t.Fatalf("%d workers: %d items failed, want %d", workers, len(errs), 20-workers)
+		}
+		for n, e := range errs {
+			if e.Index != workers+n || !errors.Is(e.Err, context.Canceled) {
+				t.Errorf("%d workers: error %d is %v for item %d, want %v for item %d", workers, n, e.Err, e.Index, context.Canceled, workers+n)
+			}
+		}
+	}
+}
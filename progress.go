@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval is how often the progress of a transfer is printed.
+const progressInterval = 500 * time.Millisecond
+
+// showProgress is set with --progress to print the progress of transfers.
+var showProgress bool
+
+// progress is an io.Writer counting the bytes of a transfer written to it
+// and printing their number, every progressInterval and when done. On a
+// terminal, the progress is updated in place.
+type progress struct {
+	out   io.Writer
+	total int64 // -1 if unknown
+	n     int64
+	last  time.Time
+	tty   bool
+}
+
+// newProgress returns a progress of a transfer of total bytes, or of an
+// unknown number of bytes if total is negative, printed to out.
+func newProgress(out io.Writer, total int64) *progress {
+	f, ok := out.(*os.File)
+	return &progress{
+		out:   out,
+		total: total,
+		last:  time.Now(),
+		tty:   ok && isTerminal(f),
+	}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.n += int64(len(b))
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.print()
+	}
+	return len(b), nil
+}
+
+// done prints the final progress.
+func (p *progress) done() {
+	p.print()
+	if p.tty {
+		fmt.Fprintln(p.out)
+	}
+}
+
+func (p *progress) print() {
+	line := formatBytes(p.n)
+	if p.total >= 0 {
+		percent := int64(100)
+		if p.total > 0 {
+			percent = p.n * 100 / p.total
+		}
+		line = fmt.Sprintf("%s / %s (%d%%)", line, formatBytes(p.total), percent)
+	}
+	if p.tty {
+		// Pad to clear what's left of a longer previous line
+		fmt.Fprintf(p.out, "\r%-40s", line)
+		return
+	}
+	fmt.Fprintln(p.out, line)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commands
+	purgeContainerCmd = &cobra.Command{
+		Use:   "purge-container",
+		Short: "Delete all blobs of a container but keep the container",
+		Long: `Delete all blobs of a container but keep the container.
+
+Every blob of --container-name is deleted along with its snapshots, as by
+delete-prefix without a prefix, but unlike delete-container the container
+itself and its metadata, access level and policies are left in place. An
+empty container is left as is.
+
+Soft-deleted blobs are left to expire unless --include-deleted is set, in
+which case they are deleted permanently, which needs permanent delete to
+be allowed by the soft delete policy of the account.
+
+Since this deletes everything in the container, the deletion must be
+confirmed, either interactively or with --yes. Use --dry-run to only print
+what would be deleted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			if !dryRun {
+				if err := checkWritable("delete blobs"); err != nil {
+					return err
+				}
+			}
+
+			items, err := listBlobItems(ctx, containerName, "", azblob.BlobListingDetails{Deleted: includeDeleted})
+			if err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				logger.Info(fmt.Sprintf("Container %q is already empty", containerName))
+				return nil
+			}
+
+			if !dryRun && !assumeYes {
+				ok, err := confirm(cmd.InOrStdin(), errOut, fmt.Sprintf("Delete all %d blobs of container %q?", len(items), containerName))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("deletion not confirmed")
+				}
+			}
+
+			deleted, size, errs := deleteBlobItems(out, errOut, items)
+
+			verb := "Deleted"
+			if dryRun {
+				verb = "Would delete"
+			}
+			logger.Info(fmt.Sprintf("%s: %d (%s), failed: %d", verb, deleted, formatBytes(size), len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if !dryRun {
+				logger.Info(fmt.Sprintf("Successfully purged container %q of %d blobs", containerName, deleted))
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	purgeContainerCmd.PersistentFlags().BoolVar(&assumeYes, "yes", false, "delete without asking for confirmation")
+	purgeContainerCmd.PersistentFlags().BoolVar(&includeDeleted, "include-deleted", false, "also permanently delete soft-deleted blobs")
+
+	rootCmd.AddCommand(purgeContainerCmd)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	sourceURL string
+
+	// Commands
+	putFromURLCmd = &cobra.Command{
+		Use:         "put-from-url",
+		Short:       "Copy a small external object into a blob in one call",
+		Annotations: mutating,
+		Long: `Copy a small external object into a blob in one call.
+
+The object at --source-url is copied by the service into the block blob
+--blob-key with Put Blob From URL, which completes synchronously and is
+faster than an asynchronous copy for small objects. The source must be
+readable by the service, e.g. public or a signed URL.
+
+Put Blob From URL only supports sources up to 256 MiB. The size of the
+source is checked first with a HEAD request, and larger sources, or sources
+whose size is unknown, are copied asynchronously with Copy Blob instead.
+The copy then continues on the service after the command returns.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if sourceURL == "" {
+				return fmt.Errorf(`flag "--source-url" should be set`)
+			}
+			src, err := url.Parse(sourceURL)
+			if err != nil || (src.Scheme != "https" && src.Scheme != "http") {
+				return fmt.Errorf(`flag "--source-url" should be an http(s) URL`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			blobURL := newBlobURL(containerName, blobKey)
+
+			size, err := sourceSize(src)
+			if err != nil || size < 0 || size > azblob.BlockBlobMaxUploadBlobBytes {
+				if err != nil {
+					fmt.Fprintf(errOut, "Cannot determine the size of the source (%v), copying asynchronously\n", err)
+				} else if size < 0 {
+					fmt.Fprintln(errOut, "The source doesn't report its size, copying asynchronously")
+				} else {
+					fmt.Fprintf(errOut, "The source is %s, over the %s limit of a synchronous copy, copying asynchronously\n",
+						formatBytes(size), formatBytes(azblob.BlockBlobMaxUploadBlobBytes))
+				}
+
+				resp, err := blobURL.StartCopyFromURL(ctx, *src, azblob.Metadata{}, azblob.ModifiedAccessConditions{},
+					azblob.BlobAccessConditions{}, azblob.AccessTierNone, nil)
+				if err != nil {
+					return fmt.Errorf("starting copy of %q: %v", sourceURL, err)
+				}
+
+				fmt.Fprintf(out, "Copy %s is %s\n", resp.CopyID(), resp.CopyStatus())
+				logger.Info(fmt.Sprintf("Successfully started copying to %q", blobKey))
+				return nil
+			}
+
+			_, err = blobURL.ToBlockBlobURL().PutBlobFromURL(ctx, azblob.BlobHTTPHeaders{}, *src, azblob.Metadata{},
+				azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, nil, nil, azblob.AccessTierNone, nil,
+				azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return fmt.Errorf("copying %q: %v", sourceURL, err)
+			}
+
+			logger.Info(fmt.Sprintf("Successfully copied %s to %q", formatBytes(size), blobKey))
+			return nil
+		},
+	}
+)
+
+// sourceSize returns the size reported by a HEAD request to src, or -1 if
+// it doesn't report one.
+func sourceSize(src *url.URL) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, src.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HEAD returned %s", resp.Status)
+	}
+	return resp.ContentLength, nil
+}
+
+func init() {
+	putFromURLCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to copy to")
+	putFromURLCmd.PersistentFlags().StringVar(&sourceURL, "source-url", "", "indicate a URL of the object to copy")
+
+	rootCmd.AddCommand(putFromURLCmd)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+var (
+	// Flags
+	readDefault string
+)
+
+// isBlobNotFound reports whether err is the service's error for a missing
+// blob. Other errors, such as a missing container or a failed
+// authorization, are not.
+func isBlobNotFound(err error) bool {
+	var serr azblob.StorageError
+	return errors.As(err, &serr) && serr.ServiceCode() == azblob.ServiceCodeBlobNotFound
+}
+
+// writeReadDefault prints --default to out in place of a missing blob,
+// followed by a newline like the blobs written by write. It is printed as
+// exports with --as-env and filtered with --grep, --head or --tail, like a
+// blob would be.
+func writeReadDefault(out io.Writer, re *regexp.Regexp) error {
+	content := strings.NewReader(readDefault + "\n")
+	switch {
+	case asEnv:
+		return writeEnvExports(out, content)
+	case hasLineFilters():
+		return filterLines(out, content, re, headLines, tailLines)
+	}
+	_, err := io.Copy(out, content)
+	return err
+}
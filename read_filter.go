@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+)
+
+var (
+	// Flags
+	grepPattern string
+	headLines   int
+	tailLines   int
+)
+
+// hasLineFilters reports whether any of the line filters of read are set.
+func hasLineFilters() bool {
+	return grepPattern != "" || headLines > 0 || tailLines > 0
+}
+
+// filterLines copies the lines of r matching re, or all lines if re is nil,
+// to out. With head > 0, reading stops after head matching lines, so the
+// rest of the blob is never downloaded. With tail > 0, only the last tail of
+// those lines are printed.
+func filterLines(out io.Writer, r io.Reader, re *regexp.Regexp, head, tail int) error {
+	br := bufio.NewReader(r)
+	w := bufio.NewWriter(out)
+
+	// ring holds the last tail matching lines, starting at next once full
+	var (
+		ring []string
+		next int
+	)
+	for n := 0; head <= 0 || n < head; {
+		line, err := br.ReadString('\n')
+		if line != "" && (re == nil || re.MatchString(line)) {
+			n++
+			if line[len(line)-1] != '\n' {
+				line += "\n"
+			}
+			switch {
+			case tail <= 0:
+				if _, err := w.WriteString(line); err != nil {
+					return err
+				}
+			case len(ring) < tail:
+				ring = append(ring, line)
+			default:
+				ring[next] = line
+				next = (next + 1) % tail
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range ring {
+		if _, err := w.WriteString(ring[(next+i)%len(ring)]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/noprysk-ua/azure/blobstore"
+)
+
+var (
+	// Flags
+	readRange string
+)
+
+// rangeUnits are the multipliers of the units a --range bound can have.
+// Units are binary, so "1KB" is 1024 bytes.
+var rangeUnits = []struct {
+	suffix string
+	n      int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// readRangeOf returns the offset and length to read of key, or of its
+// snapshot taken at snapshot, from --range if set, computed against the
+// size of the blob, or else from --offset and --length.
+func readRangeOf(ctx context.Context, client *blobstore.Client, key, snapshot string) (offset, length int64, err error) {
+	if readRange == "" {
+		return readOffset, readLength, nil
+	}
+	// A reader of no bytes has the size of the blob
+	r, err := client.NewSnapshotRangeReader(ctx, key, snapshot, 0, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	r.Close()
+	return parseReadRange(readRange, r.Size())
+}
+
+// parseReadRange returns the offset and length of the byte range spec of a
+// blob of size bytes, with a length of -1 for a range to the end of the
+// blob. spec is "start-end", with end inclusive, "start-" to read from
+// start to the end, or "-n" to read the last n bytes, where each bound is a
+// number of bytes, optionally in KB, MB or GB, or a percentage of size,
+// e.g. "-1KB" or "50%-". Ranges past the end of the blob are cut at its
+// end, but ranges starting past it are an error.
+func parseReadRange(spec string, size int64) (offset, length int64, err error) {
+	i := strings.Index(spec, "-")
+	if i < 0 || (i == 0 && len(spec) == 1) {
+		return 0, 0, fmt.Errorf("range %q should be \"start-end\", \"start-\" or \"-n\"", spec)
+	}
+	startSpec, endSpec := spec[:i], spec[i+1:]
+
+	if startSpec == "" {
+		// The last n bytes
+		n, err := parseRangeBound(endSpec, size)
+		if err != nil {
+			return 0, 0, fmt.Errorf("range %q: %v", spec, err)
+		}
+		if n == 0 {
+			return 0, 0, fmt.Errorf("range %q is empty", spec)
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, nil
+	}
+
+	start, err := parseRangeBound(startSpec, size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("range %q: %v", spec, err)
+	}
+	if start >= size {
+		return 0, 0, fmt.Errorf("range %q starts past the end of the blob of %d bytes", spec, size)
+	}
+	if endSpec == "" {
+		return start, -1, nil
+	}
+	end, err := parseRangeBound(endSpec, size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("range %q: %v", spec, err)
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range %q ends before it starts", spec)
+	}
+	if end >= size {
+		return start, -1, nil
+	}
+	return start, end - start + 1, nil
+}
+
+// parseRangeBound returns the number of bytes of a --range bound, such as
+// "100", "1KB" or "50%" of size.
+func parseRangeBound(bound string, size int64) (int64, error) {
+	if p := strings.TrimSuffix(bound, "%"); p != bound {
+		percent, err := strconv.ParseFloat(p, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, fmt.Errorf("%q should be a percentage between 0%% and 100%%", bound)
+		}
+		return int64(float64(size) * percent / 100), nil
+	}
+
+	n, unit := strings.ToUpper(bound), int64(1)
+	for _, u := range rangeUnits {
+		if strings.HasSuffix(n, u.suffix) {
+			n, unit = strings.TrimSuffix(n, u.suffix), u.n
+			break
+		}
+	}
+	v, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%q should be a number of bytes, optionally in KB, MB or GB", bound)
+	}
+	return v * unit, nil
+}
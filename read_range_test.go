@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReadRange(t *testing.T) {
+	tests := []struct {
+		spec       string
+		size       int64
+		wantOffset int64
+		wantLength int64
+	}{
+		{spec: "-1KB", size: 4096, wantOffset: 3072, wantLength: 1024},
+		{spec: "-1kb", size: 4096, wantOffset: 3072, wantLength: 1024},
+		{spec: "-100", size: 4096, wantOffset: 3996, wantLength: 100},
+		// The last n bytes of a smaller blob are the whole blob
+		{spec: "-1MB", size: 10, wantOffset: 0, wantLength: 10},
+		{spec: "50%-", size: 1001, wantOffset: 500, wantLength: -1},
+		{spec: "-25%", size: 400, wantOffset: 300, wantLength: 100},
+		{spec: "10-19", size: 100, wantOffset: 10, wantLength: 10},
+		{spec: "1KB-", size: 4096, wantOffset: 1024, wantLength: -1},
+		{spec: "0%-50%", size: 100, wantOffset: 0, wantLength: 51},
+		// Ends past the end of the blob read to its end
+		{spec: "10-1GB", size: 100, wantOffset: 10, wantLength: -1},
+	}
+	for _, tt := range tests {
+		offset, length, err := parseReadRange(tt.spec, tt.size)
+		if err != nil {
+			t.Errorf("parseReadRange(%q, %d): %v", tt.spec, tt.size, err)
+			continue
+		}
+		if offset != tt.wantOffset || length != tt.wantLength {
+			t.Errorf("parseReadRange(%q, %d) = %d, %d, want %d, %d", tt.spec, tt.size, offset, length, tt.wantOffset, tt.wantLength)
+		}
+	}
+}
+
+func TestParseReadRangeErrors(t *testing.T) {
+	tests := []struct {
+		spec    string
+		size    int64
+		wantErr string
+	}{
+		{spec: "", wantErr: "should be"},
+		{spec: "-", wantErr: "should be"},
+		{spec: "100", size: 1000, wantErr: "should be"},
+		{spec: "-0", size: 1000, wantErr: "is empty"},
+		{spec: "-1XB", size: 1000, wantErr: "number of bytes"},
+		{spec: "150%-", size: 1000, wantErr: "percentage"},
+		{spec: "-x%", size: 1000, wantErr: "percentage"},
+		{spec: "20-10", size: 1000, wantErr: "ends before it starts"},
+		{spec: "1000-", size: 1000, wantErr: "starts past the end"},
+		{spec: "2KB-", size: 1000, wantErr: "starts past the end"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseReadRange(tt.spec, tt.size)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseReadRange(%q, %d): got error %v, want %q", tt.spec, tt.size, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "k", "--blob-value", "0123456789"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// The blob is "0123456789\n"
+	for spec, want := range map[string]string{"-3": "89\n", "50%-": "56789\n", "0-1": "01"} {
+		stdout, _, err := executeFake(t, s, "read", "--blob-key", "k", "--range", spec)
+		if err != nil {
+			t.Fatalf("read --range %s: %v", spec, err)
+		}
+		if stdout != want {
+			t.Errorf("read --range %s printed %q, want %q", spec, stdout, want)
+		}
+	}
+
+	if _, _, err := executeFake(t, s, "read", "--blob-key", "k", "--range", "-1", "--offset", "1"); err == nil {
+		t.Error("read with --range and --offset succeeded")
+	}
+}
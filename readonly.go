@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// mutatingAnnotation is set in the Annotations of commands that modify the
+// storage account. Such commands are refused in read-only mode.
+const mutatingAnnotation = "mutating"
+
+// mutating is used as the Annotations of commands that modify the storage
+// account.
+var mutating = map[string]string{mutatingAnnotation: "true"}
+
+// isReadOnly reports whether read-only mode is enabled, either with the
+// --read-only flag or with a true AZURE_READ_ONLY environment variable.
+func isReadOnly() bool {
+	if readOnly {
+		return true
+	}
+	v, err := strconv.ParseBool(os.Getenv("AZURE_READ_ONLY"))
+	return err == nil && v
+}
+
+// checkWritable returns an error if read-only mode is enabled. action
+// describes the refused operation.
+func checkWritable(action string) error {
+	if isReadOnly() {
+		return fmt.Errorf("refusing to %s in read-only mode (unset --read-only and AZURE_READ_ONLY to allow it)", action)
+	}
+	return nil
+}
+
+// checkCommandWritable refuses to run mutating commands in read-only mode.
+func checkCommandWritable(cmd *cobra.Command) error {
+	if _, ok := cmd.Annotations[mutatingAnnotation]; !ok {
+		return nil
+	}
+	return checkWritable(fmt.Sprintf("run %q", cmd.Name()))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	rehydratePriority string
+
+	// Commands
+	rehydrateCmd = &cobra.Command{
+		Use:         "rehydrate",
+		Short:       "Rehydrate an archived blob",
+		Annotations: mutating,
+		Long: `Rehydrate an archived blob.
+
+The archived block blob --blob-key is moved back to the access tier given
+by --tier (Hot or Cool), so that it can be read again. Rehydration runs on
+the service and can take up to 15 hours with --priority standard, or under
+an hour for small blobs with --priority high, which costs more. The blob
+stays archived until it completes; get its status with blob-properties.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			tier, ok := parseAccessTier(tierName)
+			if !ok || tier == azblob.AccessTierArchive {
+				return fmt.Errorf(`flag "--tier" should be one of "Hot" or "Cool"`)
+			}
+			var priority azblob.RehydratePriorityType
+			switch strings.ToLower(rehydratePriority) {
+			case "standard":
+				priority = azblob.RehydratePriorityStandard
+			case "high":
+				priority = azblob.RehydratePriorityHigh
+			default:
+				return fmt.Errorf(`flag "--priority" should be one of "standard" or "high"`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			blobURL := newBlobURL(containerName, blobKey)
+			props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return err
+			}
+			if status := props.ArchiveStatus(); status != "" {
+				return fmt.Errorf("blob %q is already being rehydrated (%s)", blobKey, status)
+			}
+			if props.AccessTier() != string(azblob.AccessTierArchive) {
+				return fmt.Errorf("blob %q is not archived but %s, use set-tier to change its tier", blobKey, props.AccessTier())
+			}
+
+			// BlobURL.SetTier doesn't take the priority, so it is added to the
+			// request by requestHeadersPipeline
+			pctx := withRequestHeaders(ctx, http.Header{"X-Ms-Rehydrate-Priority": {string(priority)}})
+			if _, err := blobURL.SetTier(pctx, tier, azblob.LeaseAccessConditions{}); err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully started rehydrating %q to %s with %s priority, which can take hours", blobKey, tier, priority))
+			return nil
+		},
+	}
+)
+
+func init() {
+	rehydrateCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to rehydrate")
+	rehydrateCmd.PersistentFlags().StringVar(&tierName, "tier", "", "indicate an access tier to rehydrate the blob to (Hot or Cool)")
+	rehydrateCmd.PersistentFlags().StringVar(&rehydratePriority, "priority", "standard", "indicate a rehydration priority (standard or high)")
+
+	rootCmd.AddCommand(rehydrateCmd)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	keepSource bool
+
+	// Commands
+	renameBlobCmd = &cobra.Command{
+		Use:         "rename-blob",
+		Aliases:     []string{"move-blob"},
+		Short:       "Rename a blob by copying it on the server and deleting the source",
+		Annotations: mutating,
+		Long: `Rename a blob by copying it on the server and deleting the source.
+
+--source-key is copied to --dest-key with Copy Blob, as by copy-blob, and
+the source is deleted once the copy succeeded. If the copy fails, or the
+source is modified while it is copied, the source is left intact. With
+--keep-source, the source is never deleted, which makes the command a plain
+copy.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if sourceKey == "" {
+				return fmt.Errorf(`flag "--source-key" should be set`)
+			}
+			if destKey == "" {
+				return fmt.Errorf(`flag "--dest-key" should be set`)
+			}
+			if destKey == sourceKey {
+				return fmt.Errorf(`flags "--source-key" and "--dest-key" should differ`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			srcURL := newBlobURL(containerName, sourceKey)
+			props, err := srcURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return fmt.Errorf("reading properties of %q: %v", sourceKey, err)
+			}
+
+			// Only copy and delete the source as it is now
+			unchanged := azblob.ModifiedAccessConditions{IfMatch: props.ETag()}
+			if err := copyBlob(out, containerName, sourceKey, newBlobURL(containerName, destKey), unchanged); err != nil {
+				return err
+			}
+
+			if keepSource {
+				logger.Info(fmt.Sprintf("Successfully copied %q to %q, keeping the source", sourceKey, destKey))
+				return nil
+			}
+
+			_, err = srcURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{ModifiedAccessConditions: unchanged})
+			if err != nil {
+				return fmt.Errorf("copied %q to %q but deleting the source failed: %v", sourceKey, destKey, err)
+			}
+
+			logger.Info(fmt.Sprintf("Successfully renamed %q to %q", sourceKey, destKey))
+			return nil
+		},
+	}
+)
+
+func init() {
+	renameBlobCmd.PersistentFlags().StringVar(&sourceKey, "source-key", "", "indicate a blob key to rename")
+	renameBlobCmd.PersistentFlags().StringVar(&destKey, "dest-key", "", "indicate a blob key to rename to")
+	renameBlobCmd.PersistentFlags().BoolVar(&keepSource, "keep-source", false, "copy without deleting the source")
+
+	rootCmd.AddCommand(renameBlobCmd)
+}
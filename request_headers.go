@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// requestHeadersContextKey is the context key of the headers that
+// requestHeadersPipeline adds to the requests.
+type requestHeadersContextKey struct{}
+
+// requestHeadersPipeline adds the headers of the request context, if any,
+// to the requests. It passes options that the SDK methods and the blob
+// readers and writers don't take, e.g. customer-provided keys.
+type requestHeadersPipeline struct {
+	pipeline.Pipeline
+}
+
+func (p requestHeadersPipeline) Do(ctx context.Context, methodFactory pipeline.Factory, request pipeline.Request) (pipeline.Response, error) {
+	if h, ok := ctx.Value(requestHeadersContextKey{}).(http.Header); ok {
+		for name, values := range h {
+			request.Header[name] = values
+		}
+	}
+	return p.Pipeline.Do(ctx, methodFactory, request)
+}
+
+// withRequestHeaders returns ctx adding h to the headers sent with the
+// requests made with it.
+func withRequestHeaders(ctx context.Context, h http.Header) context.Context {
+	merged := http.Header{}
+	if prev, ok := ctx.Value(requestHeadersContextKey{}).(http.Header); ok {
+		for name, values := range prev {
+			merged[name] = values
+		}
+	}
+	for name, values := range h {
+		merged[name] = values
+	}
+	return context.WithValue(ctx, requestHeadersContextKey{}, merged)
+}
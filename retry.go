@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// defaultRetryStatusCodes are the HTTP statuses that are always retried:
+// Azure's standard set of transient statuses, and 429 for throttling.
+var defaultRetryStatusCodes = []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+
+var (
+	// retryStatusCodes are the HTTP status codes set with --retry-status-codes.
+	retryStatusCodes []int
+
+	// The retry policy set with --max-retries, --retry-delay and
+	// --max-retry-delay. The defaults are the SDK's.
+	maxRetries    int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+
+	// throttleBackoff is the base delay before a throttled request is
+	// retried, set with --throttle-backoff.
+	throttleBackoff time.Duration
+
+	// maxTotalRetries is the number of retries all requests of a command
+	// may make together, set with --max-total-retries. Zero is no limit.
+	maxTotalRetries int
+	// totalRetries counts the retries of the command so far, reset by
+	// Execute.
+	totalRetries int64
+)
+
+// errRetryBudget is the error of the items a batch command doesn't start
+// once its requests retried more than --max-total-retries times.
+var errRetryBudget = errors.New(`not attempted, the requests retried more than "--max-total-retries" times`)
+
+// checkRetryOptions returns an error if the retry policy is not sane.
+func checkRetryOptions() error {
+	if maxRetries < 0 {
+		return fmt.Errorf(`flag "--max-retries" should not be negative`)
+	}
+	if retryDelay <= 0 {
+		return fmt.Errorf(`flag "--retry-delay" should be positive`)
+	}
+	if maxRetryDelay <= 0 {
+		return fmt.Errorf(`flag "--max-retry-delay" should be positive`)
+	}
+	if retryDelay > maxRetryDelay {
+		return fmt.Errorf(`flag "--retry-delay" should not exceed "--max-retry-delay"`)
+	}
+	if throttleBackoff <= 0 {
+		return fmt.Errorf(`flag "--throttle-backoff" should be positive`)
+	}
+	if maxTotalRetries < 0 {
+		return fmt.Errorf(`flag "--max-total-retries" should not be negative`)
+	}
+	return nil
+}
+
+// spendRetry counts a retry of a request that failed with err against
+// --max-total-retries, and reports whether it may be made. The retry that
+// exceeds the budget is refused, logging that the failures are likely
+// systemic.
+func spendRetry(err error) bool {
+	if maxTotalRetries == 0 {
+		return true
+	}
+	n := atomic.AddInt64(&totalRetries, 1)
+	if n == int64(maxTotalRetries)+1 {
+		logger.Error(fmt.Sprintf("Giving up after %d retries in all (--max-total-retries), the failures are likely systemic, e.g. bad credentials, throttling or an unreachable service: %v", maxTotalRetries, err))
+	}
+	return n <= int64(maxTotalRetries)
+}
+
+// retryBudgetExceeded reports whether a retry was refused by spendRetry.
+func retryBudgetExceeded() bool {
+	return maxTotalRetries > 0 && atomic.LoadInt64(&totalRetries) > int64(maxTotalRetries)
+}
+
+// retryOptions returns the SDK retry options. The SDK only makes a single
+// try, since requests are retried by retryPipeline instead, which a
+// retrying SDK policy would multiply the tries of.
+func retryOptions() azblob.RetryOptions {
+	return azblob.RetryOptions{
+		Policy: azblob.RetryPolicyExponential,
+		// MaxTries counts the first try, and zero means the default
+		MaxTries: 1,
+	}
+}
+
+// checkRetryStatusCodes returns an error if --retry-status-codes holds a
+// status code that is not an HTTP error status, or a negated one.
+func checkRetryStatusCodes() error {
+	for _, code := range retryStatusCodes {
+		if code < 0 {
+			code = -code
+		}
+		if code < 400 || code > 599 {
+			return fmt.Errorf(`flag "--retry-status-codes" should only hold HTTP error statuses (400-599), or negated ones to exclude, got %d`, code)
+		}
+	}
+	return nil
+}
+
+// isRetryStatus reports whether err is a storage error whose HTTP status is
+// retried: one of defaultRetryStatusCodes or --retry-status-codes, unless
+// --retry-status-codes excludes it by its negation, e.g. -503.
+func isRetryStatus(err error) bool {
+	serr, ok := err.(azblob.StorageError)
+	if !ok || serr.Response() == nil {
+		return false
+	}
+	status := serr.Response().StatusCode
+	retry := false
+	for _, code := range defaultRetryStatusCodes {
+		if code == status {
+			retry = true
+		}
+	}
+	for _, code := range retryStatusCodes {
+		switch code {
+		case status:
+			retry = true
+		case -status:
+			return false
+		}
+	}
+	return retry
+}
+
+// isNetworkError reports whether err is a failure to reach the service or
+// to read its response, such as a reset connection, which is worth retrying.
+// Failures to resolve the account's host are not, since they mostly come
+// from a mistyped account name.
+func isNetworkError(err error) bool {
+	if _, ok := err.(azblob.StorageError); ok {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryPipeline retries requests that fail because of the network or with
+// one of the statuses of isRetryStatus, up to --max-retries times, and
+// while the retries of the command stay within --max-total-retries. It is
+// the only layer that retries requests, so that a request is sent at most
+// --max-retries + 1 times. Throttled requests back off as told by
+// throttleDelay, other ones exponentially from --retry-delay up to
+// --max-retry-delay.
+type retryPipeline struct {
+	pipeline.Pipeline
+}
+
+func (p retryPipeline) Do(ctx context.Context, methodFactory pipeline.Factory, request pipeline.Request) (pipeline.Response, error) {
+	for try := 0; ; try++ {
+		// Policies may modify the request, so each try sends a copy
+		req := request.Copy()
+		if err := req.RewindBody(); err != nil {
+			return nil, err
+		}
+		resp, err := p.Pipeline.Do(ctx, methodFactory, req)
+		if try == maxRetries || ctx.Err() != nil || !(isRetryStatus(err) || isNetworkError(err)) || !spendRetry(err) {
+			return resp, err
+		}
+		// Drain the response so that its connection is reused
+		if resp != nil && resp.Response() != nil && resp.Response().Body != nil {
+			io.Copy(ioutil.Discard, resp.Response().Body)
+			resp.Response().Body.Close()
+		}
+
+		delay, throttled := throttleDelay(err, try)
+		if !throttled {
+			delay = backoffDelay(retryDelay, try)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return resp, err
+		}
+	}
+}
+
+// backoffDelay returns the delay before the try-th retry, base doubled with
+// every retry, up to --max-retry-delay.
+func backoffDelay(base time.Duration, try int) time.Duration {
+	delay := base
+	for i := 0; i < try && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// throttleDelay reports whether err means the account is throttling
+// requests, with a 503 ServerBusy or a 429, and how long to wait before the
+// try-th retry: the Retry-After of the response if longer, and otherwise
+// --throttle-backoff doubled with every retry, up to --max-retry-delay.
+func throttleDelay(err error, try int) (time.Duration, bool) {
+	var serr azblob.StorageError
+	if !errors.As(err, &serr) {
+		return 0, false
+	}
+	resp := serr.Response()
+	throttled := serr.ServiceCode() == azblob.ServiceCodeServerBusy ||
+		(resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable))
+	if !throttled {
+		return 0, false
+	}
+
+	delay := backoffDelay(throttleBackoff, try)
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && time.Duration(secs)*time.Second > delay {
+			delay = time.Duration(secs) * time.Second
+		}
+	}
+	return delay, true
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// statusError returns the storage error of a response with status.
+func statusError(status int) error {
+	req, _ := http.NewRequest(http.MethodGet, "https://account.blob.core.windows.net/c/k", nil)
+	resp := &http.Response{StatusCode: status, Header: http.Header{}, Request: req}
+	return azblob.NewResponseError(nil, resp, "throttled")
+}
+
+// setRetryPolicy sets a retry policy with short delays for the duration of
+// the test.
+func setRetryPolicy(t *testing.T, retries int) {
+	savedRetries, savedBackoff, savedMaxDelay := maxRetries, throttleBackoff, maxRetryDelay
+	t.Cleanup(func() {
+		maxRetries, throttleBackoff, maxRetryDelay = savedRetries, savedBackoff, savedMaxDelay
+	})
+	maxRetries = retries
+	throttleBackoff = time.Millisecond
+	maxRetryDelay = time.Millisecond
+}
+
+// stubPipeline returns a pipeline like the one of initPipeline, whose
+// requests are answered with statuses in turn, the last one repeating, and
+// counts the requests sent in *requests.
+func stubPipeline(statuses []int, requests *int) pipeline.Pipeline {
+	opts := pipelineOptions()
+	opts.HTTPSender = pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			status := statuses[len(statuses)-1]
+			if *requests < len(statuses) {
+				status = statuses[*requests]
+			}
+			*requests++
+			return pipeline.NewHTTPResponse(&http.Response{
+				StatusCode: status,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    request.Request,
+			}), nil
+		}
+	})
+	return wrapPipeline(azblob.NewPipeline(azblob.NewAnonymousCredential(), opts))
+}
+
+func TestRetryPipeline(t *testing.T) {
+	u, err := url.Parse("https://account.blob.core.windows.net/c/k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		statuses   []int
+		extra      []int
+		wantErr    bool
+		wantTries  int
+		maxRetries int
+	}{
+		{name: "503 then 200", statuses: []int{503, 200}, wantTries: 2, maxRetries: 3},
+		{name: "429 then 200", statuses: []int{429, 200}, wantTries: 2, maxRetries: 3},
+		{name: "always 503", statuses: []int{503}, wantErr: true, wantTries: 3, maxRetries: 2},
+		{name: "no retries", statuses: []int{500}, wantErr: true, wantTries: 1, maxRetries: 0},
+		{name: "404", statuses: []int{404}, wantErr: true, wantTries: 1, maxRetries: 3},
+		{name: "504", statuses: []int{504, 200}, wantErr: true, wantTries: 1, maxRetries: 3},
+		{name: "504 with --retry-status-codes", statuses: []int{504, 200}, extra: []int{504}, wantTries: 2, maxRetries: 3},
+		{name: "503 excluded", statuses: []int{503, 200}, extra: []int{-503}, wantErr: true, wantTries: 1, maxRetries: 3},
+		{name: "500 with 503 excluded", statuses: []int{500, 200}, extra: []int{-503}, wantTries: 2, maxRetries: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRetryPolicy(t, tt.maxRetries)
+			saved := retryStatusCodes
+			t.Cleanup(func() { retryStatusCodes = saved })
+			retryStatusCodes = tt.extra
+
+			var requests int
+			blobURL := azblob.NewBlobURL(*u, stubPipeline(tt.statuses, &requests))
+			_, err := blobURL.GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if requests != tt.wantTries {
+				t.Errorf("sent %d requests, want %d", requests, tt.wantTries)
+			}
+		})
+	}
+}
+
+func TestThrottleDelay(t *testing.T) {
+	setRetryPolicy(t, 3)
+	throttleBackoff = time.Second
+	maxRetryDelay = 5 * time.Second
+
+	for try, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		delay, throttled := throttleDelay(statusError(http.StatusServiceUnavailable), try)
+		if !throttled || delay != want {
+			t.Errorf("try %d: got %v, %v, want %v, true", try, delay, throttled, want)
+		}
+	}
+
+	err := statusError(http.StatusTooManyRequests)
+	err.(azblob.StorageError).Response().Header.Set("Retry-After", "30")
+	if delay, _ := throttleDelay(err, 0); delay != 30*time.Second {
+		t.Errorf("got %v with Retry-After: 30, want 30s", delay)
+	}
+
+	if _, throttled := throttleDelay(statusError(http.StatusNotFound), 0); throttled {
+		t.Error("404 is throttled")
+	}
+}
+
+func TestCheckRetryStatusCodes(t *testing.T) {
+	saved := retryStatusCodes
+	t.Cleanup(func() { retryStatusCodes = saved })
+
+	tests := []struct {
+		codes   []int
+		wantErr bool
+	}{
+		{codes: nil},
+		{codes: []int{408, 504}},
+		{codes: []int{-503, 504}},
+		{codes: []int{200}, wantErr: true},
+		{codes: []int{-200}, wantErr: true},
+		{codes: []int{600}, wantErr: true},
+	}
+	for _, tt := range tests {
+		retryStatusCodes = tt.codes
+		if err := checkRetryStatusCodes(); (err != nil) != tt.wantErr {
+			t.Errorf("checkRetryStatusCodes() with %v: got error %v, want error %v", tt.codes, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	u, err := url.Parse("https://account.blob.core.windows.net/c/k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setRetryPolicy(t, 2)
+	savedMax, savedTotal := maxTotalRetries, totalRetries
+	t.Cleanup(func() { maxTotalRetries, totalRetries = savedMax, savedTotal })
+	maxTotalRetries, totalRetries = 3, 0
+
+	// The first request spends 2 retries, the second the last one
+	for n, wantTries := range []int{3, 2, 1} {
+		var requests int
+		blobURL := azblob.NewBlobURL(*u, stubPipeline([]int{503}, &requests))
+		if _, err := blobURL.GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{}); err == nil {
+			t.Fatalf("request %d succeeded", n)
+		}
+		if requests != wantTries {
+			t.Errorf("request %d: sent %d requests, want %d", n, requests, wantTries)
+		}
+	}
+	if !retryBudgetExceeded() {
+		t.Fatal("the retry budget is not exceeded")
+	}
+
+	// Batches start no more items
+	errs := runPool(3, 2, func(i int) error {
+		t.Errorf("item %d started with the retry budget exceeded", i)
+		return nil
+	})
+	if len(errs) != 3 {
+		t.Fatalf("got %d errors, want 3", len(errs))
+	}
+	for _, e := range errs {
+		if e.Err != errRetryBudget {
+			t.Errorf("item %d failed with %v, want %v", e.Index, e.Err, errRetryBudget)
+		}
+	}
+}
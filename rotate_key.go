@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob/azureblob"
+)
+
+var (
+	// Flags
+	newAccountKey string
+
+	// Commands
+	rotateKeyCmd = &cobra.Command{
+		Use:         "rotate-key",
+		Short:       "Check that a regenerated account key works",
+		Annotations: accountLevel,
+		Long: `Check that a regenerated account key works.
+
+After regenerating a storage account key in the Azure portal, run this
+command with the new key to confirm it authenticates against the account
+before switching over to it. The key is read from --new-key or, if that is
+not set, from the first line of standard input so it doesn't end up in the
+shell history. The key is never printed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := newAccountKey
+			if key == "" {
+				line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf(`flag "--new-key" should be set or the key should be passed on stdin`)
+				}
+				key = strings.TrimSpace(line)
+			}
+
+			// Build a pipeline with the new key only.
+			cred, err := azureblob.NewCredential(accountName, azureblob.AccountKey(key))
+			if err != nil {
+				return fmt.Errorf("new key is not valid: %v", err)
+			}
+			p := wrapPipeline(azureblob.NewPipeline(cred, pipelineOptions()))
+
+			if _, err := azblob.NewServiceURL(serviceURL(), p).GetAccountInfo(ctx); err != nil {
+				return fmt.Errorf("new key was rejected by account %q: %v", accountName, err)
+			}
+
+			logger.Info(fmt.Sprintf("Successfully authenticated to %q with the new key", accountName))
+			return nil
+		},
+	}
+)
+
+func init() {
+	rotateKeyCmd.PersistentFlags().StringVar(&newAccountKey, "new-key", "", "indicate the regenerated account key to check (read from stdin if empty)")
+
+	rootCmd.AddCommand(rotateKeyCmd)
+}
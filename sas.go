@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// checkSharedKey returns an error in SAS mode, where there is no account key
+// to sign with. In Azure AD mode, it warns on errOut that URLs are signed
+// with a user delegation key instead. feature describes what needs the key.
+func checkSharedKey(errOut io.Writer, feature string) error {
+	if useAAD() {
+		logger.Warn(fmt.Sprintf("%s requires the account key, which is not available with \"--auth-mode\" aad, signing with a user delegation key instead", feature))
+		return nil
+	}
+	if credential == nil {
+		return fmt.Errorf("%s requires the account key, which is not available with \"--sas-token\": "+
+			"a SAS token can't be used to sign other URLs, use \"--account-key\" or \"--connection-string\" instead", feature)
+	}
+	return nil
+}
+
+// sasProtocol returns the protocols the SAS tokens this tool signs allow:
+// HTTPS only, unless the service is reached over HTTP, as with --emulator or
+// an http:// service URL, where HTTPS-only tokens would be refused.
+func sasProtocol() azblob.SASProtocol {
+	if storageProtocol == "https" {
+		return azblob.SASProtocolHTTPS
+	}
+	return azblob.SASProtocolHTTPSandHTTP
+}
+
+// redactedURL returns u without its query, so the SAS token of the service
+// URL doesn't leak into output.
+func redactedURL(u url.URL) string {
+	u.RawQuery = ""
+	return u.String()
+}
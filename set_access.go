@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	publicAccess string
+
+	// Commands
+	setAccessCmd = &cobra.Command{
+		Use:         "set-access",
+		Short:       "Change the public access level of a container",
+		Annotations: mutating,
+		Long: `Change the public access level of a container.
+
+--public-access is none to make the container private, blob to allow
+anonymous reads of its blobs, or container to also allow anonymous listing.
+The stored access policies of the container are kept, and the level is
+only changed if the container was not modified since they were read. With
+--verbose, the current level is reported first.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if publicAccess == "" {
+				return fmt.Errorf(`flag "--public-access" should be set`)
+			}
+			access, err := parsePublicAccess(publicAccess)
+			if err != nil {
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			containerURL := newContainerURL(containerName)
+			policy, err := containerURL.GetAccessPolicy(ctx, azblob.LeaseAccessConditions{})
+			if err != nil {
+				return err
+			}
+			if verbose {
+				fmt.Fprintf(errOut, "Current public access of %q: %s\n", containerName, publicAccessName(policy.BlobPublicAccess()))
+			}
+
+			_, err = containerURL.SetAccessPolicy(ctx, access, policy.Items, azblob.ContainerAccessConditions{
+				ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfUnmodifiedSince: policy.LastModified()},
+			})
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully set the public access of %q to %s", containerName, publicAccessName(access)))
+			return nil
+		},
+	}
+)
+
+// parsePublicAccess returns the public access level named s, where an
+// empty s is none.
+func parsePublicAccess(s string) (azblob.PublicAccessType, error) {
+	switch s {
+	case "", "none":
+		return azblob.PublicAccessNone, nil
+	case "blob":
+		return azblob.PublicAccessBlob, nil
+	case "container":
+		return azblob.PublicAccessContainer, nil
+	}
+	return azblob.PublicAccessNone, fmt.Errorf(`flag "--public-access" should be none, blob or container, not %q`, s)
+}
+
+// publicAccessName returns the name of the public access level access, as
+// accepted by --public-access.
+func publicAccessName(access azblob.PublicAccessType) string {
+	if access == azblob.PublicAccessNone {
+		return "none"
+	}
+	return string(access)
+}
+
+func init() {
+	setAccessCmd.PersistentFlags().StringVar(&publicAccess, "public-access", "", "indicate a public access level (none, blob or container)")
+
+	rootCmd.AddCommand(setAccessCmd)
+}
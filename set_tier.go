@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	tierName string
+
+	// Commands
+	setTierCmd = &cobra.Command{
+		Use:         "set-tier",
+		Short:       "Set the access tier of a blob",
+		Annotations: mutating,
+		Long: `Set the access tier of a blob.
+
+The block blob --blob-key is moved to the access tier given by --tier (Hot,
+Cool or Archive). Archived blobs cannot be read until they are rehydrated,
+which may take several hours, by moving them back to Hot or Cool.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			tier, ok := parseAccessTier(tierName)
+			if !ok {
+				return fmt.Errorf(`flag "--tier" should be one of "Hot", "Cool" or "Archive"`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			_, err := newBlobURL(containerName, blobKey).SetTier(ctx, tier, azblob.LeaseAccessConditions{})
+			if serr, ok := err.(azblob.StorageError); ok && serr.Response() != nil && serr.Response().StatusCode == http.StatusConflict {
+				// E.g. the blob is being rehydrated or is not a block blob
+				return fmt.Errorf("cannot move %q to %s: %s", blobKey, tier, serr.ServiceCode())
+			}
+			if err != nil {
+				return err
+			}
+
+			if tier == azblob.AccessTierArchive {
+				logger.Warn(fmt.Sprintf("Note: %q must be rehydrated to Hot or Cool before it can be read again", blobKey))
+			}
+			logger.Info(fmt.Sprintf("Successfully set %q to %s", blobKey, tier))
+			return nil
+		},
+	}
+
+	setTierPrefixCmd = &cobra.Command{
+		Use:   "set-tier-prefix",
+		Short: "Set the access tier of all blobs under a prefix",
+		Long: `Set the access tier of all blobs under a prefix.
+
+Every block blob under --blob-prefix is moved to the access tier given by
+--tier (Hot, Cool or Archive), --concurrency blobs at a time. Blobs already
+in the target tier are skipped, as are blobs that are not block blobs, since
+only block blobs have a Hot/Cool/Archive tier.
+
+Archived blobs cannot be read until they are rehydrated. Moving a blob out
+of Archive starts its rehydration, which may take several hours, and the
+blob stays in Archive until it completes. Blobs that are already being
+rehydrated are skipped. Use --dry-run to only print what would change.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			tier, ok := parseAccessTier(tierName)
+			if !ok {
+				return fmt.Errorf(`flag "--tier" should be one of "Hot", "Cool" or "Archive"`)
+			}
+
+			if !dryRun {
+				if err := checkWritable("set access tiers"); err != nil {
+					return err
+				}
+			}
+
+			items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{})
+			if err != nil {
+				return err
+			}
+
+			var (
+				mu               sync.Mutex
+				changed, skipped int
+			)
+			// report prints a per-blob line and counts the blob in n.
+			report := func(n *int, format string, a ...interface{}) {
+				mu.Lock()
+				defer mu.Unlock()
+				*n++
+				fmt.Fprintf(out, format, a...)
+			}
+			errs := runPool(len(items), concurrency, func(i int) error {
+				item := items[i]
+				from := item.Properties.AccessTier
+
+				action, skip := tierChange(item, tier)
+				if skip != "" {
+					report(&skipped, "SKIPPED %s: %s\n", item.Name, skip)
+					return nil
+				}
+				if dryRun {
+					report(&changed, "would %s %s: %s -> %s\n", strings.ToLower(action), item.Name, from, tier)
+					return nil
+				}
+
+				if _, err := newBlobURL(containerName, item.Name).SetTier(ctx, tier, azblob.LeaseAccessConditions{}); err != nil {
+					return err
+				}
+				report(&changed, "%s %s: %s -> %s\n", action, item.Name, from, tier)
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", items[e.Index].Name, e.Err))
+			}
+
+			verb := "Changed"
+			if dryRun {
+				verb = "Would change"
+			}
+			logger.Info(fmt.Sprintf("%s: %d, skipped: %d, failed: %d", verb, changed, skipped, len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if !dryRun {
+				logger.Info(fmt.Sprintf("Successfully set blobs under %q to %s", blobPrefix, tier))
+			}
+			return nil
+		},
+	}
+)
+
+// tierChange returns the action that moves item to tier, "SET" or
+// "REHYDRATE" for archived blobs, or the reason to skip the blob.
+func tierChange(item azblob.BlobItemInternal, tier azblob.AccessTierType) (action, skip string) {
+	switch {
+	case item.Properties.BlobType != azblob.BlobBlockBlob:
+		return "", fmt.Sprintf("%s has no access tier", item.Properties.BlobType)
+	case item.Properties.AccessTier == tier:
+		return "", fmt.Sprintf("already %s", tier)
+	case item.Properties.ArchiveStatus != azblob.ArchiveStatusNone:
+		return "", string(item.Properties.ArchiveStatus)
+	case item.Properties.AccessTier == azblob.AccessTierArchive:
+		return "REHYDRATE", ""
+	}
+	return "SET", ""
+}
+
+// parseAccessTier returns the block blob access tier named by name,
+// ignoring case.
+func parseAccessTier(name string) (azblob.AccessTierType, bool) {
+	for _, tier := range []azblob.AccessTierType{azblob.AccessTierHot, azblob.AccessTierCool, azblob.AccessTierArchive} {
+		if strings.EqualFold(name, string(tier)) {
+			return tier, true
+		}
+	}
+	return azblob.AccessTierNone, false
+}
+
+func init() {
+	setTierCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to set the access tier of")
+	setTierCmd.PersistentFlags().StringVar(&tierName, "tier", "", "indicate an access tier to move the blob to (Hot, Cool or Archive)")
+
+	setTierPrefixCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to set the access tier under")
+	setTierPrefixCmd.PersistentFlags().StringVar(&tierName, "tier", "", "indicate an access tier to move the blobs to (Hot, Cool or Archive)")
+
+	rootCmd.AddCommand(setTierCmd)
+	rootCmd.AddCommand(setTierPrefixCmd)
+}
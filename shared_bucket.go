@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/noprysk-ua/azure/blobstore"
+	"gocloud.dev/blob"
+)
+
+// The client of --container-name shared by the commands of the process,
+// opened on first use and closed by closeSharedBucket.
+var (
+	sharedBucketMu      sync.Mutex
+	sharedClient        *blobstore.Client
+	sharedBucketRefs    int
+	sharedBucketClosing bool
+)
+
+// getClient returns the client of the container named by
+// --container-name, opening it on first use and reusing it afterwards, so
+// that commands and their worker pools don't reopen it. It is safe for
+// concurrent use. Every caller must call the returned release func once
+// done with the client, instead of closing it.
+func getClient(ctx context.Context) (*blobstore.Client, func(), error) {
+	sharedBucketMu.Lock()
+	defer sharedBucketMu.Unlock()
+
+	if sharedClient == nil {
+		c, err := openClient(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		sharedClient = c
+	}
+	sharedBucketRefs++
+
+	var once sync.Once
+	release := func() {
+		once.Do(releaseBucket)
+	}
+	return sharedClient, release, nil
+}
+
+// getBucket is like getClient, but returns the bucket of the client.
+func getBucket(ctx context.Context) (*blob.Bucket, func(), error) {
+	c, release, err := getClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Bucket(), release, nil
+}
+
+// releaseBucket drops a reference to the shared client, closing it if it is
+// the last one and closeSharedBucket was called.
+func releaseBucket() {
+	sharedBucketMu.Lock()
+	defer sharedBucketMu.Unlock()
+
+	sharedBucketRefs--
+	if sharedBucketRefs == 0 && sharedBucketClosing {
+		closeBucketLocked()
+	}
+}
+
+// closeSharedBucket closes the shared client, if it was opened, once every
+// caller of getClient released it. It is called when the process is done
+// with the client, so that it is closed exactly once.
+func closeSharedBucket() {
+	sharedBucketMu.Lock()
+	defer sharedBucketMu.Unlock()
+
+	if sharedClient == nil {
+		return
+	}
+	sharedBucketClosing = true
+	if sharedBucketRefs == 0 {
+		closeBucketLocked()
+	}
+}
+
+// closeBucketLocked closes the shared client. sharedBucketMu must be held.
+func closeBucketLocked() {
+	if err := sharedClient.Close(); err != nil {
+		logger.Warn(fmt.Sprintf("Closing the bucket: %v", err))
+	}
+	sharedClient = nil
+	sharedBucketClosing = false
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestGetClientShared(t *testing.T) {
+	s := newFakeService(t, "test")
+	openFakeBucket(t, s)
+	saved := containerName
+	t.Cleanup(func() { containerName = saved })
+	containerName = "test"
+
+	c1, release1, err := getClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, release2, err := getBucket(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != c1.Bucket() {
+		t.Error("getBucket opened another bucket than getClient")
+	}
+
+	// The client stays open while it is used
+	closeSharedBucket()
+	release1()
+	if sharedClient == nil {
+		t.Fatal("the shared client was closed while in use")
+	}
+	release2()
+	// Releasing twice doesn't drop another reference
+	release2()
+	if sharedClient != nil {
+		t.Error("the shared client is open once released and closed")
+	}
+	if sharedBucketRefs != 0 {
+		t.Errorf("%d references are left", sharedBucketRefs)
+	}
+}
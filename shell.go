@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Commands
+	shellCmd = &cobra.Command{
+		Use:   "shell",
+		Short: "Explore the container interactively",
+		Long: `Explore the container interactively.
+
+Commands are read from stdin, one per line, and run against --container-name
+without starting the binary again:
+
+  ls             list the blobs and "directories" under the current prefix
+  cd PREFIX      move into PREFIX, relative to the current prefix
+  cd ..          move up one "/" level, "cd" alone back to the root
+  cat KEY        print the blob KEY
+  put KEY VALUE  write VALUE, followed by a newline, to the blob KEY
+  rm KEY         delete the blob KEY
+  exit           leave the shell, like Ctrl-D
+
+Keys are relative to the current prefix. put and rm are refused in
+read-only mode and only print what they would do with --dry-run.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			sh := &shell{out: out, bucket: bucket}
+			scanner := bufio.NewScanner(cmd.InOrStdin())
+			for {
+				fmt.Fprintf(out, "%s:/%s> ", containerName, sh.prefix)
+				if !scanner.Scan() {
+					// Ctrl-D leaves the prompt on its own line
+					fmt.Fprintln(out)
+					return scanner.Err()
+				}
+
+				line := strings.TrimSpace(scanner.Text())
+				if line == "exit" {
+					return nil
+				}
+				if err := sh.run(ctx, line); err != nil {
+					logger.Error(err.Error())
+				}
+			}
+		},
+	}
+)
+
+// shell runs the commands of the shell command against bucket.
+type shell struct {
+	out    io.Writer
+	bucket *blob.Bucket
+	// prefix is the current prefix, empty or ending with "/".
+	prefix string
+}
+
+// run runs a command line of the shell.
+func (sh *shell) run(ctx context.Context, line string) error {
+	name, rest := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, rest = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch name {
+	case "":
+		return nil
+	case "ls":
+		return sh.ls(ctx)
+	case "cd":
+		sh.cd(rest)
+		return nil
+	case "cat":
+		if rest == "" {
+			return fmt.Errorf("usage: cat KEY")
+		}
+		return catBlob(ctx, sh.out, sh.bucket, sh.prefix+rest)
+	case "put":
+		key, value := rest, ""
+		if i := strings.IndexAny(rest, " \t"); i >= 0 {
+			key, value = rest[:i], strings.TrimSpace(rest[i+1:])
+		}
+		if key == "" {
+			return fmt.Errorf("usage: put KEY VALUE")
+		}
+		return sh.put(ctx, sh.prefix+key, value)
+	case "rm":
+		if rest == "" {
+			return fmt.Errorf("usage: rm KEY")
+		}
+		return sh.rm(ctx, sh.prefix+rest)
+	}
+	return fmt.Errorf("unknown command %q, use ls, cd, cat, put, rm or exit", name)
+}
+
+// ls lists the blobs and prefixes directly under the current prefix.
+func (sh *shell) ls(ctx context.Context) error {
+	iter := sh.bucket.List(&blob.ListOptions{Prefix: sh.prefix, Delimiter: "/"})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(obj.Key, sh.prefix)
+		if obj.IsDir {
+			fmt.Fprintln(sh.out, colorize(sh.out, colorBlue, name))
+			continue
+		}
+		fmt.Fprintf(sh.out, "%s\t%s\n", name, formatBytes(obj.Size))
+	}
+}
+
+// cd changes the current prefix to dir, relative to the current prefix.
+func (sh *shell) cd(dir string) {
+	switch dir {
+	case "", "/":
+		sh.prefix = ""
+	case "..":
+		// Drop the last "/" level
+		trimmed := strings.TrimSuffix(sh.prefix, "/")
+		if i := strings.LastIndex(trimmed, "/"); i >= 0 {
+			sh.prefix = trimmed[:i+1]
+		} else {
+			sh.prefix = ""
+		}
+	default:
+		sh.prefix += strings.TrimSuffix(dir, "/") + "/"
+	}
+}
+
+// put writes value followed by a newline to key, like write does.
+func (sh *shell) put(ctx context.Context, key, value string) error {
+	if dryRun {
+		fmt.Fprintf(sh.out, "would write %q to %q\n", value, key)
+		return nil
+	}
+	if err := checkWritable("write blobs"); err != nil {
+		return err
+	}
+
+	content := []byte(value + "\n")
+	opts := &blob.WriterOptions{}
+	if !noMD5 {
+		sum := md5.Sum(content)
+		opts.ContentMD5 = sum[:]
+	}
+	if err := sh.bucket.WriteAll(ctx, key, content, opts); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Successfully written %q to %q", value, key))
+	return nil
+}
+
+// rm deletes key.
+func (sh *shell) rm(ctx context.Context, key string) error {
+	if dryRun {
+		fmt.Fprintf(sh.out, "would delete %q\n", key)
+		return nil
+	}
+	if err := checkWritable("delete blobs"); err != nil {
+		return err
+	}
+
+	if err := sh.bucket.Delete(ctx, key); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Successfully deleted %q", key))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	signMethod string
+
+	// Commands
+	signedURLCmd = &cobra.Command{
+		Use:   "signed-url",
+		Short: "Print a time-limited URL to download or upload a blob",
+		Long: `Print a time-limited URL to download or upload a blob.
+
+The URL is signed with the account key and grants access to --blob-key
+only, for --expiry, without any other credentials. With --method GET (the
+default) it can be used to download the blob, and with --method PUT to
+upload it, e.g. with:
+
+  curl -X PUT -H "x-ms-blob-type: BlockBlob" --data-binary @file "$URL"
+
+Anyone holding the URL has that access until it expires, so keep --expiry
+short. Signing needs the account key, so the command is not available with
+--sas-token. With --auth-mode aad, the URL is signed with a user delegation
+key instead, for at most 7 days.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if expiry <= 0 {
+				return fmt.Errorf(`flag "--expiry" should be positive`)
+			}
+			method := strings.ToUpper(signMethod)
+			if method != http.MethodGet && method != http.MethodPut {
+				return fmt.Errorf(`flag "--method" should be one of "GET" or "PUT"`)
+			}
+			if err := checkSharedKey(cmd.ErrOrStderr(), "signing URLs"); err != nil {
+				return err
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			u, err := signURL(ctx, bucket, blobKey, &blob.SignedURLOptions{Expiry: expiry, Method: method})
+			if err != nil {
+				return err
+			}
+
+			// Print only the URL, so the output can be captured.
+			fmt.Fprintln(out, u)
+			return nil
+		},
+	}
+)
+
+func init() {
+	signedURLCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to sign a URL for")
+	signedURLCmd.PersistentFlags().DurationVar(&expiry, "expiry", time.Hour, "indicate how long the URL stays valid")
+	signedURLCmd.PersistentFlags().StringVar(&signMethod, "method", http.MethodGet, "indicate the HTTP method the URL allows, GET to download or PUT to upload")
+
+	rootCmd.AddCommand(signedURLCmd)
+}
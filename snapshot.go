@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	readSnapshot string
+
+	// Commands
+	snapshotBlobCmd = &cobra.Command{
+		Use:         "snapshot-blob",
+		Short:       "Take a snapshot of a blob",
+		Annotations: mutating,
+		Long: `Take a snapshot of a blob.
+
+A read-only point-in-time copy of --blob-key is taken, e.g. before
+overwriting it, and its timestamp is printed to standard output, so that it
+can be captured and passed to read --snapshot.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			resp, err := newBlobURL(containerName, blobKey).CreateSnapshot(ctx, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+			if err != nil {
+				return snapshotError(err)
+			}
+
+			fmt.Fprintln(out, resp.Snapshot())
+			logger.Info(fmt.Sprintf("Successfully took a snapshot of %q", blobKey))
+			return nil
+		},
+	}
+)
+
+// checkSnapshot returns an error if snapshot is neither empty nor a
+// snapshot timestamp.
+func checkSnapshot(snapshot string) error {
+	if snapshot == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339Nano, snapshot); err != nil {
+		return fmt.Errorf(`flag "--snapshot" should be a snapshot timestamp, e.g. "2024-01-02T03:04:05.1234567Z", got %q`, snapshot)
+	}
+	return nil
+}
+
+// snapshotError explains the errors of taking a snapshot that come from
+// the account or the blob rather than from the request.
+func snapshotError(err error) error {
+	serr, ok := err.(azblob.StorageError)
+	if !ok {
+		return err
+	}
+	switch code := serr.ServiceCode(); {
+	case code == azblob.ServiceCodeSnapshotCountExceeded:
+		return fmt.Errorf("blob %q has too many snapshots, delete some first: %v", blobKey, err)
+	case code == azblob.ServiceCodeSnaphotOperationRateExceeded:
+		return fmt.Errorf("snapshots of blob %q are taken too often, retry later: %v", blobKey, err)
+	case strings.Contains(string(code), "NotSupported"):
+		return fmt.Errorf("snapshots are not supported by storage account %q, e.g. because hierarchical namespace is enabled: %v", accountName, err)
+	}
+	return err
+}
+
+func init() {
+	snapshotBlobCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to take a snapshot of")
+
+	rootCmd.AddCommand(snapshotBlobCmd)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+	"gocloud.dev/gcerrors"
+)
+
+var (
+	// Flags
+	statAll  bool
+	statKeys []string
+
+	// Commands
+	statCmd = &cobra.Command{
+		Use:   "stat",
+		Short: "Print the ETag of a blob",
+		Long: `Print the ETag of a blob.
+
+Only the ETag of --blob-key is printed, without downloading its content, so
+that it can be captured for a later conditional write:
+
+  etag=$(azure stat --blob-key config.json)
+  azure write --blob-key config.json --blob-value "$value" --if-match "$etag"
+
+With --all, all attributes are printed as by blob-properties. If the blob
+doesn't exist, the command fails with status 1.
+
+With --output ndjson, the attributes of the blobs given with --blob-key,
+which can then be repeated, or of all blobs under --blob-prefix are printed
+as one JSON object per line in key order, as by blob-properties --output
+json, so that the metadata of many blobs can be snapshotted and parsed as
+it streams. Blobs that cannot be read are reported and skipped, and the
+command then fails with status 1.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			usePrefix := cmd.Flags().Changed("blob-prefix")
+			if len(statKeys) == 0 && !usePrefix {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if len(statKeys) > 0 && usePrefix {
+				return fmt.Errorf(`flags "--blob-key" and "--blob-prefix" cannot be combined`)
+			}
+			for _, key := range statKeys {
+				if key == "" {
+					return fmt.Errorf(`flag "--blob-key" should not be empty`)
+				}
+			}
+			if outputFormat != "text" && outputFormat != "ndjson" {
+				return fmt.Errorf(`flag "--output" should be one of "text" or "ndjson"`)
+			}
+			if outputFormat == "text" && (len(statKeys) > 1 || usePrefix) {
+				return fmt.Errorf(`flag "--output" should be "ndjson" to stat several blobs`)
+			}
+
+			client, release, err := getClient(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			if outputFormat == "ndjson" {
+				keys := append([]string(nil), statKeys...)
+				if usePrefix {
+					warnFullScan(errOut, blobPrefix)
+					items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{})
+					if err != nil {
+						return err
+					}
+					for _, item := range items {
+						keys = append(keys, item.Name)
+					}
+				}
+				sort.Strings(keys)
+
+				// Every object is encoded on a line of its own
+				enc := json.NewEncoder(out)
+				failed := 0
+				for _, key := range keys {
+					attrs, err := client.Stat(ctx, key)
+					if err != nil {
+						logger.Error(fmt.Sprintf("%s: %v", key, err))
+						failed++
+						continue
+					}
+					if err := enc.Encode(newBlobProperties(attrs)); err != nil {
+						return err
+					}
+				}
+				if failed > 0 {
+					return &exitError{Code: 1}
+				}
+				return nil
+			}
+
+			blobKey := statKeys[0]
+			attrs, err := client.Stat(ctx, blobKey)
+			if gcerrors.Code(err) == gcerrors.NotFound {
+				return fmt.Errorf("blob %q does not exist in container %q", blobKey, containerName)
+			}
+			if err != nil {
+				return err
+			}
+
+			if statAll {
+				printBlobProperties(out, newBlobProperties(attrs))
+				return nil
+			}
+			fmt.Fprintln(out, attrs.ETag)
+			return nil
+		},
+	}
+)
+
+func init() {
+	statCmd.PersistentFlags().StringArrayVar(&statKeys, "blob-key", nil, "indicate a blob key to print the ETag of (repeatable with --output ndjson)")
+	statCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to print the attributes of all blobs under with --output ndjson")
+	statCmd.PersistentFlags().BoolVar(&statAll, "all", false, "print all attributes instead of only the ETag")
+	statCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "indicate an output format (text or ndjson)")
+	statCmd.PersistentFlags().BoolVar(&assumeYes, "yes", false, "do not warn when printing the attributes of the entire container")
+
+	rootCmd.AddCommand(statCmd)
+}
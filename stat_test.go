@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStatNDJSON(t *testing.T) {
+	s := newFakeService(t, "test")
+	for _, key := range []string{"logs/b", "logs/a", "other"} {
+		if _, _, err := executeFake(t, s, "write", "--blob-key", key, "--blob-value", key); err != nil {
+			t.Fatalf("write %q: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantKeys []string
+		wantErr  bool
+	}{
+		{"prefix", []string{"--blob-prefix", "logs/"}, []string{"logs/a", "logs/b"}, false},
+		{"keys", []string{"--blob-key", "other", "--blob-key", "logs/b"}, []string{"logs/b", "other"}, false},
+		// Missing blobs are skipped, and fail the command
+		{"missing", []string{"--blob-key", "missing", "--blob-key", "other"}, []string{"other"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"stat", "--output", "ndjson"}, tt.args...)
+			stdout, _, err := executeFake(t, s, args...)
+			var exitErr *exitError
+			if tt.wantErr != (err != nil) || (err != nil && !errors.As(err, &exitErr)) {
+				t.Fatalf("stat: got error %v, want one: %v", err, tt.wantErr)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+			if len(lines) != len(tt.wantKeys) {
+				t.Fatalf("stat printed %q, want %d lines", stdout, len(tt.wantKeys))
+			}
+			for i, line := range lines {
+				var props blobProperties
+				if err := json.Unmarshal([]byte(line), &props); err != nil {
+					t.Fatalf("line %q: %v", line, err)
+				}
+				if props.Key != tt.wantKeys[i] || props.ETag == "" {
+					t.Errorf("line %d is %q, want the attributes of %q", i, line, tt.wantKeys[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatSeveralKeysNeedNDJSON(t *testing.T) {
+	s := newFakeService(t, "test")
+	_, _, err := executeFake(t, s, "stat", "--blob-key", "a", "--blob-key", "b")
+	if err == nil || !strings.Contains(err.Error(), `"ndjson"`) {
+		t.Errorf("stat of several keys as text: got error %v, want --output ndjson to be required", err)
+	}
+}
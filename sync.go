@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	syncDelete bool
+
+	// Commands
+	syncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "Upload the new and changed files of a local directory",
+		Long: `Upload the new and changed files of a local directory.
+
+Every regular file under --dir is compared with the blob it would be
+uploaded to by upload-dir, and only uploaded if the blob doesn't exist or
+differs in size or MD5. Blobs without a stored MD5 are always uploaded.
+Since the comparison relies on it, the MD5 is stored even with --no-md5.
+
+With --delete, blobs under --dest-prefix that have no local file are
+deleted, unless an upload failed. Every file and blob is reported with its
+action, and failures don't stop the other files. Use --dry-run to only
+print what would change.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if localDir == "" {
+				return fmt.Errorf(`flag "--dir" should be set`)
+			}
+
+			if !dryRun {
+				if err := checkWritable("sync blobs"); err != nil {
+					return err
+				}
+			}
+
+			files, err := walkFiles(localDir)
+			if err != nil {
+				return err
+			}
+
+			prefix := uploadKey(destPrefix, "")
+			items, err := listBlobItems(ctx, containerName, prefix, azblob.BlobListingDetails{})
+			if err != nil {
+				return err
+			}
+			remote := make(map[string]azblob.BlobItemInternal, len(items))
+			for _, item := range items {
+				remote[item.Name] = item
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			local := make(map[string]bool, len(files))
+			for _, f := range files {
+				local[uploadKey(destPrefix, f)] = true
+			}
+
+			var (
+				mu                sync.Mutex
+				uploaded, skipped int
+				size              int64
+			)
+			errs := runPool(len(files), concurrency, func(i int) error {
+				path := filepath.Join(localDir, files[i])
+				key := uploadKey(destPrefix, files[i])
+
+				sum, changed, err := syncChanged(path, remote[key])
+				if err != nil {
+					return err
+				}
+				if !changed {
+					mu.Lock()
+					defer mu.Unlock()
+					skipped++
+					fmt.Fprintf(out, "SKIPPED %s: unchanged\n", key)
+					return nil
+				}
+
+				var n int64
+				if dryRun {
+					fi, err := os.Stat(path)
+					if err != nil {
+						return err
+					}
+					n = fi.Size()
+				} else {
+					opts := &blob.WriterOptions{
+						ContentType: mime.TypeByExtension(filepath.Ext(path)),
+						ContentMD5:  sum,
+					}
+					if n, err = uploadFile(ctx, bucket, key, path, opts); err != nil {
+						return err
+					}
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				uploaded++
+				size += n
+				if dryRun {
+					fmt.Fprintf(out, "would upload %s -> %s\n", path, key)
+				} else {
+					fmt.Fprintf(out, "UPLOADED %s -> %s\n", path, key)
+				}
+				return nil
+			})
+			failed := len(errs)
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", files[e.Index], e.Err))
+			}
+
+			var deleted int
+			if syncDelete {
+				var stale []azblob.BlobItemInternal
+				for _, item := range items {
+					if !local[item.Name] {
+						stale = append(stale, item)
+					}
+				}
+				sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+
+				if failed > 0 && len(stale) > 0 {
+					logger.Warn(fmt.Sprintf("Not deleting %d blobs because of the failed uploads", len(stale)))
+				} else {
+					errs := runPool(len(stale), concurrency, func(i int) error {
+						item := stale[i]
+						if !dryRun {
+							_, err := newBlobURL(containerName, item.Name).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{
+								ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: item.Properties.Etag},
+							})
+							if err != nil {
+								return err
+							}
+						}
+
+						mu.Lock()
+						defer mu.Unlock()
+						deleted++
+						if dryRun {
+							fmt.Fprintf(out, "would delete %s\n", item.Name)
+						} else {
+							fmt.Fprintf(out, "DELETED %s\n", item.Name)
+						}
+						return nil
+					})
+					failed += len(errs)
+					for _, e := range errs {
+						logger.Error(fmt.Sprintf("%s: %v", stale[e.Index].Name, e.Err))
+					}
+				}
+			}
+
+			verb := "Uploaded"
+			if dryRun {
+				verb = "Would upload"
+			}
+			logger.Info(fmt.Sprintf("%s: %d (%s), skipped: %d, deleted: %d, failed: %d",
+				verb, uploaded, formatBytes(size), skipped, deleted, failed))
+
+			if failed > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if !dryRun {
+				logger.Info(fmt.Sprintf("Successfully synced %q to %q", localDir, destPrefix))
+			}
+			return nil
+		},
+	}
+)
+
+// syncChanged reports whether the local file at path differs from the
+// listed blob item, which is zero if the blob doesn't exist, along with
+// the MD5 of the file.
+func syncChanged(path string, item azblob.BlobItemInternal) ([]byte, bool, error) {
+	sum, err := fileMD5(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if item.Name == "" || item.Properties.ContentLength == nil || len(item.Properties.ContentMD5) == 0 {
+		return sum, true, nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if fi.Size() != *item.Properties.ContentLength {
+		return sum, true, nil
+	}
+	return sum, !bytes.Equal(sum, item.Properties.ContentMD5), nil
+}
+
+func init() {
+	syncCmd.PersistentFlags().StringVar(&localDir, "dir", "", "indicate a local directory to sync")
+	syncCmd.PersistentFlags().StringVar(&destPrefix, "dest-prefix", "", "indicate a blob prefix to sync the files under")
+	syncCmd.PersistentFlags().BoolVar(&syncDelete, "delete", false, "delete the blobs that have no local file")
+
+	rootCmd.AddCommand(syncCmd)
+}
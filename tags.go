@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+// maxBlobTags is the maximum number of index tags of a blob.
+const maxBlobTags = 10
+
+var (
+	// tagKeyPattern and tagValuePattern match valid tag keys and values.
+	tagKeyPattern   = regexp.MustCompile(`^[A-Za-z0-9 +\-./:=_]{1,128}$`)
+	tagValuePattern = regexp.MustCompile(`^[A-Za-z0-9 +\-./:=_]{0,256}$`)
+)
+
+var (
+	// Flags
+	tagPairs []string
+	tagsFile string
+	tagQuery string
+
+	// Commands
+	tagBlobCmd = &cobra.Command{
+		Use:         "tag-blob",
+		Short:       "Set the index tags of a blob",
+		Annotations: mutating,
+		Long: `Set the index tags of a blob.
+
+The index tags of --blob-key are replaced by the --tag KEY=VALUE pairs, as
+Azure sets tags as a whole, so that the blob can be found with find-by-tag.
+A blob has at most 10 tags. Keys have 1 to 128 and values up to 256
+letters, digits, spaces and "+-./:=_" characters.
+
+With --tags-file, the tags are loaded from a file holding a JSON object of
+string values, e.g. {"project": "alpha"}, and merged with the --tag pairs,
+which take precedence.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if len(tagPairs) == 0 && tagsFile == "" {
+				return fmt.Errorf(`flag "--tag" or "--tags-file" should be set`)
+			}
+			tags, err := tagsOfFlags()
+			if err != nil {
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			_, err = newBlobURL(containerName, blobKey).SetTags(ctx, nil, nil, nil, tags)
+			if err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully set %d tags of %q", len(tags), blobKey))
+			return nil
+		},
+	}
+
+	getTagsCmd = &cobra.Command{
+		Use:   "get-tags",
+		Short: "Print the index tags of a blob",
+		Long: `Print the index tags of a blob.
+
+The index tags of --blob-key are printed as KEY=VALUE lines sorted by key.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			resp, err := newBlobURL(containerName, blobKey).GetTags(ctx, nil)
+			if err != nil {
+				return err
+			}
+
+			tags := make(map[string]string, len(resp.BlobTagSet))
+			for _, tag := range resp.BlobTagSet {
+				tags[tag.Key] = tag.Value
+			}
+			printMetadata(cmd.OutOrStdout(), tags)
+			return nil
+		},
+	}
+
+	findByTagCmd = &cobra.Command{
+		Use:         "find-by-tag",
+		Short:       "Find the blobs of the account by index tags",
+		Annotations: accountLevel,
+		Long: `Find the blobs of the account by index tags.
+
+The blobs of all containers whose index tags match --query are printed as
+CONTAINER/KEY lines. The query is an Azure tag filter expression, e.g.:
+
+  azure find-by-tag --query "project='alpha' AND status='done'"
+
+Add "@container='name'" to the query to only search one container.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if tagQuery == "" {
+				return fmt.Errorf(`flag "--query" should be set`)
+			}
+
+			n := 0
+			svcURL := azblob.NewServiceURL(serviceURL(), pline)
+			// Keep going until the service stops returning a marker, which
+			// is left out rather than empty at the end.
+			marker := azblob.Marker{}
+			for {
+				resp, err := svcURL.FindBlobsByTags(ctx, nil, nil, &tagQuery, marker, nil)
+				if err != nil {
+					return err
+				}
+				for _, item := range resp.Blobs {
+					fmt.Fprintf(out, "%s/%s\n", item.ContainerName, item.Name)
+				}
+				n += len(resp.Blobs)
+				if resp.NextMarker == nil || *resp.NextMarker == "" {
+					break
+				}
+				marker = azblob.Marker{Val: resp.NextMarker}
+			}
+
+			if !quiet {
+				fmt.Fprintf(errOut, "Summary: %d blobs\n", n)
+			}
+			return nil
+		},
+	}
+)
+
+// tagsOfFlags returns the tags loaded from --tags-file, if set, merged with
+// the --tag pairs, which take precedence, checking them against the
+// restrictions of Azure.
+func tagsOfFlags() (azblob.BlobTagsMap, error) {
+	pairs, err := readPairsFile("--tags-file", tagsFile)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(azblob.BlobTagsMap, len(pairs))
+	for key, value := range pairs {
+		if err := checkTag("--tags-file", key, value); err != nil {
+			return nil, err
+		}
+		tags[key] = value
+	}
+
+	inline, err := parseTagPairs(tagPairs)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range inline {
+		tags[key] = value
+	}
+	if len(tags) > maxBlobTags {
+		return nil, fmt.Errorf(`flags "--tag" and "--tags-file" should set at most %d tags, got %d tags`, maxBlobTags, len(tags))
+	}
+	return tags, nil
+}
+
+// parseTagPairs parses KEY=VALUE pairs into tags, checking them against
+// the restrictions of Azure.
+func parseTagPairs(pairs []string) (azblob.BlobTagsMap, error) {
+	tags := make(azblob.BlobTagsMap, len(pairs))
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return nil, fmt.Errorf(`flag "--tag" should be KEY=VALUE, got %q`, pair)
+		}
+		key, value := pair[:i], pair[i+1:]
+		if err := checkTag("--tag", key, value); err != nil {
+			return nil, err
+		}
+		tags[key] = value
+	}
+	if len(tags) > maxBlobTags {
+		return nil, fmt.Errorf(`flag "--tag" should be set at most %d times, got %d tags`, maxBlobTags, len(tags))
+	}
+	return tags, nil
+}
+
+// checkTag checks the tag key=value, given with flag, against the
+// restrictions of Azure.
+func checkTag(flag, key, value string) error {
+	if !tagKeyPattern.MatchString(key) {
+		return fmt.Errorf(`flag %q should have a KEY of 1 to 128 letters, digits, spaces and "+-./:=_", got %q`, flag, key)
+	}
+	if !tagValuePattern.MatchString(value) {
+		return fmt.Errorf(`flag %q should have a VALUE of up to 256 letters, digits, spaces and "+-./:=_", got %q`, flag, value)
+	}
+	return nil
+}
+
+func init() {
+	tagBlobCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to tag")
+	tagBlobCmd.PersistentFlags().StringArrayVar(&tagPairs, "tag", nil, "indicate a KEY=VALUE index tag to set (repeatable)")
+	tagBlobCmd.PersistentFlags().StringVar(&tagsFile, "tags-file", "", "indicate a JSON file of index tags to set, overridden by --tag")
+	getTagsCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to print the tags of")
+	findByTagCmd.PersistentFlags().StringVar(&tagQuery, "query", "", "indicate a tag filter expression, e.g. \"project='alpha'\"")
+
+	rootCmd.AddCommand(tagBlobCmd)
+	rootCmd.AddCommand(getTagsCmd)
+	rootCmd.AddCommand(findByTagCmd)
+}
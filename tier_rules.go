@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+// tierRule moves blobs under prefix that were last modified at least
+// minAge ago to tier.
+type tierRule struct {
+	prefix string
+	minAge time.Duration
+	tier   azblob.AccessTierType
+}
+
+var (
+	// Flags
+	rulesFile string
+
+	// Commands
+	tierByRulesCmd = &cobra.Command{
+		Use:   "tier-by-rules",
+		Short: "Move blobs to access tiers according to prefix and age rules",
+		Long: `Move blobs to access tiers according to prefix and age rules.
+
+This enforces a simple lifecycle policy from the client, for accounts
+without server-side lifecycle management. --rules-file has one rule per
+line with a prefix, a minimum age and a tier:
+
+  # prefix  age   tier
+  logs/     180d  Archive
+  logs/     30d   Cool
+  *         90d   Cool
+
+The age is a number of days such as "30d", or a duration such as "12h",
+and is compared to the last modification time of the blob. A prefix of "*"
+matches every key. Blank lines and lines starting with "#" are ignored.
+
+Every blob in the container is checked against the rules in order, and the
+first rule whose prefix and age match decides its tier. Blobs matching no
+rule are left alone, and blobs are skipped as by set-tier-prefix. Use
+--dry-run to only print what would change.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if rulesFile == "" {
+				return fmt.Errorf(`flag "--rules-file" should be set`)
+			}
+			rules, err := readTierRules(rulesFile)
+			if err != nil {
+				return err
+			}
+
+			if !dryRun {
+				if err := checkWritable("set access tiers"); err != nil {
+					return err
+				}
+			}
+
+			items, err := listBlobItems(ctx, containerName, "", azblob.BlobListingDetails{})
+			if err != nil {
+				return err
+			}
+
+			var (
+				mu                          sync.Mutex
+				changed, skipped, unmatched int
+			)
+			// report prints a per-blob line and counts the blob in n.
+			report := func(n *int, format string, a ...interface{}) {
+				mu.Lock()
+				defer mu.Unlock()
+				*n++
+				fmt.Fprintf(out, format, a...)
+			}
+			now := time.Now()
+			errs := runPool(len(items), concurrency, func(i int) error {
+				item := items[i]
+				from := item.Properties.AccessTier
+
+				rule, ok := matchTierRule(rules, item, now)
+				if !ok {
+					mu.Lock()
+					defer mu.Unlock()
+					unmatched++
+					return nil
+				}
+
+				action, skip := tierChange(item, rule.tier)
+				if skip != "" {
+					report(&skipped, "SKIPPED %s: %s\n", item.Name, skip)
+					return nil
+				}
+				if dryRun {
+					report(&changed, "would %s %s: %s -> %s\n", strings.ToLower(action), item.Name, from, rule.tier)
+					return nil
+				}
+
+				if _, err := newBlobURL(containerName, item.Name).SetTier(ctx, rule.tier, azblob.LeaseAccessConditions{}); err != nil {
+					return err
+				}
+				report(&changed, "%s %s: %s -> %s\n", action, item.Name, from, rule.tier)
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", items[e.Index].Name, e.Err))
+			}
+
+			verb := "Changed"
+			if dryRun {
+				verb = "Would change"
+			}
+			logger.Info(fmt.Sprintf("%s: %d, skipped: %d, matching no rule: %d, failed: %d", verb, changed, skipped, unmatched, len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if !dryRun {
+				logger.Info(fmt.Sprintf("Successfully applied the rules of %q", rulesFile))
+			}
+			return nil
+		},
+	}
+)
+
+// matchTierRule returns the first rule matching the key and age of item.
+func matchTierRule(rules []tierRule, item azblob.BlobItemInternal, now time.Time) (tierRule, bool) {
+	age := now.Sub(item.Properties.LastModified)
+	for _, rule := range rules {
+		if strings.HasPrefix(item.Name, rule.prefix) && age >= rule.minAge {
+			return rule, true
+		}
+	}
+	return tierRule{}, false
+}
+
+// readTierRules reads the tier rules from the file at path, one per line.
+// Blank lines and lines starting with "#" are ignored.
+func readTierRules(path string) ([]tierRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []tierRule
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: rule should be \"PREFIX AGE TIER\"", path, n)
+		}
+		rule := tierRule{prefix: fields[0]}
+		if rule.prefix == "*" {
+			rule.prefix = ""
+		}
+		if rule.minAge, err = parseAge(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		var ok bool
+		if rule.tier, ok = parseAccessTier(fields[2]); !ok {
+			return nil, fmt.Errorf("%s:%d: tier should be one of \"Hot\", \"Cool\" or \"Archive\"", path, n)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no rules found in %q", path)
+	}
+	return rules, nil
+}
+
+// parseAge parses a non-negative age given as a number of days, such as
+// "30d", or as a time.Duration, such as "12h".
+func parseAge(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, should be a number of days such as \"30d\" or a duration such as \"12h\"", s)
+	}
+	return d, nil
+}
+
+func init() {
+	tierByRulesCmd.PersistentFlags().StringVar(&rulesFile, "rules-file", "", "indicate a file with one \"PREFIX AGE TIER\" rule per line")
+
+	rootCmd.AddCommand(tierByRulesCmd)
+}
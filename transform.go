@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	destKey      string
+	transformCmd string
+
+	// Commands
+	transformBlobCmd = &cobra.Command{
+		Use:         "transform",
+		Short:       "Stream a blob through an external command into another blob",
+		Annotations: mutating,
+		Long: `Stream a blob through an external command into another blob.
+
+The content of --blob-key is piped to the standard input of --transform-cmd,
+which is run with "sh -c", and its standard output is written to --dest-key.
+Nothing is buffered on local disk, so e.g. --transform-cmd "gzip -9" or
+--transform-cmd "gpg --encrypt -r me@example.com" can be applied to blobs of
+any size. The destination blob is only committed if the command succeeds.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			if destKey == "" {
+				return fmt.Errorf(`flag "--dest-key" should be set`)
+			}
+
+			if transformCmd == "" {
+				return fmt.Errorf(`flag "--transform-cmd" should be set`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			if err := transform(ctx, blobKey, destKey, transformCmd, cmd.ErrOrStderr()); err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully transformed %q into %q", blobKey, destKey))
+			return nil
+		},
+	}
+)
+
+// transform streams the blob src through the shell command command and
+// writes its output to the blob dst. The command's stderr goes to errOut. If the command fails, the write to dst
+// is aborted so that no partial blob is committed.
+func transform(ctx context.Context, src, dst, command string, errOut io.Writer) error {
+	bucket, release, err := getBucket(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	r, err := bucket.NewReader(ctx, src, nil)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Cancelling the writer's context before Close aborts the upload.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := bucket.NewWriter(wctx, dst, nil)
+	if err != nil {
+		return err
+	}
+
+	c := exec.CommandContext(ctx, "sh", "-c", command)
+	c.Stdin = r
+	c.Stderr = errOut
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+
+	if err := c.Start(); err != nil {
+		cancel()
+		w.Close()
+		return fmt.Errorf("starting transform command %q: %v", command, err)
+	}
+
+	if _, err := io.Copy(w, stdout); err != nil {
+		cancel()
+		w.Close()
+		c.Process.Kill()
+		c.Wait()
+		return err
+	}
+
+	if err := c.Wait(); err != nil {
+		cancel()
+		w.Close()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("transform command %q exited with code %d", command, exitErr.ExitCode())
+		}
+		return fmt.Errorf("transform command %q: %v", command, err)
+	}
+
+	return w.Close()
+}
+
+func init() {
+	transformBlobCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to read from")
+	transformBlobCmd.PersistentFlags().StringVar(&destKey, "dest-key", "", "indicate a blob key to write the transformed content to")
+	transformBlobCmd.PersistentFlags().StringVar(&transformCmd, "transform-cmd", "", "indicate a shell command to pipe the blob content through")
+
+	rootCmd.AddCommand(transformBlobCmd)
+}
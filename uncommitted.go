@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	purge bool
+
+	// Commands
+	uncommittedBlocksCmd = &cobra.Command{
+		Use:   "uncommitted-blocks",
+		Short: "Report and purge uncommitted blocks under a prefix",
+		Long: `Report and purge uncommitted blocks under a prefix.
+
+Interrupted block uploads leave staged blocks that were never committed.
+They take up space but don't show up in normal listings. Every block blob
+under --blob-prefix, including blobs that only exist as uncommitted blocks,
+is checked and those with uncommitted blocks are reported with their count
+and size.
+
+With --purge, blobs that only exist as uncommitted blocks are purged by
+committing an empty block list, which discards the blocks, and deleting the
+resulting empty blob. Uncommitted blocks of committed blobs are reported but
+left alone, since discarding them would rewrite the blob. Combine with
+--dry-run to only print what would be purged.
+
+Azure discards uncommitted blocks by itself if the blob they belong to is
+not committed within a week, so purging only reclaims the space earlier.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			if purge && !dryRun {
+				if err := checkWritable("purge uncommitted blocks"); err != nil {
+					return err
+				}
+			}
+
+			items, err := listBlobItems(ctx, containerName, blobPrefix, azblob.BlobListingDetails{UncommittedBlobs: true})
+			if err != nil {
+				return err
+			}
+
+			var (
+				mu             sync.Mutex
+				found, purged  int
+				uncommittedLen int64
+			)
+			errs := runPool(len(items), concurrency, func(i int) error {
+				item := items[i]
+				if item.Properties.BlobType != azblob.BlobBlockBlob {
+					return nil
+				}
+
+				blockBlobURL := newBlobURL(containerName, item.Name).ToBlockBlobURL()
+				list, err := blockBlobURL.GetBlockList(ctx, azblob.BlockListAll, azblob.LeaseAccessConditions{})
+				if err != nil {
+					return err
+				}
+				if len(list.UncommittedBlocks) == 0 {
+					return nil
+				}
+				var size int64
+				for _, b := range list.UncommittedBlocks {
+					size += int64(b.Size)
+				}
+				orphaned := len(list.CommittedBlocks) == 0 && !blobExists(blockBlobURL.BlobURL)
+
+				status := "committed blob, left alone"
+				switch {
+				case !orphaned:
+				case !purge:
+					status = "never committed"
+				case dryRun:
+					status = "would purge"
+				default:
+					if err := purgeUncommitted(blockBlobURL); err != nil {
+						return err
+					}
+					status = "purged"
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				found++
+				uncommittedLen += size
+				if status == "purged" {
+					purged++
+				}
+				fmt.Fprintf(out, "%s: %d uncommitted blocks, %s (%s)\n", item.Name, len(list.UncommittedBlocks), formatBytes(size), status)
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", items[e.Index].Name, e.Err))
+			}
+
+			logger.Info(fmt.Sprintf("Blobs with uncommitted blocks: %d, uncommitted: %s, purged: %d, failed: %d",
+				found, formatBytes(uncommittedLen), purged, len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+			return nil
+		},
+	}
+)
+
+// blobExists reports whether the blob has been committed. A blob that only
+// exists as uncommitted blocks has no properties.
+func blobExists(blobURL azblob.BlobURL) bool {
+	_, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+		return false
+	}
+	return true
+}
+
+// purgeUncommitted discards the uncommitted blocks of a blob that was never
+// committed by committing an empty block list and deleting the empty blob.
+func purgeUncommitted(blockBlobURL azblob.BlockBlobURL) error {
+	// Only commit if nobody committed the blob in the meantime.
+	resp, err := blockBlobURL.CommitBlockList(ctx, []string{}, azblob.BlobHTTPHeaders{}, azblob.Metadata{},
+		azblob.BlobAccessConditions{
+			ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny},
+		}, azblob.AccessTierNone, nil, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return err
+	}
+
+	_, err = blockBlobURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: resp.ETag()},
+	})
+	return err
+}
+
+func init() {
+	uncommittedBlocksCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to look for uncommitted blocks under")
+	uncommittedBlocksCmd.PersistentFlags().BoolVar(&purge, "purge", false, "purge the uncommitted blocks of blobs that were never committed")
+
+	rootCmd.AddCommand(uncommittedBlocksCmd)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commands
+	undeleteBlobCmd = &cobra.Command{
+		Use:         "undelete-blob",
+		Short:       "Restore a soft-deleted blob",
+		Annotations: mutating,
+		Long: `Restore a soft-deleted blob.
+
+If soft delete is enabled on the account, the deleted --blob-key and its
+deleted snapshots are restored, as long as their retention period has not
+passed. Use list --include-deleted to find deleted blobs. Restoring a blob
+that is not deleted has no effect.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			if _, err := newBlobURL(containerName, blobKey).Undelete(ctx); err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("Successfully restored %q", blobKey))
+			return nil
+		},
+	}
+)
+
+// deletedRetention describes when a soft-deleted blob with the properties
+// props was deleted and how long it can still be restored, as ", deleted
+// TIME, N days left", leaving out what is unknown.
+func deletedRetention(props azblob.BlobProperties) string {
+	var s string
+	if props.DeletedTime != nil {
+		s += ", deleted " + props.DeletedTime.Format(time.RFC3339)
+	}
+	if props.RemainingRetentionDays != nil {
+		s += fmt.Sprintf(", %d days left", *props.RemainingRetentionDays)
+	}
+	return s
+}
+
+func init() {
+	undeleteBlobCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to restore")
+
+	rootCmd.AddCommand(undeleteBlobCmd)
+}
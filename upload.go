@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+const (
+	// mib is the number of bytes in a MiB.
+	mib = 1 << 20
+	// defaultBlockSize is the size of the blocks blobs are uploaded in,
+	// unless --block-size is set.
+	defaultBlockSize = 8 * mib
+)
+
+var (
+	// Flags
+	localFile          string
+	uploadContentType  string
+	uploadEncoding     string
+	uploadCacheControl string
+	blockSizeMiB       int
+	uploadParallelism  int
+	ifChanged          bool
+
+	// Commands
+	uploadFileCmd = &cobra.Command{
+		Use:         "upload-file",
+		Short:       "Upload a local file to a blob",
+		Annotations: mutating,
+		Long: `Upload a local file to a blob.
+
+The content of --file is streamed to --blob-key, so binary and large files
+can be uploaded. The content type is taken from the file extension unless
+--content-type is set. With --content-type-detect-magic, the content type of
+files whose extension is unknown is sniffed from their first bytes, by the
+magic bytes of common data formats, such as Parquet or tar, or else as
+browsers would. --content-encoding and --cache-control set the
+matching headers, e.g. to serve the blob from a static website or CDN, and
+are left to the service defaults if empty. Unless --no-md5 is set, the file
+is read once more beforehand to compute the Content-MD5 stored with the
+blob. With --encryption-key, the blob is encrypted with that
+customer-provided key.
+
+With --gzip, the file is compressed with gzip as it is uploaded and stored
+with Content-Encoding gzip, so that read and download-file decompress it.
+No Content-MD5 is stored then.
+
+Files are uploaded in blocks of --block-size MiB, --parallelism blocks at a
+time, which take up as much memory. A blob has at most 50000 blocks, so
+files over 390 GiB need a larger block size than the default of 8 MiB.
+With --progress, the number of bytes uploaded is printed to standard error
+as the upload goes.
+
+With --if-changed, the file is only uploaded if the blob doesn't exist yet
+or differs from it in size or MD5, so that deploy scripts can be re-run
+without rewriting unchanged blobs. Blobs without a stored MD5 are always
+uploaded. Since the comparison relies on it, the MD5 is stored even with
+--no-md5, and --if-changed cannot be combined with --gzip.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			errOut := cmd.ErrOrStderr()
+
+			// Check if valid flags
+			if blobKey == "" {
+				return fmt.Errorf(`flag "--blob-key" should be set`)
+			}
+			if localFile == "" {
+				return fmt.Errorf(`flag "--file" should be set`)
+			}
+			if gzipped && uploadEncoding != "" && uploadEncoding != "gzip" {
+				return fmt.Errorf(`flag "--gzip" cannot be combined with "--content-encoding" %q`, uploadEncoding)
+			}
+			if blockSizeMiB < 0 || blockSizeMiB > azblob.BlockBlobMaxStageBlockBytes/mib {
+				return fmt.Errorf(`flag "--block-size" should be between 1 and %d MiB, or 0 for the default`, azblob.BlockBlobMaxStageBlockBytes/mib)
+			}
+			if uploadParallelism < 0 {
+				return fmt.Errorf(`flag "--parallelism" should not be negative`)
+			}
+			if ifChanged && gzipped {
+				return fmt.Errorf(`flag "--if-changed" cannot be combined with "--gzip"`)
+			}
+
+			// Send the customer-provided key, if any, with the requests
+			ctx, err := encryptionContext(ctx)
+			if err != nil {
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			contentType := uploadContentType
+			if contentType == "" {
+				contentType = mime.TypeByExtension(filepath.Ext(localFile))
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			var sum []byte
+			if ifChanged {
+				var unchanged bool
+				if sum, unchanged, err = uploadUnchanged(ctx, bucket, blobKey, localFile); err != nil {
+					return err
+				}
+				if unchanged {
+					logger.Info(fmt.Sprintf("Blob %q is unchanged from %q, not uploaded", blobKey, localFile))
+					return nil
+				}
+			}
+
+			opts := &blob.WriterOptions{
+				ContentType:     contentType,
+				ContentEncoding: uploadEncoding,
+				CacheControl:    uploadCacheControl,
+			}
+			if err := setBlockOptions(opts, localFile); err != nil {
+				return err
+			}
+			if gzipped {
+				opts.ContentEncoding = "gzip"
+			} else if sum != nil {
+				opts.ContentMD5 = sum
+			} else if !noMD5 || ifChanged {
+				if opts.ContentMD5, err = fileMD5(localFile); err != nil {
+					return err
+				}
+			}
+
+			f, err := os.Open(localFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			var (
+				src io.Reader = f
+				p   *progress
+			)
+			if opts.ContentType == "" && detectMagic {
+				if opts.ContentType, src, err = sniffContentType(src); err != nil {
+					return err
+				}
+			}
+			if showProgress {
+				fi, err := f.Stat()
+				if err != nil {
+					return err
+				}
+				p = newProgress(errOut, fi.Size())
+				src = io.TeeReader(src, p)
+			}
+			if gzipped {
+				zr := gzipReader(src)
+				defer zr.Close()
+				src = zr
+			}
+
+			n, err := writeBlob(ctx, bucket, blobKey, src, opts)
+			if p != nil {
+				p.done()
+			}
+			if err != nil {
+				return err
+			}
+
+			if gzipped {
+				logger.Info(fmt.Sprintf("Successfully uploaded %q (%s compressed) to %q", localFile, formatBytes(n), blobKey))
+				return nil
+			}
+			logger.Info(fmt.Sprintf("Successfully uploaded %q (%s) to %q", localFile, formatBytes(n), blobKey))
+			return nil
+		},
+	}
+)
+
+// setBlockOptions sets the block size and the number of blocks uploaded in
+// parallel of opts from --block-size and --parallelism, checking that the
+// file at path fits in the maximum number of blocks of a blob.
+func setBlockOptions(opts *blob.WriterOptions, path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	blockSize := int64(blockSizeMiB) * mib
+	if blockSize == 0 {
+		blockSize = defaultBlockSize
+	}
+	if blocks := (fi.Size() + blockSize - 1) / blockSize; blocks > azblob.BlockBlobMaxBlocks {
+		min := (fi.Size() + azblob.BlockBlobMaxBlocks*mib - 1) / (azblob.BlockBlobMaxBlocks * mib)
+		return fmt.Errorf("%q (%s) needs %d blocks of %s, over the limit of %d, set \"--block-size\" to at least %d MiB",
+			path, formatBytes(fi.Size()), blocks, formatBytes(blockSize), azblob.BlockBlobMaxBlocks, min)
+	}
+	opts.BufferSize = int(blockSize)
+
+	if uploadParallelism > 0 {
+		opts.BeforeWrite = func(as func(interface{}) bool) error {
+			var uploadOpts *azblob.UploadStreamToBlockBlobOptions
+			if as(&uploadOpts) {
+				uploadOpts.MaxBuffers = uploadParallelism
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// uploadFile copies the local file at path to key in b and returns the
+// number of bytes written. The blob is only committed if the whole file
+// was copied.
+func uploadFile(ctx context.Context, b *blob.Bucket, key, path string, opts *blob.WriterOptions) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return writeBlob(ctx, b, key, f, opts)
+}
+
+// writeBlob copies the content of r to key in b and returns the number of
+// bytes written. The blob is only committed if the whole content was
+// copied.
+func writeBlob(ctx context.Context, b *blob.Bucket, key string, r io.Reader, opts *blob.WriterOptions) (int64, error) {
+	// Cancelling the writer's context before Close aborts the upload.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := b.NewWriter(wctx, key, opts)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		cancel()
+		w.Close()
+		return n, err
+	}
+	// The blob is committed by Close, so its error must not be ignored.
+	return n, w.Close()
+}
+
+// uploadUnchanged reports whether key in b has the size and MD5 of the
+// local file at path, along with the MD5 of the file if it was computed.
+func uploadUnchanged(ctx context.Context, b *blob.Bucket, key, path string) ([]byte, bool, error) {
+	attrs, err := b.Attributes(ctx, key)
+	if gcerrors.Code(err) == gcerrors.NotFound {
+		return fileUnchanged(nil, path)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return fileUnchanged(attrs, path)
+}
+
+// fileUnchanged reports whether the blob with attrs, nil for a missing blob,
+// has the size and MD5 of the local file at path, along with the MD5 of the
+// file. A blob without a stored MD5 is changed. The file is only hashed if
+// the sizes match, and the MD5 is nil otherwise.
+func fileUnchanged(attrs *blob.Attributes, path string) ([]byte, bool, error) {
+	if attrs == nil || len(attrs.MD5) == 0 {
+		return nil, false, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if fi.Size() != attrs.Size {
+		return nil, false, nil
+	}
+
+	sum, err := fileMD5(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return sum, bytes.Equal(sum, attrs.MD5), nil
+}
+
+// fileMD5 returns the MD5 of the content of the local file at path.
+func fileMD5(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func init() {
+	uploadFileCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to upload to")
+	uploadFileCmd.PersistentFlags().StringVar(&localFile, "file", "", "indicate a local file to upload")
+	uploadFileCmd.PersistentFlags().StringVar(&uploadContentType, "content-type", "", "indicate a content type (detected from the file extension if empty)")
+	uploadFileCmd.PersistentFlags().BoolVar(&detectMagic, "content-type-detect-magic", false, "sniff the content type from the first bytes of the file if its extension is unknown")
+	uploadFileCmd.PersistentFlags().StringVar(&uploadEncoding, "content-encoding", "", "indicate a content encoding (e.g. \"gzip\") to store with the blob")
+	uploadFileCmd.PersistentFlags().StringVar(&encryptionKey, "encryption-key", "", "indicate a base64 encoded AES-256 key to encrypt the blob with")
+	uploadFileCmd.PersistentFlags().StringVar(&encryptionKeySHA256, "encryption-key-sha256", "", "indicate the base64 encoded SHA-256 of --encryption-key (computed if empty)")
+	uploadFileCmd.PersistentFlags().IntVar(&blockSizeMiB, "block-size", 0, "indicate a block size in MiB, up to 4000 (8 if 0)")
+	uploadFileCmd.PersistentFlags().IntVar(&uploadParallelism, "parallelism", 0, "indicate a number of blocks to upload in parallel (5 if 0)")
+	uploadFileCmd.PersistentFlags().BoolVar(&showProgress, "progress", false, "print the progress of the upload to stderr")
+	uploadFileCmd.PersistentFlags().BoolVar(&gzipped, "gzip", false, "compress the file with gzip and store it with Content-Encoding gzip")
+	uploadFileCmd.PersistentFlags().BoolVar(&ifChanged, "if-changed", false, "only upload the file if the blob is missing or differs in size or MD5")
+	uploadFileCmd.PersistentFlags().StringVar(&uploadCacheControl, "cache-control", "", "indicate a cache control (e.g. \"max-age=3600\") to store with the blob")
+
+	rootCmd.AddCommand(uploadFileCmd)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+// uploadStateFlushInterval is how often at most the state file of
+// upload-dir is rewritten as files are uploaded.
+const uploadStateFlushInterval = time.Second
+
+// uploadState is persisted to --state-file so that an interrupted
+// upload-dir can skip the files it already uploaded.
+type uploadState struct {
+	// Dir and Prefix are the directory and the prefix being uploaded to. A
+	// state file recorded for one cannot be used to resume another.
+	Dir    string `json:"dir"`
+	Prefix string `json:"prefix"`
+	// Uploaded maps the keys uploaded so far to the file they were
+	// uploaded from.
+	Uploaded map[string]uploadedFile `json:"uploaded"`
+}
+
+// uploadedFile is the size and modification time of an uploaded file, so
+// that files changed since they were uploaded are uploaded again.
+type uploadedFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+var (
+	// Flags
+	destPrefix      string
+	continueOnError bool
+
+	// Commands
+	uploadDirCmd = &cobra.Command{
+		Use:         "upload-dir",
+		Short:       "Upload a local directory into a container",
+		Annotations: mutating,
+		Long: `Upload a local directory into a container.
+
+Every regular file under --dir is uploaded as by upload-file, --concurrency
+files at a time, to the key made of --dest-prefix and its path relative to
+--dir with "/" separators. For example, with --dest-prefix backups/,
+"dir/a/b.txt" is uploaded to "backups/a/b.txt". Symbolic links and other
+special files are skipped.
+
+The first failing upload cancels the remaining ones, unless
+--continue-on-error is set, in which case every file is attempted and the
+failures are reported at the end.
+
+With --state-file, the files uploaded are recorded in that file as the
+upload goes, so that re-running the same upload after an interruption
+skips them, unless they changed in size or modification time since. The
+file is rewritten atomically at most once a second, so a crash loses little
+progress, and removed once every file is uploaded.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if localDir == "" {
+				return fmt.Errorf(`flag "--dir" should be set`)
+			}
+
+			files, err := walkFiles(localDir)
+			if err != nil {
+				return err
+			}
+
+			state, err := readUploadState(stateFile, localDir, destPrefix)
+			if err != nil {
+				return err
+			}
+
+			if skipDryRun(cmd) {
+				return nil
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			// Cancelling uctx stops the uploads after the first failure.
+			uctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			var (
+				mu        sync.Mutex
+				uploaded  int
+				skipped   int
+				size      int64
+				lastFlush time.Time
+				flushErr  error
+			)
+			// flush records state to --state-file, at most once per
+			// uploadStateFlushInterval and until it fails, unless force is
+			// set. It is called with mu held.
+			flush := func(force bool) {
+				if stateFile == "" || (!force && (flushErr != nil || time.Since(lastFlush) < uploadStateFlushInterval)) {
+					return
+				}
+				lastFlush = time.Now()
+				if flushErr = writeStateFile(stateFile, state); flushErr != nil {
+					logger.Error(fmt.Sprintf("Writing state file %q: %v", stateFile, flushErr))
+				}
+			}
+			errs := runPool(len(files), concurrency, func(i int) error {
+				if uctx.Err() != nil {
+					return nil
+				}
+
+				path := filepath.Join(localDir, files[i])
+				key := uploadKey(destPrefix, files[i])
+				fi, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				file := uploadedFile{Size: fi.Size(), ModTime: fi.ModTime().UTC()}
+
+				mu.Lock()
+				if done, ok := state.Uploaded[key]; ok && done.Size == file.Size && done.ModTime.Equal(file.ModTime) {
+					skipped++
+					fmt.Fprintf(out, "SKIPPED %s: already uploaded\n", path)
+					mu.Unlock()
+					return nil
+				}
+				mu.Unlock()
+
+				opts := &blob.WriterOptions{ContentType: mime.TypeByExtension(filepath.Ext(path))}
+				var n int64
+				if !noMD5 {
+					opts.ContentMD5, err = fileMD5(path)
+				}
+				if err == nil {
+					n, err = uploadFile(uctx, bucket, key, path, opts)
+				}
+				if err != nil {
+					if uctx.Err() != nil {
+						// Aborted because of another failure
+						return nil
+					}
+					if !continueOnError {
+						cancel()
+					}
+					return err
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				uploaded++
+				size += n
+				fmt.Fprintf(out, "UPLOADED %s -> %s\n", path, key)
+				state.Uploaded[key] = file
+				flush(false)
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", files[e.Index], e.Err))
+			}
+			logger.Info(fmt.Sprintf("Uploaded: %d (%s), skipped: %d, failed: %d, not uploaded: %d",
+				uploaded, formatBytes(size), skipped, len(errs), len(files)-uploaded-skipped-len(errs)))
+
+			// Keep the progress for the next run unless every file is
+			// uploaded
+			if stateFile != "" && (len(errs) > 0 || ctx.Err() != nil) {
+				mu.Lock()
+				flush(true)
+				mu.Unlock()
+			}
+
+			// An interrupt or the global --timeout also cancels uctx
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+
+			if stateFile != "" {
+				if err := os.Remove(stateFile); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+
+			logger.Info(fmt.Sprintf("Successfully uploaded %q to %q", localDir, destPrefix))
+			return nil
+		},
+	}
+)
+
+// readUploadState reads the upload state of dir to prefix from path, or
+// returns an empty state if path is empty or doesn't exist yet.
+func readUploadState(path, dir, prefix string) (*uploadState, error) {
+	state := &uploadState{Dir: dir, Prefix: prefix, Uploaded: make(map[string]uploadedFile)}
+	if path == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded uploadState
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("reading state file %q: %v", path, err)
+	}
+	if recorded.Dir != dir || recorded.Prefix != prefix {
+		return nil, fmt.Errorf("state file %q was recorded for %q to prefix %q, not %q to %q", path, recorded.Dir, recorded.Prefix, dir, prefix)
+	}
+	if recorded.Uploaded == nil {
+		recorded.Uploaded = make(map[string]uploadedFile)
+	}
+	return &recorded, nil
+}
+
+// walkFiles returns the paths of the regular files under dir, relative to
+// dir. Other files than directories are skipped with a warning.
+func walkFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			logger.Warn(fmt.Sprintf("Skipping %q: not a regular file", path))
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+// uploadKey returns the key of the local file at the relative path rel
+// under prefix, which is separated from rel by a "/".
+func uploadKey(prefix, rel string) string {
+	rel = filepath.ToSlash(rel)
+	if prefix == "" {
+		return rel
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + rel
+}
+
+func init() {
+	uploadDirCmd.PersistentFlags().StringVar(&localDir, "dir", "", "indicate a local directory to upload")
+	uploadDirCmd.PersistentFlags().StringVar(&destPrefix, "dest-prefix", "", "indicate a blob prefix to upload the files under")
+	uploadDirCmd.PersistentFlags().StringVar(&stateFile, "state-file", "", "indicate a file to record the files uploaded to, so an interrupted upload can be resumed")
+	uploadDirCmd.PersistentFlags().BoolVar(&continueOnError, "continue-on-error", false, "keep uploading the other files after a failure")
+
+	rootCmd.AddCommand(uploadDirCmd)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadDirReportsCountsWhenCanceled(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The timeout cancels the command as an interrupt would
+	s := newFakeService(t, "test")
+	_, stderr, err := executeFake(t, s, "upload-dir", "--dir", dir, "--timeout", "1ns")
+	if err == nil {
+		t.Fatal("upload-dir past its timeout succeeded")
+	}
+	if !strings.Contains(stderr, "Uploaded: 0 (0 B), skipped: 0, failed: 3") {
+		t.Errorf("upload-dir past its timeout printed %q, want the counts", stderr)
+	}
+}
+
+func TestUploadDirOutput(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newFakeService(t, "test")
+
+	stdout, stderr, err := executeFake(t, s, "upload-dir", "--dir", dir, "--dest-prefix", "up")
+	if err != nil {
+		t.Fatalf("upload-dir: %v", err)
+	}
+	if want := "UPLOADED " + filepath.Join(dir, "a.txt") + " -> up/a.txt\n"; stdout != want {
+		t.Errorf("upload-dir printed %q, want %q", stdout, want)
+	}
+	if !strings.Contains(stderr, "Uploaded: 1 (1 B), skipped: 0, failed: 0") {
+		t.Errorf("upload-dir printed %q to stderr, want the counts", stderr)
+	}
+
+	// The counts are logged, so --quiet leaves them out
+	_, stderr, err = executeFake(t, s, "upload-dir", "--dir", dir, "--dest-prefix", "up", "--quiet")
+	if err != nil {
+		t.Fatalf("upload-dir --quiet: %v", err)
+	}
+	if stderr != "" {
+		t.Errorf("upload-dir --quiet printed %q to stderr", stderr)
+	}
+}
+
+func TestUploadDirStateFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	s := newFakeService(t, "test")
+
+	// A state recorded by an interrupted run, after a.txt was uploaded
+	fi, err := os.Stat(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &uploadState{Dir: dir, Prefix: "up", Uploaded: map[string]uploadedFile{
+		"up/a.txt": {Size: fi.Size(), ModTime: fi.ModTime().UTC()},
+	}}
+	if err := writeStateFile(statePath, state); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := executeFake(t, s, "upload-dir", "--dir", dir, "--dest-prefix", "up", "--state-file", statePath)
+	if err != nil {
+		t.Fatalf("upload-dir --state-file: %v", err)
+	}
+	if !strings.Contains(stdout, "SKIPPED "+filepath.Join(dir, "a.txt")+": already uploaded\n") {
+		t.Errorf("upload-dir printed %q, want a.txt skipped", stdout)
+	}
+	if s.blob("test", "up/a.txt") != nil || s.blob("test", "up/b.txt") == nil {
+		t.Error("upload-dir --state-file uploaded another file than b.txt")
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("state file after a complete upload: got %v, want it removed", err)
+	}
+
+	// A state file of another upload is rejected
+	state.Prefix = "other"
+	if err := writeStateFile(statePath, state); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := executeFake(t, s, "upload-dir", "--dir", dir, "--dest-prefix", "up", "--state-file", statePath); err == nil {
+		t.Error("upload-dir with the state file of another prefix succeeded")
+	}
+}
+
+func TestUploadDirStateFileKeptOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	// The container doesn't exist, so the upload fails
+	s := newFakeService(t)
+	if _, _, err := executeFake(t, s, "upload-dir", "--dir", dir, "--state-file", statePath); err == nil {
+		t.Fatal("upload-dir to a missing container succeeded")
+	}
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("state file after a failed upload: %v", err)
+	}
+	state, err := readUploadState(statePath, dir, "")
+	if err != nil {
+		t.Fatalf("state file after a failed upload: %v", err)
+	}
+	if len(state.Uploaded) != 0 {
+		t.Errorf("state file records %v, want no uploads", state.Uploaded)
+	}
+}
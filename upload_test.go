@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"gocloud.dev/blob"
+)
+
+func TestFileUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	content := []byte("hello, world\n")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum(content)
+	other := md5.Sum([]byte("hello, WORLD\n"))
+
+	tests := []struct {
+		name          string
+		attrs         *blob.Attributes
+		wantUnchanged bool
+		wantSum       bool
+	}{
+		{name: "absent", attrs: nil},
+		{name: "same", attrs: &blob.Attributes{Size: int64(len(content)), MD5: sum[:]}, wantUnchanged: true, wantSum: true},
+		{name: "different content", attrs: &blob.Attributes{Size: int64(len(content)), MD5: other[:]}, wantSum: true},
+		// The file isn't hashed when the sizes differ
+		{name: "different size", attrs: &blob.Attributes{Size: 1, MD5: sum[:]}},
+		{name: "no stored MD5", attrs: &blob.Attributes{Size: int64(len(content))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unchanged, err := fileUnchanged(tt.attrs, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unchanged != tt.wantUnchanged {
+				t.Errorf("got unchanged %v, want %v", unchanged, tt.wantUnchanged)
+			}
+			if (got != nil) != tt.wantSum {
+				t.Errorf("got MD5 %x, want one: %v", got, tt.wantSum)
+			}
+			if got != nil && string(got) != string(sum[:]) {
+				t.Errorf("got MD5 %x, want %x", got, sum)
+			}
+		})
+	}
+}
+
+func TestFileUnchangedMissingFile(t *testing.T) {
+	attrs := &blob.Attributes{Size: 1, MD5: []byte{1}}
+	if _, _, err := fileUnchanged(attrs, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("got no error for a missing file")
+	}
+}
+
+func TestUploadFileHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.js")
+	if err := ioutil.WriteFile(path, []byte("console.log(1)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newFakeService(t, "test")
+	if _, _, err := executeFake(t, s, "upload-file", "--blob-key", "app.js", "--file", path, "--content-encoding", "identity", "--cache-control", "no-cache"); err != nil {
+		t.Fatalf("upload-file: %v", err)
+	}
+
+	attrs, err := openFakeBucket(t, s).Attributes(context.Background(), "app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.ContentEncoding != "identity" || attrs.CacheControl != "no-cache" {
+		t.Errorf("got Content-Encoding %q and Cache-Control %q, want identity and no-cache", attrs.ContentEncoding, attrs.CacheControl)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// maxBlobKeyLength is the maximum length of a blob name accepted by Azure.
+const maxBlobKeyLength = 1024
+
+// validateBlobKey checks key against Azure's blob naming rules.
+func validateBlobKey(key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("blob key is empty")
+	case len(key) > maxBlobKeyLength:
+		return fmt.Errorf("blob key is %d characters long, the maximum is %d", len(key), maxBlobKeyLength)
+	case strings.HasSuffix(key, ".") || strings.HasSuffix(key, "/"):
+		return fmt.Errorf("blob key %q should not end with a dot or a forward slash", key)
+	}
+	return nil
+}
+
+// validateWrite performs every check of a write of content to key without
+// uploading anything, and prints to out what the write would do.
+func validateWrite(ctx context.Context, out io.Writer, key string, content []byte) error {
+	if err := validateBlobKey(key); err != nil {
+		return err
+	}
+
+	// Checks both the credentials and that the container exists.
+	containerURL := newContainerURL(containerName)
+	if _, err := containerURL.GetProperties(ctx, azblob.LeaseAccessConditions{}); err != nil {
+		if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeContainerNotFound {
+			return fmt.Errorf("container %q does not exist", containerName)
+		}
+		return err
+	}
+
+	bucket, release, err := getBucket(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	exists, err := bucket.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	action := "create"
+	if exists {
+		action = "overwrite"
+	}
+	fmt.Fprintf(out, "Would %s %q in container %q\n", action, key, containerName)
+	fmt.Fprintln(out, "Size:", len(content))
+	// The blob writer sniffs the content type from the first 512 bytes
+	// when none is given, so report what it would detect.
+	fmt.Fprintln(out, "Content-Type:", http.DetectContentType(content))
+	fmt.Fprintf(out, "MD5: %x\n", md5.Sum(content))
+	return nil
+}
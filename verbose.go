@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// verbose is set with --verbose to log HTTP requests and responses.
+var verbose bool
+
+var (
+	// sigPattern matches the signature of SAS URLs, including signed copy
+	// sources in headers.
+	sigPattern = regexp.MustCompile(`([?&]sig=)[^&\s"]+`)
+	// authorizationPattern matches Authorization header lines.
+	authorizationPattern = regexp.MustCompile(`(?im)^(\s*Authorization:).*$`)
+)
+
+// logOptions returns the options that log requests and responses of the
+// pipeline with logger, at debug level.
+func logOptions() pipeline.LogOptions {
+	return pipeline.LogOptions{
+		Log: func(level pipeline.LogLevel, message string) {
+			logger.Debug(fmt.Sprintf("[%s] %s", logLevelName(level), strings.TrimRight(redactLog(message), "\n")))
+		},
+		ShouldLog: func(level pipeline.LogLevel) bool {
+			return level != pipeline.LogNone && level <= pipeline.LogInfo
+		},
+	}
+}
+
+// redactLog redacts SAS signatures and Authorization headers in message.
+// The SDK already redacts them in the URLs it logs, but not in every
+// header.
+func redactLog(message string) string {
+	message = sigPattern.ReplaceAllString(message, "${1}REDACTED")
+	return authorizationPattern.ReplaceAllString(message, "${1} REDACTED")
+}
+
+// logLevelName returns the name of level.
+func logLevelName(level pipeline.LogLevel) string {
+	switch level {
+	case pipeline.LogFatal:
+		return "FATAL"
+	case pipeline.LogPanic:
+		return "PANIC"
+	case pipeline.LogError:
+		return "ERROR"
+	case pipeline.LogWarning:
+		return "WARNING"
+	}
+	return "INFO"
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Commands
+	verifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify blobs under a prefix against their stored MD5",
+		Long: `Verify blobs under a prefix against their stored MD5.
+
+Every blob under --blob-prefix is downloaded and its MD5 is compared with
+the Content-MD5 stored for it. Blobs without a stored MD5 cannot be
+verified and are reported separately. The command exits with a non-zero
+status if any blob does not match or could not be read.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			var (
+				objs    []*blob.ListObject
+				skipped []string
+			)
+			iter := bucket.List(&blob.ListOptions{Prefix: blobPrefix})
+			for {
+				obj, err := iter.Next(ctx)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if obj.MD5 == nil {
+					skipped = append(skipped, obj.Key)
+					continue
+				}
+				objs = append(objs, obj)
+			}
+
+			var (
+				mu                 sync.Mutex
+				verified, mismatch int
+			)
+			errs := runPool(len(objs), concurrency, func(i int) error {
+				obj := objs[i]
+				sum, err := blobMD5(ctx, bucket, obj.Key)
+				if err != nil {
+					return err
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if !bytes.Equal(sum, obj.MD5) {
+					mismatch++
+					fmt.Fprintf(out, "%s %s: stored %x, computed %x\n", colorize(out, colorRed, "MISMATCH"), obj.Key, obj.MD5, sum)
+					return nil
+				}
+				verified++
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", objs[e.Index].Key, e.Err))
+			}
+			failed := len(errs)
+
+			for _, key := range skipped {
+				fmt.Fprintf(out, "SKIPPED %s: no stored MD5\n", key)
+			}
+			logger.Info(fmt.Sprintf("Verified: %d, mismatched: %d, failed: %d, skipped (no MD5): %d",
+				verified, mismatch, failed, len(skipped)))
+
+			if mismatch > 0 || failed > 0 {
+				return &exitError{Code: 1}
+			}
+
+			logger.Info(fmt.Sprintf("Successfully verified blobs under %q", blobPrefix))
+			return nil
+		},
+	}
+)
+
+// blobMD5 streams the blob key from b and returns the MD5 of its content.
+func blobMD5(ctx context.Context, b *blob.Bucket, key string) ([]byte, error) {
+	r, err := b.NewReader(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func init() {
+	verifyCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to verify")
+
+	rootCmd.AddCommand(verifyCmd)
+}
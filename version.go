@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Build information, set when building with e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// sdkModule is the module of the Azure SDK whose version is reported.
+const sdkModule = "github.com/Azure/azure-storage-blob-go"
+
+// versionInfo is the build information printed by version.
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	SDK       string `json:"sdkVersion,omitempty"`
+}
+
+var (
+	// Flags
+	versionJSON bool
+
+	// Commands
+	versionCmd = &cobra.Command{
+		Use:         "version",
+		Short:       "Print the version of the binary",
+		Annotations: accountLevel,
+		Long: `Print the version of the binary.
+
+The version, git commit and build date of the binary are printed along with
+the Go version it was built with and the version of the Azure Storage SDK,
+to include when reporting issues. Use --json to parse them.`,
+		Args: cobra.NoArgs,
+		// Printing the version needs no account
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			info := versionInfo{
+				Version:   version,
+				Commit:    commit,
+				BuildDate: buildDate,
+				GoVersion: runtime.Version(),
+				SDK:       sdkVersion(),
+			}
+
+			if versionJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			}
+
+			fmt.Fprintf(out, "Version:    %s\n", info.Version)
+			fmt.Fprintf(out, "Commit:     %s\n", info.Commit)
+			fmt.Fprintf(out, "Build date: %s\n", info.BuildDate)
+			fmt.Fprintf(out, "Go version: %s\n", info.GoVersion)
+			if info.SDK != "" {
+				fmt.Fprintf(out, "SDK:        %s %s\n", sdkModule, info.SDK)
+			}
+			return nil
+		},
+	}
+)
+
+// sdkVersion returns the version of sdkModule the binary was built with, or
+// an empty string if the build information is not available.
+func sdkVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path != sdkModule {
+			continue
+		}
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return ""
+}
+
+func init() {
+	versionCmd.PersistentFlags().BoolVar(&versionJSON, "json", false, "print the build information as a JSON object")
+
+	rootCmd.AddCommand(versionCmd)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+var (
+	// Flags
+	waitInterval time.Duration
+
+	// Commands
+	waitForCmd = &cobra.Command{
+		Use:   "wait-for",
+		Short: "Wait until a blob exists",
+		Long: `Wait until a blob exists.
+
+The blob is polled every --interval until it exists, in which case the
+command exits with status 0, or until --timeout elapses, in which case it
+exits with status 1. A zero timeout waits forever. Interrupting the wait
+exits with status 130.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWait(cmd, true)
+		},
+	}
+
+	waitUntilGoneCmd = &cobra.Command{
+		Use:   "wait-until-gone",
+		Short: "Wait until a blob no longer exists",
+		Long: `Wait until a blob no longer exists.
+
+The blob is polled every --interval until it no longer exists, in which
+case the command exits with status 0, or until --timeout elapses, in which
+case it exits with status 1. A zero timeout waits forever. Interrupting the
+wait exits with status 130.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWait(cmd, false)
+		},
+	}
+)
+
+// runWait waits for the blob --blob-key to exist (if exists is true) or to
+// be deleted. A timeout or an interrupt is reported with an *exitError.
+func runWait(cmd *cobra.Command, exists bool) error {
+	errOut := cmd.ErrOrStderr()
+
+	// Check if valid flags
+	if blobKey == "" {
+		return fmt.Errorf(`flag "--blob-key" should be set`)
+	}
+
+	if waitInterval <= 0 {
+		return fmt.Errorf(`flag "--interval" should be positive`)
+	}
+
+	bucket, release, err := getBucket(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	want, done := "exist", "exists"
+	if !exists {
+		want, done = "be deleted", "no longer exists"
+	}
+
+	err = pollExists(ctx, bucket, blobKey, exists, waitInterval)
+	switch {
+	case err == nil:
+		logger.Info(fmt.Sprintf("Blob %q %s", blobKey, done))
+		return nil
+	case err == context.DeadlineExceeded:
+		fmt.Fprintf(errOut, "Timed out after %s waiting for %q to %s\n", timeout, blobKey, want)
+		return &exitError{Code: 1}
+	case err == context.Canceled:
+		fmt.Fprintf(errOut, "Interrupted while waiting for %q to %s\n", blobKey, want)
+		return &exitError{Code: 130}
+	default:
+		return err
+	}
+}
+
+// pollExists checks every interval whether key exists in b, until its
+// existence matches exists or ctx is done. It returns ctx.Err() in the
+// latter case.
+func pollExists(ctx context.Context, b *blob.Bucket, key string, exists bool, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := b.Exists(ctx, key)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if ok == exists {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func init() {
+	waitForCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to wait for")
+	waitForCmd.PersistentFlags().DurationVar(&waitInterval, "interval", 5*time.Second, "indicate how often to check for the blob")
+
+	waitUntilGoneCmd.PersistentFlags().StringVar(&blobKey, "blob-key", "", "indicate a blob key to wait for the deletion of")
+	waitUntilGoneCmd.PersistentFlags().DurationVar(&waitInterval, "interval", 5*time.Second, "indicate how often to check for the blob")
+
+	rootCmd.AddCommand(waitForCmd)
+	rootCmd.AddCommand(waitUntilGoneCmd)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags
+	watchInterval time.Duration
+	watchOnce     bool
+
+	// Commands
+	watchCmd = &cobra.Command{
+		Use:   "watch",
+		Short: "Print blobs as they are written under a prefix",
+		Long: `Print blobs as they are written under a prefix.
+
+The blobs under --blob-prefix are listed every --interval, and the keys of
+blobs that are new or were modified since the previous listing, i.e. whose
+ETag changed, are printed, which tails the container like "tail -f". The
+blobs present when the watch starts are not printed. Blobs are watched
+until --timeout elapses, which exits with status 0, or until the command
+is interrupted, which exits with status 130 like other commands.
+
+With --once, the blobs are listed a single time and compared to the
+baseline recorded in --state-file by the previous run, which is then
+replaced by the current listing. Without a state file, or on the first
+run, every blob is printed. The state file is compatible with that of
+list --incremental.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if watchInterval <= 0 {
+				return fmt.Errorf(`flag "--interval" should be positive`)
+			}
+			if stateFile != "" && !watchOnce {
+				return fmt.Errorf(`flag "--state-file" can only be used with "--once"`)
+			}
+
+			if watchOnce {
+				return watchOnceAgainst(ctx, out, blobPrefix, stateFile)
+			}
+
+			// ctx is bounded by --timeout and cancelled on interrupts
+			err := watchPrefix(ctx, out, blobPrefix, watchInterval)
+			if err == context.Canceled || err == context.DeadlineExceeded {
+				return nil
+			}
+			return err
+		},
+	}
+)
+
+// watchPrefix lists the blobs under prefix every interval and prints the
+// keys that are new or whose ETag changed since the previous listing, until
+// ctx is done. It returns ctx.Err() in the latter case.
+func watchPrefix(ctx context.Context, out io.Writer, prefix string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var seen map[string]string
+	for {
+		items, err := listBlobItems(ctx, containerName, prefix, azblob.BlobListingDetails{})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		// The first listing is the baseline and isn't printed
+		if seen == nil {
+			seen = blobETags(items)
+			logger.Info(fmt.Sprintf("Watching %d blobs under %q", len(seen), prefix))
+		} else {
+			seen = printChangedBlobs(out, items, seen)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchOnceAgainst prints the keys of the blobs under prefix that are new or
+// changed since the listing recorded in the state file at path, which it
+// then replaces. With no path, every blob is printed.
+func watchOnceAgainst(ctx context.Context, out io.Writer, prefix, path string) error {
+	state := &listState{}
+	if path != "" {
+		var err error
+		if state, err = readListState(path); err != nil {
+			return err
+		}
+		if state.Seen != nil && state.Prefix != prefix {
+			return fmt.Errorf("state file %q was recorded for prefix %q, not %q", path, state.Prefix, prefix)
+		}
+	}
+
+	items, err := listBlobItems(ctx, containerName, prefix, azblob.BlobListingDetails{})
+	if err != nil {
+		return err
+	}
+	seen := printChangedBlobs(out, items, state.Seen)
+
+	if path == "" {
+		return nil
+	}
+	state.Prefix = prefix
+	state.Marker = ""
+	state.Seen = seen
+	state.Pending = nil
+	return writeListState(path, state)
+}
+
+// printChangedBlobs prints the keys of the items whose ETag differs from
+// the one in seen, or which are missing from it, and returns the ETags of
+// the items to compare the next listing with. Deleted blobs are dropped, so
+// a blob written again under the same key is printed again.
+func printChangedBlobs(out io.Writer, items []azblob.BlobItemInternal, seen map[string]string) map[string]string {
+	current := blobETags(items)
+	for _, item := range items {
+		if etag, ok := seen[item.Name]; !ok || etag != current[item.Name] {
+			fmt.Fprintln(out, item.Name)
+		}
+	}
+	return current
+}
+
+// blobETags maps the names of items to their ETags.
+func blobETags(items []azblob.BlobItemInternal) map[string]string {
+	etags := make(map[string]string, len(items))
+	for _, item := range items {
+		etags[item.Name] = string(item.Properties.Etag)
+	}
+	return etags
+}
+
+func init() {
+	watchCmd.PersistentFlags().StringVar(&blobPrefix, "blob-prefix", "", "indicate a blob prefix to watch")
+	watchCmd.PersistentFlags().DurationVar(&watchInterval, "interval", 5*time.Second, "indicate how often to list the blobs")
+	watchCmd.PersistentFlags().BoolVar(&watchOnce, "once", false, "list the blobs once and print the changes since the state file")
+	watchCmd.PersistentFlags().StringVar(&stateFile, "state-file", "", "indicate a file recording the blobs listed by the previous run of --once")
+
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+)
+
+// batchEntry is a blob to write with write-batch.
+type batchEntry struct {
+	Key   string
+	Value string
+}
+
+var (
+	// Flags
+	batchFile     string
+	batchJSONFile string
+
+	// Commands
+	writeBatchCmd = &cobra.Command{
+		Use:   "write-batch",
+		Short: "Write many small blobs from a file",
+		Long: `Write many small blobs from a file.
+
+Every line of --file is a key and a value separated by a tab, and every
+entry of the JSON object in --from-json is a key and its string value. Each
+value is written to its key as by write, followed by a newline,
+--concurrency blobs at a time, e.g. to seed a container.
+
+Blank lines of --file are skipped. Nothing is written if any line is
+malformed, i.e. has no tab or an empty key, or if a key is given twice; the
+offending lines are reported with their numbers instead. Use --dry-run to
+only print what would be written.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			// Check if valid flags
+			if (batchFile == "") == (batchJSONFile == "") {
+				return fmt.Errorf(`exactly one of flags "--file" or "--from-json" should be set`)
+			}
+
+			var (
+				entries []batchEntry
+				err     error
+			)
+			if batchFile != "" {
+				entries, err = readBatchFile(batchFile)
+			} else {
+				entries, err = readBatchJSON(batchJSONFile)
+			}
+			if err != nil {
+				return err
+			}
+
+			if !dryRun {
+				if err := checkWritable("write blobs"); err != nil {
+					return err
+				}
+			}
+
+			bucket, release, err := getBucket(ctx)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			var (
+				mu      sync.Mutex
+				written int
+			)
+			errs := runPool(len(entries), concurrency, func(i int) error {
+				e := entries[i]
+				if !dryRun {
+					content := []byte(e.Value + "\n")
+					opts := &blob.WriterOptions{}
+					if !noMD5 {
+						sum := md5.Sum(content)
+						opts.ContentMD5 = sum[:]
+					}
+					if err := bucket.WriteAll(ctx, e.Key, content, opts); err != nil {
+						return err
+					}
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				written++
+				if dryRun {
+					fmt.Fprintf(out, "would write %s\n", e.Key)
+				} else {
+					fmt.Fprintf(out, "WROTE %s\n", e.Key)
+				}
+				return nil
+			})
+			for _, e := range errs {
+				logger.Error(fmt.Sprintf("%s: %v", entries[e.Index].Key, e.Err))
+			}
+
+			verb := "Written"
+			if dryRun {
+				verb = "Would write"
+			}
+			logger.Info(fmt.Sprintf("%s: %d, failed: %d", verb, written, len(errs)))
+
+			if len(errs) > 0 {
+				return &exitError{Code: 1}
+			}
+			return nil
+		},
+	}
+)
+
+// readBatchFile reads the tab-separated entries of write-batch from path.
+// All malformed lines are reported in the error, with their numbers.
+func readBatchFile(path string) ([]batchEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		entries []batchEntry
+		bad     []string
+	)
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	// Allow values up to a few MiB on one line
+	scanner.Buffer(nil, 4*mib)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		i := strings.IndexByte(line, '\t')
+		switch {
+		case i < 0:
+			bad = append(bad, fmt.Sprintf("line %d: no tab between key and value", n))
+		case i == 0:
+			bad = append(bad, fmt.Sprintf("line %d: empty key", n))
+		case seen[line[:i]] > 0:
+			bad = append(bad, fmt.Sprintf("line %d: key %q already given on line %d", n, line[:i], seen[line[:i]]))
+		default:
+			seen[line[:i]] = n
+			entries = append(entries, batchEntry{Key: line[:i], Value: line[i+1:]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %q: %v", path, err)
+	}
+	if len(bad) > 0 {
+		return nil, fmt.Errorf("%q has %d malformed lines, nothing was written:\n  %s", path, len(bad), strings.Join(bad, "\n  "))
+	}
+	return entries, nil
+}
+
+// readBatchJSON reads the entries of write-batch from the JSON object of
+// string values in path, sorted by key.
+func readBatchJSON(path string) ([]batchEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%q should be a JSON object of string values: %v", path, err)
+	}
+	if _, ok := values[""]; ok {
+		return nil, fmt.Errorf("%q has an empty key, nothing was written", path)
+	}
+
+	entries := make([]batchEntry, 0, len(values))
+	for key, value := range values {
+		entries = append(entries, batchEntry{Key: key, Value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func init() {
+	writeBatchCmd.PersistentFlags().StringVar(&batchFile, "file", "", "indicate a file of tab-separated key and value lines to write")
+	writeBatchCmd.PersistentFlags().StringVar(&batchJSONFile, "from-json", "", "indicate a JSON file of a key to value object to write")
+
+	rootCmd.AddCommand(writeBatchCmd)
+}
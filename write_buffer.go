@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/md5"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+var (
+	// Flags
+	bufferToTempFile bool
+)
+
+// spoolToTempFile copies r to a temporary file and returns it, positioned
+// at its start, along with the size and MD5 of the content. The caller
+// removes the file with the returned cleanup, which also closes it.
+func spoolToTempFile(r io.Reader) (f *os.File, size int64, sum []byte, cleanup func(), err error) {
+	f, err = ioutil.TempFile("", "azure-write-*")
+	if err != nil {
+		return nil, 0, nil, nil, err
+	}
+	cleanup = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	h := md5.New()
+	if size, err = io.Copy(io.MultiWriter(f, h), r); err != nil {
+		cleanup()
+		return nil, 0, nil, nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, 0, nil, nil, err
+	}
+	return f, size, h.Sum(nil), cleanup, nil
+}
+
+// blockSizeFor returns the size of the blocks to upload size bytes in: the
+// default block size, or the smallest whole number of MiB that fits size
+// in the maximum number of blocks of a blob, if larger.
+func blockSizeFor(size int64) int64 {
+	min := (size + azblob.BlockBlobMaxBlocks*mib - 1) / (azblob.BlockBlobMaxBlocks * mib) * mib
+	if min > defaultBlockSize {
+		return min
+	}
+	return defaultBlockSize
+}
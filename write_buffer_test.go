@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestWriteBufferToTempFile(t *testing.T) {
+	s := newFakeService(t, "test")
+	content := strings.Repeat("piped\n", 1000)
+	rootCmd.SetIn(strings.NewReader(content))
+	t.Cleanup(func() { rootCmd.SetIn(nil) })
+
+	if _, _, err := executeFake(t, s, "write", "--blob-key", "k", "--buffer-to-temp-file"); err != nil {
+		t.Fatalf("write --buffer-to-temp-file: %v", err)
+	}
+	b := s.blob("test", "k")
+	if b == nil {
+		t.Fatal("write --buffer-to-temp-file wrote no blob")
+	}
+	if string(b.content) != content {
+		t.Errorf("blob has %d bytes, want %d", len(b.content), len(content))
+	}
+	// Unlike streamed input, the spooled content has a known MD5
+	if got, want := b.header.Get("Content-MD5"), contentMD5([]byte(content)); got != want {
+		t.Errorf("blob has Content-MD5 %q, want %q", got, want)
+	}
+}
+
+func TestBlockSizeFor(t *testing.T) {
+	for _, tt := range []struct {
+		size int64
+		want int64
+	}{
+		{0, defaultBlockSize},
+		{defaultBlockSize * azblob.BlockBlobMaxBlocks, defaultBlockSize},
+		{defaultBlockSize*azblob.BlockBlobMaxBlocks + 1, defaultBlockSize + mib},
+	} {
+		if got := blockSizeFor(tt.size); got != tt.want {
+			t.Errorf("blockSizeFor(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}